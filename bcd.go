@@ -0,0 +1,218 @@
+package binaryio
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+func decodeDigits(raw []byte, packed bool) (digits []byte, neg bool, err error) {
+	digits = make([]byte, 0, 2*len(raw))
+	for i, b := range raw {
+		hi, lo := b>>4, b&0x0F
+		if hi > 9 {
+			return nil, false, ErrInvalidBCD
+		}
+		digits = append(digits, '0'+hi)
+		if packed && i == len(raw)-1 {
+			switch lo {
+			case 0x0B, 0x0D:
+				neg = true
+			case 0x0A, 0x0C, 0x0E, 0x0F:
+			default:
+				return nil, false, ErrInvalidBCD
+			}
+			break
+		}
+		if lo > 9 {
+			return nil, false, ErrInvalidBCD
+		}
+		digits = append(digits, '0'+lo)
+	}
+	return digits, neg, nil
+}
+
+func digitsToUint(digits []byte) (uint64, error) {
+	var v uint64
+	for _, d := range digits {
+		n := uint64(d - '0')
+		if v > (math.MaxUint64-n)/10 {
+			return 0, ErrOverflow
+		}
+		v = v*10 + n
+	}
+	return v, nil
+}
+
+func (br *Reader) readDigits(nbytes int, packed bool) ([]byte, bool) {
+	if nbytes < 1 {
+		br.setErr(ErrInvalidWidth)
+		return nil, false
+	}
+	raw := br.ReadRaw(uint64(nbytes))
+	if br.err != nil {
+		return nil, false
+	}
+	digits, neg, err := decodeDigits(raw, packed)
+	if err != nil {
+		br.setErr(err)
+		return nil, false
+	}
+	return digits, neg
+}
+
+// ReadBCD reads nbytes of unsigned packed BCD (two digits per byte, high nibble first).
+func (br *Reader) ReadBCD(nbytes int) uint64 {
+	if br.err != nil {
+		return 0
+	}
+	digits, _ := br.readDigits(nbytes, false)
+	if br.err != nil {
+		return 0
+	}
+	v, err := digitsToUint(digits)
+	if err != nil {
+		br.setErr(err)
+		return 0
+	}
+	return v
+}
+
+// ReadBCDString is like ReadBCD but returns all 2*nbytes digits, including leading zeros.
+func (br *Reader) ReadBCDString(nbytes int) string {
+	if br.err != nil {
+		return ""
+	}
+	digits, _ := br.readDigits(nbytes, false)
+	return string(digits)
+}
+
+// ReadPackedDecimal reads nbytes of IBM packed decimal: 2*nbytes-1 digits
+// followed by a sign nibble (B or D negative, A, C, E or F positive).
+func (br *Reader) ReadPackedDecimal(nbytes int) int64 {
+	if br.err != nil {
+		return 0
+	}
+	digits, neg := br.readDigits(nbytes, true)
+	if br.err != nil {
+		return 0
+	}
+	v, err := digitsToUint(digits)
+	if err == nil && (v > math.MaxInt64+1 || !neg && v > math.MaxInt64) {
+		err = ErrOverflow
+	}
+	if err != nil {
+		br.setErr(err)
+		return 0
+	}
+	if neg {
+		return -int64(v-1) - 1
+	}
+	return int64(v)
+}
+
+// ReadPackedDecimalString is like ReadPackedDecimal but returns a decimal
+// string without leading zeros, so any precision can be read.
+func (br *Reader) ReadPackedDecimalString(nbytes int) string {
+	if br.err != nil {
+		return ""
+	}
+	digits, neg := br.readDigits(nbytes, true)
+	if br.err != nil {
+		return ""
+	}
+	s := strings.TrimLeft(string(digits), "0")
+	if s == "" {
+		return "0"
+	}
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+func (bw *Writer) writeDigits(digits string, nbytes int, packed, neg bool) int {
+	if nbytes < 1 {
+		bw.setErr(ErrInvalidWidth)
+		return 0
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			bw.setErr(ErrInvalidBCD)
+			return 0
+		}
+	}
+	width := 2 * nbytes
+	if packed {
+		width--
+	}
+	if len(digits) > width {
+		bw.setErr(ErrOverflow)
+		return 0
+	}
+
+	nibbles := make([]byte, 0, 2*nbytes)
+	for i := len(digits); i < width; i++ {
+		nibbles = append(nibbles, 0)
+	}
+	for i := 0; i < len(digits); i++ {
+		nibbles = append(nibbles, digits[i]-'0')
+	}
+	if packed {
+		if neg {
+			nibbles = append(nibbles, 0x0D)
+		} else {
+			nibbles = append(nibbles, 0x0C)
+		}
+	}
+
+	data := make([]byte, nbytes)
+	for i := range data {
+		data[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return bw.writeBytes(data)
+}
+
+// WriteBCD writes v as nbytes of unsigned packed BCD.
+func (bw *Writer) WriteBCD(v uint64, nbytes int) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.writeDigits(strconv.FormatUint(v, 10), nbytes, false, false)
+}
+
+// WriteBCDString writes the decimal digits in s as nbytes of packed BCD, padded with leading zeros.
+func (bw *Writer) WriteBCDString(s string, nbytes int) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.writeDigits(s, nbytes, false, false)
+}
+
+// WritePackedDecimal writes v as nbytes of IBM packed decimal using the C and D sign nibbles.
+func (bw *Writer) WritePackedDecimal(v int64, nbytes int) int {
+	if bw.err != nil {
+		return 0
+	}
+	if v < 0 {
+		return bw.writeDigits(strconv.FormatUint(uint64(-(v+1))+1, 10), nbytes, true, true)
+	}
+	return bw.writeDigits(strconv.FormatUint(uint64(v), 10), nbytes, true, false)
+}
+
+// WritePackedDecimalString writes a decimal string with an optional sign as nbytes of packed decimal.
+func (bw *Writer) WritePackedDecimalString(s string, nbytes int) int {
+	if bw.err != nil {
+		return 0
+	}
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "" {
+		bw.setErr(ErrInvalidBCD)
+		return 0
+	}
+	return bw.writeDigits(s, nbytes, true, neg)
+}
@@ -0,0 +1,110 @@
+package binaryio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestBCD(t *testing.T) {
+	// -----------------------------
+	// Read
+	// -----------------------------
+	{
+		r := NewReader(bytes.NewReader([]byte{
+			0x12, 0x34, 0x56, // BCD 123456
+			0x00, 0x42, // BCD string "0042"
+			0x12, 0x3D, // packed -123
+			0x00, 0x12, 0x3C, // packed 123
+			0x00, 0x00, 0x0F, // packed string 0
+			0x98, 0x76, 0x54, 0x32, 0x10, 0x98, 0x76, 0x54, 0x32, 0x10, 0x9D, // packed string, 21 digits
+		}))
+		if v := r.ReadBCD(3); v != 123456 {
+			t.Fatalf("Invalid ReadBCD %d", v)
+		}
+		if v := r.ReadBCDString(2); v != "0042" {
+			t.Fatalf("Invalid ReadBCDString %s", v)
+		}
+		if v := r.ReadPackedDecimal(2); v != -123 {
+			t.Fatalf("Invalid ReadPackedDecimal %d", v)
+		}
+		if v := r.ReadPackedDecimal(3); v != 123 {
+			t.Fatalf("Invalid ReadPackedDecimal %d", v)
+		}
+		if v := r.ReadPackedDecimalString(3); v != "0" {
+			t.Fatalf("Invalid ReadPackedDecimalString %s", v)
+		}
+		if v := r.ReadPackedDecimalString(11); v != "-987654321098765432109" {
+			t.Fatalf("Invalid ReadPackedDecimalString %s", v)
+		}
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+	}
+	{
+		r := NewReader(bytes.NewReader([]byte{0x1A}))
+		r.ReadBCD(1)
+		if r.Err() != ErrInvalidBCD {
+			t.Fatalf("Invalid nibble must fail %v", r.Err())
+		}
+		r = NewReader(bytes.NewReader([]byte{0x12, 0x34}))
+		r.ReadPackedDecimal(2)
+		if r.Err() != ErrInvalidBCD {
+			t.Fatalf("Invalid sign nibble must fail %v", r.Err())
+		}
+		r = NewReader(bytes.NewReader(bytes.Repeat([]byte{0x99}, 10)))
+		r.ReadBCD(10)
+		if r.Err() != ErrOverflow {
+			t.Fatalf("ReadBCD must overflow %v", r.Err())
+		}
+	}
+	// -----------------------------
+	// Write
+	// -----------------------------
+	{
+		testFileName := "test.bin"
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw)
+		n := w.WriteBCD(123456, 4)
+		n += w.WriteBCDString("8901", 2)
+		n += w.WritePackedDecimal(-123, 2)
+		n += w.WritePackedDecimal(math.MinInt64, 10)
+		n += w.WritePackedDecimalString("+42", 2)
+		if w.Err() != nil {
+			t.Fatal(w.Err())
+		}
+		if n != 20 {
+			t.Fatalf("Invalid write size %d", n)
+		}
+		fw.Close()
+
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		if raw := r.ReadRaw(8); !bytes.Equal(raw, []byte{0x00, 0x12, 0x34, 0x56, 0x89, 0x01, 0x12, 0x3D}) {
+			t.Fatalf("Invalid BCD bytes % x", raw)
+		}
+		if v := r.ReadPackedDecimal(10); v != math.MinInt64 {
+			t.Fatalf("Invalid WritePackedDecimal %d", v)
+		}
+		if v := r.ReadPackedDecimalString(2); v != "42" {
+			t.Fatalf("Invalid WritePackedDecimalString %s", v)
+		}
+		fr.Close()
+
+		removeFile(testFileName, t)
+	}
+	{
+		w := NewWriter(failWriterAt{})
+		if w.WriteBCD(1000, 1) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("WriteBCD must overflow")
+		}
+		w = NewWriter(failWriterAt{})
+		if w.WriteBCDString("12a4", 2) != 0 || w.Err() != ErrInvalidBCD {
+			t.Fatalf("WriteBCDString must reject invalid digits")
+		}
+		w = NewWriter(failWriterAt{})
+		if w.WritePackedDecimal(100, 1) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("WritePackedDecimal must overflow")
+		}
+	}
+}
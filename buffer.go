@@ -0,0 +1,50 @@
+package binaryio
+
+import (
+	"errors"
+	"io"
+)
+
+// Buffer is a growable in-memory io.WriterAt that is also an io.ReaderAt.
+// Writes past the end grow it, zero-filling any gap.
+type Buffer []byte
+
+// WriteAt ...
+func (b *Buffer) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off > int64(^uint(0)>>1)-int64(len(p)) {
+		return 0, errors.New("binaryio: buffer offset too large")
+	}
+	if end := int(off) + len(p); end > len(*b) {
+		if end > cap(*b) {
+			grown := make([]byte, end, 2*end)
+			copy(grown, *b)
+			*b = grown
+		} else {
+			// clear bytes left in the capacity by an earlier truncation
+			n := len(*b)
+			*b = (*b)[:end]
+			for i := n; i < int(off); i++ {
+				(*b)[i] = 0
+			}
+		}
+	}
+	return copy((*b)[off:], p), nil
+}
+
+// ReadAt ...
+func (b Buffer) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,41 @@
+package binaryio
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	var buf Buffer
+	w := NewWriter(&buf)
+	w.SetOffset(2)
+	w.WriteU16(0x0102, BigEndian)
+	w.SetOffset(0)
+	w.WriteU8(0xFF)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	if !bytes.Equal(buf, []byte{0xFF, 0, 1, 2}) {
+		t.Fatalf("Invalid Buffer % x", []byte(buf))
+	}
+
+	r := NewReader(&buf)
+	r.SetOffset(2)
+	if v := r.ReadU16(BigEndian); v != 0x0102 || r.Err() != nil {
+		t.Fatalf("Invalid ReadU16 %x", v)
+	}
+	if r.ReadU8(); r.Err() != io.EOF {
+		t.Fatalf("Read past end must fail %v", r.Err())
+	}
+	if _, err := buf.WriteAt([]byte{0}, -1); err == nil {
+		t.Fatalf("WriteAt at a negative offset must fail")
+	}
+
+	// the gap after a truncation reads as zeros
+	buf = buf[:1]
+	buf.WriteAt([]byte{3}, 3)
+	if !bytes.Equal(buf, []byte{0xFF, 0, 0, 3}) {
+		t.Fatalf("Invalid Buffer after truncation % x", []byte(buf))
+	}
+}
@@ -0,0 +1,160 @@
+// Package cbor encodes and decodes CBOR (RFC 8949). Values map to and from Go
+// values, including tagged structs, big integers and times, and the Encoder
+// and Decoder also expose the individual data items, indefinite-length items
+// and tags. An Encoder in Canonical mode produces the core deterministic
+// encoding, and Decoder.Diagnose prints items in diagnostic notation.
+package cbor
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the cbor package.
+var (
+	ErrFormat     = errors.New("cbor: invalid format")
+	ErrType       = errors.New("cbor: type mismatch")
+	ErrRange      = errors.New("cbor: value out of range")
+	ErrPointer    = errors.New("cbor: Decode requires a non-nil pointer")
+	ErrDepth      = errors.New("cbor: nesting too deep")
+	ErrIndefinite = errors.New("cbor: indefinite length in canonical mode")
+)
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Additional information values.
+const (
+	aiUint8      = 24
+	aiUint16     = 25
+	aiUint32     = 26
+	aiUint64     = 27
+	aiIndefinite = 31
+
+	aiFloat16 = aiUint16
+	aiFloat32 = aiUint32
+	aiFloat64 = aiUint64
+)
+
+const breakCode = 0xFF
+
+// Tag numbers handled by the package.
+const (
+	TagDateTimeString = 0     // RFC 3339 text
+	TagEpochDateTime  = 1     // seconds since the epoch, integer or float
+	TagPosBignum      = 2     // unsigned big-endian byte string
+	TagNegBignum      = 3     // -1 minus the unsigned byte string
+	TagSelfDescribe   = 55799 // marks CBOR data, no semantics
+)
+
+// maxDepth limits the nesting of decoded and encoded values.
+const maxDepth = 10000
+
+// Simple is a simple value other than false, true and null.
+type Simple uint8
+
+// Undefined is the undefined simple value.
+const Undefined Simple = 23
+
+// Simple values with their own meaning.
+const (
+	simpleFalse = 20
+	simpleTrue  = 21
+	simpleNull  = 22
+)
+
+// Tag is a tagged data item whose tag number the package does not interpret.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	bigIntType = reflect.TypeOf(big.Int{})
+	tagType    = reflect.TypeOf(Tag{})
+	simpleType = reflect.TypeOf(Simple(0))
+)
+
+// field describes a struct field. Keys come from the cbor tag when present, as
+// in `cbor:"name,omitempty"`; with the keyasint option the key is an integer,
+// as in `cbor:"-1,keyasint"`. A "-" tag skips the field.
+type field struct {
+	name      string
+	index     int
+	omitEmpty bool
+	keyAsInt  bool
+	intKey    int64
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func structFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		tag := sf.Tag.Get("cbor")
+		if tag == "-" {
+			continue
+		}
+		f := field{name: sf.Name, index: i}
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			f.name = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "keyasint":
+				if n, err := strconv.ParseInt(f.name, 10, 64); err == nil {
+					f.keyAsInt, f.intKey = true, n
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return false
+}
@@ -0,0 +1,410 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// encode returns the encoding of v.
+func encode(t *testing.T, v interface{}, canonical bool) []byte {
+	var buf bio.Buffer
+	e := NewEncoder(&buf)
+	e.Canonical = canonical
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func bigInt(s string) *big.Int {
+	x, _ := new(big.Int).SetString(s, 10)
+	return x
+}
+
+// Examples from RFC 8949 Appendix A.
+var examples = []struct {
+	hex  string
+	diag string
+	v    interface{} // encoded in canonical mode when not nil
+}{
+	{"00", "0", 0},
+	{"17", "23", 23},
+	{"1818", "24", 24},
+	{"1903e8", "1000", 1000},
+	{"1a000f4240", "1000000", 1000000},
+	{"1b000000e8d4a51000", "1000000000000", int64(1000000000000)},
+	{"1bffffffffffffffff", "18446744073709551615", uint64(math.MaxUint64)},
+	{"c249010000000000000000", "2(h'010000000000000000')", bigInt("18446744073709551616")},
+	{"3bffffffffffffffff", "-18446744073709551616", bigInt("-18446744073709551616")},
+	{"c349010000000000000000", "3(h'010000000000000000')", bigInt("-18446744073709551617")},
+	{"20", "-1", -1},
+	{"3903e7", "-1000", -1000},
+	{"f90000", "0.0", 0.0},
+	{"f98000", "-0.0", math.Copysign(0, -1)},
+	{"f93c00", "1.0", 1.0},
+	{"fb3ff199999999999a", "1.1", 1.1},
+	{"f93e00", "1.5", 1.5},
+	{"f97bff", "65504.0", 65504.0},
+	{"fa47c35000", "100000.0", 100000.0},
+	{"fa7f7fffff", "3.4028234663852886e+38", 3.4028234663852886e+38},
+	{"fb7e37e43c8800759c", "1.0e+300", 1.0e+300},
+	{"f90001", "5.960464477539063e-8", 5.960464477539063e-8},
+	{"f90400", "6.103515625e-5", 0.00006103515625},
+	{"fbc010666666666666", "-4.1", -4.1},
+	{"f97c00", "Infinity", math.Inf(1)},
+	{"f97e00", "NaN", math.NaN()},
+	{"f9fc00", "-Infinity", math.Inf(-1)},
+	{"fa7f800000", "Infinity", nil},
+	{"fb7ff8000000000000", "NaN", nil},
+	{"f4", "false", false},
+	{"f5", "true", true},
+	{"f6", "null", nil},
+	{"f7", "undefined", Undefined},
+	{"f0", "simple(16)", Simple(16)},
+	{"f8ff", "simple(255)", Simple(255)},
+	{"c074323031332d30332d32315432303a30343a30305a", `0("2013-03-21T20:04:00Z")`, nil},
+	{"c11a514b67b0", "1(1363896240)", time.Unix(1363896240, 0)},
+	{"c1fb41d452d9ec200000", "1(1363896240.5)", nil},
+	{"d74401020304", "23(h'01020304')", Tag{23, []byte{1, 2, 3, 4}}},
+	{"d818456449455446", "24(h'6449455446')", Tag{24, []byte("dIETF")}},
+	{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", `32("http://www.example.com")`, Tag{32, "http://www.example.com"}},
+	{"40", "h''", []byte{}},
+	{"4401020304", "h'01020304'", []byte{1, 2, 3, 4}},
+	{"60", `""`, ""},
+	{"6449455446", `"IETF"`, "IETF"},
+	{"62225c", `"\"\\"`, "\"\\"},
+	{"62c3bc", `"ü"`, "ü"},
+	{"64f0908591", `"𐅑"`, "𐅑"},
+	{"80", "[]", []int{}},
+	{"8301820203820405", "[1, [2, 3], [4, 5]]", []interface{}{1, []int{2, 3}, []int{4, 5}}},
+	{"98190102030405060708090a0b0c0d0e0f101112131415161718181819", "[1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25]", nil},
+	{"a0", "{}", map[string]int{}},
+	{"a201020304", "{1: 2, 3: 4}", map[int]int{3: 4, 1: 2}},
+	{"a26161016162820203", `{"a": 1, "b": [2, 3]}`, map[string]interface{}{"b": []int{2, 3}, "a": 1}},
+	{"826161a161626163", `["a", {"b": "c"}]`, []interface{}{"a", map[string]string{"b": "c"}}},
+	{"5f42010243030405ff", "(_ h'0102', h'030405')", nil},
+	{"7f657374726561646d696e67ff", `(_ "strea", "ming")`, nil},
+	{"9fff", "[_ ]", nil},
+	{"9f018202039f0405ffff", "[_ 1, [2, 3], [_ 4, 5]]", nil},
+	{"bf61610161629f0203ffff", `{_ "a": 1, "b": [_ 2, 3]}`, nil},
+	{"bf6346756ef563416d7421ff", `{_ "Fun": true, "Amt": -2}`, nil},
+}
+
+func TestExamples(t *testing.T) {
+	for _, ex := range examples {
+		b := unhex(t, ex.hex)
+
+		d := NewDecoder(bytes.NewReader(b))
+		s, err := d.Diagnose()
+		if err != nil || s != ex.diag {
+			t.Fatalf("Invalid diagnostic notation of %s: %s %v", ex.hex, s, err)
+		}
+		if d.Offset() != int64(len(b)) {
+			t.Fatalf("Invalid offset after %s: %d", ex.hex, d.Offset())
+		}
+		d = NewDecoder(bytes.NewReader(b))
+		if err := d.Skip(); err != nil || d.Offset() != int64(len(b)) {
+			t.Fatalf("Invalid skip of %s: %v", ex.hex, err)
+		}
+		if _, err := NewDecoder(bytes.NewReader(b)).DecodeInterface(); err != nil {
+			t.Fatalf("Invalid decode of %s: %v", ex.hex, err)
+		}
+
+		if ex.v == nil {
+			continue
+		}
+		got := encode(t, ex.v, true)
+		if hex.EncodeToString(got) != ex.hex {
+			t.Fatalf("Invalid encoding of %v: %x", ex.v, got)
+		}
+	}
+
+	long := make([]int, 25)
+	for i := range long {
+		long[i] = i + 1
+	}
+	got := encode(t, long, false)
+	if hex.EncodeToString(got) != "98190102030405060708090a0b0c0d0e0f101112131415161718181819" {
+		t.Fatalf("Invalid array encoding %x", got)
+	}
+}
+
+func TestDecodeInterface(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"3903e7", int64(-1000)},
+		{"3bffffffffffffffff", bigInt("-18446744073709551616")},
+		{"c349010000000000000000", bigInt("-18446744073709551617")},
+		{"f93e00", 1.5},
+		{"f7", Undefined},
+		{"c11a514b67b0", time.Unix(1363896240, 0).UTC()},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 5e8).UTC()},
+		{"d9d9f7a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"bf6346756ef563416d7421ff", map[string]interface{}{"Fun": true, "Amt": int64(-2)}},
+		{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", Tag{32, "http://www.example.com"}},
+	}
+	for _, tt := range tests {
+		v, err := NewDecoder(bytes.NewReader(unhex(t, tt.hex))).DecodeInterface()
+		if err != nil {
+			t.Fatalf("Invalid decode of %s: %v", tt.hex, err)
+		}
+		if x, ok := tt.want.(*big.Int); ok {
+			if y, ok := v.(*big.Int); !ok || x.Cmp(y) != 0 {
+				t.Fatalf("Invalid bignum %s: %v", tt.hex, v)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.want) {
+			t.Fatalf("Invalid decode of %s: %#v", tt.hex, v)
+		}
+	}
+
+	d := NewDecoder(bytes.NewReader(unhex(t, "c074323031332d30332d32315432303a30343a30305a")))
+	if tm, err := d.ReadTime(); err != nil || !tm.Equal(time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)) {
+		t.Fatalf("Invalid date/time string %v %v", tm, err)
+	}
+}
+
+// coseKey is an EC2 COSE_Key (RFC 8152) with integer labels.
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	Crv int    `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+type record struct {
+	Name    string         `cbor:"name"`
+	Age     uint8          `cbor:"age,omitempty"`
+	Score   float64        `cbor:"score"`
+	Tags    []string       `cbor:"tags"`
+	Attrs   map[string]int `cbor:"attrs"`
+	When    time.Time      `cbor:"when"`
+	Big     *big.Int       `cbor:"big"`
+	Key     *coseKey       `cbor:"key"`
+	Any     interface{}    `cbor:"any"`
+	Skipped string         `cbor:"-"`
+	Grid    [2]int8        `cbor:"grid"`
+	Tag     Tag            `cbor:"tag"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := record{
+		Name:    "binaryio",
+		Score:   -0.25,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"x": -1, "y": 1 << 40},
+		When:    time.Date(2020, 9, 7, 12, 0, 0, 123456789, time.UTC),
+		Big:     bigInt("-340282366920938463463374607431768211456"),
+		Key:     &coseKey{Kty: 2, Alg: -7, Crv: 1, X: []byte{1, 2}, Y: []byte{3, 4}},
+		Any:     []interface{}{int64(1), "two", nil},
+		Skipped: "hidden",
+		Grid:    [2]int8{-1, 1},
+		Tag:     Tag{Number: 42, Content: "payload"},
+	}
+	want := src
+	want.Skipped = ""
+
+	for _, canonical := range []bool{false, true} {
+		b := encode(t, &src, canonical)
+		var dst record
+		d := NewDecoder(bytes.NewReader(b))
+		if err := d.Decode(&dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Big.Cmp(want.Big) != 0 {
+			t.Fatalf("Invalid bignum %v", dst.Big)
+		}
+		dst.Big = want.Big
+		if !reflect.DeepEqual(dst, want) {
+			t.Fatalf("Invalid round trip\n%+v\n%+v", dst, want)
+		}
+		if err := d.Decode(&dst); err != io.EOF {
+			t.Fatalf("Invalid end of input %v", err)
+		}
+	}
+
+	// COSE_Key labels sort by encoded bytes in canonical mode: 1, 3, -1, -2, -3
+	b := encode(t, src.Key, true)
+	d := NewDecoder(bytes.NewReader(b))
+	if s, _ := d.Diagnose(); s != "{1: 2, 3: -7, -1: 1, -2: h'0102', -3: h'0304'}" {
+		t.Fatalf("Invalid canonical COSE_Key %s", s)
+	}
+	b = encode(t, map[interface{}]int{"aa": 1, "b": 2, 100: 3, -1: 4, false: 5}, true)
+	if s, _ := NewDecoder(bytes.NewReader(b)).Diagnose(); s != `{100: 3, -1: 4, "b": 2, "aa": 1, false: 5}` {
+		t.Fatalf("Invalid canonical key order %s", s)
+	}
+}
+
+func TestIndefinite(t *testing.T) {
+	var buf bio.Buffer
+	e := NewEncoder(&buf)
+	e.WriteTag(TagSelfDescribe)
+	e.BeginMap()
+	e.WriteString("data")
+	e.BeginBytes()
+	e.WriteBytes([]byte{1, 2})
+	e.WriteBytes([]byte{3})
+	e.WriteBreak()
+	e.WriteString("list")
+	e.BeginArray()
+	for i := 0; i < 3; i++ {
+		e.WriteInt(int64(-i))
+	}
+	e.WriteBreak()
+	e.WriteString("name")
+	e.BeginString()
+	e.WriteString("bin")
+	e.WriteString("aryio")
+	e.WriteBreak()
+	e.WriteString("half")
+	e.WriteFloat16(0.333)
+	e.WriteBreak()
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(buf)
+	if s, _ := NewDecoder(bytes.NewReader(b)).Diagnose(); s != `55799({_ "data": (_ h'0102', h'03'), "list": [_ 0, -1, -2], "name": (_ "bin", "aryio"), "half": 0.3330078125})` {
+		t.Fatalf("Invalid indefinite items %s", s)
+	}
+
+	var v struct {
+		Data []byte
+		List []int
+		Name string
+		Half float32
+	}
+	if err := NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v.Data, []byte{1, 2, 3}) || !reflect.DeepEqual(v.List, []int{0, -1, -2}) || v.Name != "binaryio" || v.Half != 0.3330078125 {
+		t.Fatalf("Invalid indefinite decode %+v", v)
+	}
+
+	// streaming an indefinite-length array
+	d := NewDecoder(bytes.NewReader(unhex(t, "9f018202039f0405ffff")))
+	if n, err := d.ReadArrayHeader(); n != -1 || err != nil {
+		t.Fatalf("Invalid indefinite header %d %v", n, err)
+	}
+	count := 0
+	for !d.IsBreak() {
+		if err := d.Skip(); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if err := d.ReadBreak(); err != nil || count != 3 {
+		t.Fatalf("Invalid element count %d %v", count, err)
+	}
+
+	e = NewEncoder(&bio.Buffer{})
+	e.Canonical = true
+	if err := e.BeginArray(); err != ErrIndefinite {
+		t.Fatalf("Invalid canonical indefinite error %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want error
+	}{
+		{"6261", io.ErrUnexpectedEOF},      // truncated text
+		{"8201", io.ErrUnexpectedEOF},      // truncated array
+		{"1c", ErrFormat},                  // reserved additional information
+		{"ff", ErrFormat},                  // break outside an indefinite item
+		{"5f6161ff", ErrFormat},            // text chunk in a byte string
+		{"5f5f4100ffff", ErrFormat},        // nested indefinite chunk
+		{"62c328", ErrFormat},              // invalid UTF-8
+		{"f818", ErrFormat},                // two-byte simple value below 32
+		{"a14100f6", ErrType},              // byte string map key
+		{"c0f6", ErrType},                  // date/time string of the wrong type
+		{"9f", io.ErrUnexpectedEOF},        // unterminated array
+		{"c1fb7ff0000000000000", ErrRange}, // infinite epoch time
+	}
+	for _, tt := range tests {
+		if _, err := NewDecoder(bytes.NewReader(unhex(t, tt.hex))).DecodeInterface(); err != tt.want {
+			t.Fatalf("Invalid error for %s: %v", tt.hex, err)
+		}
+	}
+
+	var s string
+	if err := NewDecoder(bytes.NewReader([]byte{0x01})).Decode(&s); err != ErrType {
+		t.Fatalf("Invalid type error %v", err)
+	}
+	var i8 int8
+	if err := NewDecoder(bytes.NewReader(unhex(t, "18c8"))).Decode(&i8); err != ErrRange {
+		t.Fatalf("Invalid range error %v", err)
+	}
+	var u uint
+	if err := NewDecoder(bytes.NewReader([]byte{0x20})).Decode(&u); err != ErrRange {
+		t.Fatalf("Invalid negative error %v", err)
+	}
+	if err := NewDecoder(bytes.NewReader([]byte{0x01})).Decode(s); err != ErrPointer {
+		t.Fatalf("Invalid pointer error %v", err)
+	}
+	var m map[interface{}]interface{}
+	if err := NewDecoder(bytes.NewReader(unhex(t, "a1810102"))).Decode(&m); err != ErrType {
+		t.Fatalf("Invalid key type error %v", err)
+	}
+	// byte string headers claiming more data than the input holds
+	for _, h := range []string{"5a7fffffff", "5f5a7fffffff", "5f4101"} {
+		if _, err := NewDecoder(bytes.NewReader(unhex(t, h))).ReadBytes(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Invalid truncation error of %s: %v", h, err)
+		}
+	}
+	// a long run of self-describe tags must not exhaust the stack
+	var v interface{}
+	tags := append(bytes.Repeat([]byte{0xD9, 0xD9, 0xF7}, 1<<20), 0x01)
+	old := debug.SetMaxStack(1 << 20)
+	err := NewDecoder(bytes.NewReader(tags)).Decode(&v)
+	debug.SetMaxStack(old)
+	if err != nil || v != int64(1) {
+		t.Fatalf("Invalid self-describe run %v %v", v, err)
+	}
+	if err := NewDecoder(bytes.NewReader(tags[:3])).Decode(&v); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid self-describe truncation error %v", err)
+	}
+	deep := append(bytes.Repeat([]byte{0x81}, maxDepth+1), 0xF6)
+	if _, err := NewDecoder(bytes.NewReader(deep)).DecodeInterface(); err != ErrDepth {
+		t.Fatalf("Invalid depth error %v", err)
+	}
+	loop := []interface{}{nil}
+	loop[0] = loop
+	if err := NewEncoder(&bio.Buffer{}).Encode(loop); err != ErrDepth {
+		t.Fatalf("Invalid encode depth error %v", err)
+	}
+	if err := NewEncoder(&bio.Buffer{}).Encode(make(chan int)); err != ErrType {
+		t.Fatalf("Invalid encode type error %v", err)
+	}
+	if err := NewEncoder(&bio.Buffer{}).WriteSimple(24); err != ErrRange {
+		t.Fatalf("Invalid simple value error %v", err)
+	}
+}
@@ -0,0 +1,931 @@
+package cbor
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Decoder reads CBOR data items.
+type Decoder struct {
+	br    *bio.Reader
+	depth int
+}
+
+// NewDecoder returns a Decoder starting at offset 0 of r.
+func NewDecoder(r io.ReaderAt) *Decoder {
+	return &Decoder{br: bio.NewReader(r, bio.WithBufferSize(64<<10))}
+}
+
+// Offset returns the position of the next data item.
+func (d *Decoder) Offset() int64 {
+	return d.br.GetOffset()
+}
+
+// fail returns the reader error, reporting a truncated item as io.ErrUnexpectedEOF.
+func (d *Decoder) fail() error {
+	return more(d.br.Err())
+}
+
+// more reports the end of input inside an item as io.ErrUnexpectedEOF.
+func more(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// head is the initial byte of a data item and its argument. For floats the
+// argument holds the raw bits.
+type head struct {
+	major byte
+	ai    byte
+	arg   uint64
+}
+
+func (h head) indefinite() bool {
+	return h.ai == aiIndefinite
+}
+
+func (h head) isBreak() bool {
+	return h.major == majorSimple && h.ai == aiIndefinite
+}
+
+func (h head) isFloat() bool {
+	return h.major == majorSimple && h.ai >= aiFloat16 && h.ai <= aiFloat64
+}
+
+func (h head) float() float64 {
+	switch h.ai {
+	case aiFloat16:
+		return float64(bio.Float16frombits(uint16(h.arg)))
+	case aiFloat32:
+		return float64(math.Float32frombits(uint32(h.arg)))
+	}
+	return math.Float64frombits(h.arg)
+}
+
+// readHead reads the head of the next data item, or returns io.EOF at the end
+// of input.
+func (d *Decoder) readHead() (head, error) {
+	br := d.br
+	c := br.ReadU8()
+	if err := br.Err(); err != nil {
+		return head{}, err
+	}
+	h := head{major: c >> 5, ai: c & 0x1F}
+	switch {
+	case h.ai < aiUint8:
+		h.arg = uint64(h.ai)
+	case h.ai == aiUint8:
+		h.arg = uint64(br.ReadU8())
+		if h.major == majorSimple && h.arg < 32 && br.Err() == nil {
+			return head{}, ErrFormat
+		}
+	case h.ai == aiUint16:
+		h.arg = uint64(br.ReadU16(be))
+	case h.ai == aiUint32:
+		h.arg = uint64(br.ReadU32(be))
+	case h.ai == aiUint64:
+		h.arg = br.ReadU64(be)
+	case h.ai == aiIndefinite:
+		if h.major == majorUint || h.major == majorNegInt || h.major == majorTag {
+			return head{}, ErrFormat
+		}
+	default:
+		return head{}, ErrFormat
+	}
+	if br.Err() != nil {
+		return head{}, d.fail()
+	}
+	return h, nil
+}
+
+// peekHead returns the head of the next data item without consuming it.
+func (d *Decoder) peekHead() (head, error) {
+	off := d.br.GetOffset()
+	h, err := d.readHead()
+	if err == nil {
+		d.br.SetOffset(off)
+	}
+	return h, err
+}
+
+func (d *Decoder) raw(n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, ErrRange
+	}
+	if n == 0 {
+		return []byte{}, nil
+	}
+	// make sure the data exists before allocating for it; this also bounds
+	// indefinite-length strings by the input
+	if k, _ := d.br.ReadAt(make([]byte, 1), d.br.GetOffset()+int64(n)-1); k != 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.br.ReadRaw(n)
+	if d.br.Err() != nil {
+		return nil, d.fail()
+	}
+	return b, nil
+}
+
+func (d *Decoder) enter() error {
+	if d.depth >= maxDepth {
+		return ErrDepth
+	}
+	d.depth++
+	return nil
+}
+
+// IsNil reports whether the next item is null or undefined without consuming it.
+func (d *Decoder) IsNil() bool {
+	h, err := d.peekHead()
+	return err == nil && h.major == majorSimple && (h.arg == simpleNull || h.arg == uint64(Undefined)) && h.ai < aiUint8
+}
+
+// IsBreak reports whether the next item is the break ending an
+// indefinite-length item, without consuming it.
+func (d *Decoder) IsBreak() bool {
+	h, err := d.peekHead()
+	return err == nil && h.isBreak()
+}
+
+// ReadBreak reads the break ending an indefinite-length item.
+func (d *Decoder) ReadBreak() error {
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+	if !h.isBreak() {
+		return ErrType
+	}
+	return nil
+}
+
+// ReadNil reads null.
+func (d *Decoder) ReadNil() error {
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+	if h.major != majorSimple || h.ai != simpleNull {
+		return ErrType
+	}
+	return nil
+}
+
+// ReadBool reads true or false.
+func (d *Decoder) ReadBool() (bool, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return false, err
+	}
+	if h.major == majorSimple && h.ai < aiUint8 {
+		switch h.arg {
+		case simpleTrue:
+			return true, nil
+		case simpleFalse:
+			return false, nil
+		}
+	}
+	return false, ErrType
+}
+
+// ReadSimple reads any simple value, including false, true, null and undefined.
+func (d *Decoder) ReadSimple() (Simple, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if h.major != majorSimple || h.ai > aiUint8 {
+		return 0, ErrType
+	}
+	return Simple(h.arg), nil
+}
+
+// ReadUint reads a non-negative integer.
+func (d *Decoder) ReadUint() (uint64, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	switch h.major {
+	case majorUint:
+		return h.arg, nil
+	case majorNegInt:
+		return 0, ErrRange
+	}
+	return 0, ErrType
+}
+
+// ReadInt reads an integer that fits an int64.
+func (d *Decoder) ReadInt() (int64, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if h.major != majorUint && h.major != majorNegInt {
+		return 0, ErrType
+	}
+	if h.arg > math.MaxInt64 {
+		return 0, ErrRange
+	}
+	if h.major == majorNegInt {
+		return -1 - int64(h.arg), nil
+	}
+	return int64(h.arg), nil
+}
+
+// ReadBigInt reads an integer or a bignum.
+func (d *Decoder) ReadBigInt() (*big.Int, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	x := new(big.Int)
+	switch {
+	case h.major == majorUint:
+		return x.SetUint64(h.arg), nil
+	case h.major == majorNegInt:
+		x.SetUint64(h.arg)
+	case h.major == majorTag && (h.arg == TagPosBignum || h.arg == TagNegBignum):
+		x, err := d.bignum(h.arg)
+		return x, more(err)
+	default:
+		return nil, ErrType
+	}
+	return x.Sub(x.Neg(x), big.NewInt(1)), nil
+}
+
+// bignum reads the byte string content of a bignum tag.
+func (d *Decoder) bignum(tag uint64) (*big.Int, error) {
+	b, err := d.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	x := new(big.Int).SetBytes(b)
+	if tag == TagNegBignum {
+		x.Sub(x.Neg(x), big.NewInt(1))
+	}
+	return x, nil
+}
+
+// ReadFloat reads a float of any precision, or an integer, as a float64.
+func (d *Decoder) ReadFloat() (float64, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case h.isFloat():
+		return h.float(), nil
+	case h.major == majorUint:
+		return float64(h.arg), nil
+	case h.major == majorNegInt:
+		return -1 - float64(h.arg), nil
+	}
+	return 0, ErrType
+}
+
+// readString reads a definite or indefinite-length byte or text string.
+func (d *Decoder) readString(major byte) ([]byte, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if h.major != major {
+		return nil, ErrType
+	}
+	return d.stringData(h)
+}
+
+// stringData reads the data of the string whose head h was read, joining the
+// chunks of an indefinite-length string.
+func (d *Decoder) stringData(h head) ([]byte, error) {
+	if !h.indefinite() {
+		return d.raw(h.arg)
+	}
+	out := []byte{}
+	for {
+		c, err := d.readHead()
+		if err != nil {
+			return nil, more(err)
+		}
+		if c.isBreak() {
+			return out, nil
+		}
+		if c.major != h.major || c.indefinite() {
+			return nil, ErrFormat
+		}
+		b, err := d.raw(c.arg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+}
+
+// text reads the data of the text string whose head h was read.
+func (d *Decoder) text(h head) (string, error) {
+	b, err := d.stringData(h)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", ErrFormat
+	}
+	return string(b), nil
+}
+
+// ReadBytes reads a byte string, joining the chunks of an indefinite-length one.
+func (d *Decoder) ReadBytes() ([]byte, error) {
+	return d.readString(majorBytes)
+}
+
+// ReadString reads a text string, joining the chunks of an indefinite-length
+// one. Invalid UTF-8 is reported as ErrFormat.
+func (d *Decoder) ReadString() (string, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return "", err
+	}
+	if h.major != majorText {
+		return "", ErrType
+	}
+	return d.text(h)
+}
+
+func (d *Decoder) readHeader(major byte) (int, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if h.major != major {
+		return 0, ErrType
+	}
+	if h.indefinite() {
+		return -1, nil
+	}
+	if h.arg > math.MaxInt32 {
+		return 0, ErrRange
+	}
+	return int(h.arg), nil
+}
+
+// ReadArrayHeader reads the head of an array and returns its length, or -1
+// for an indefinite-length array whose elements are followed by a break. The
+// elements can then be decoded one at a time.
+func (d *Decoder) ReadArrayHeader() (int, error) {
+	return d.readHeader(majorArray)
+}
+
+// ReadMapHeader reads the head of a map and returns its number of pairs, or -1
+// for an indefinite-length map whose pairs are followed by a break.
+func (d *Decoder) ReadMapHeader() (int, error) {
+	return d.readHeader(majorMap)
+}
+
+// ReadTag reads a tag number; the tagged item follows.
+func (d *Decoder) ReadTag() (uint64, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if h.major != majorTag {
+		return 0, ErrType
+	}
+	return h.arg, nil
+}
+
+// ReadTime reads a date/time string (tag 0) or an epoch date/time (tag 1).
+// Epoch times are returned in UTC.
+func (d *Decoder) ReadTime() (time.Time, error) {
+	tag, err := d.ReadTag()
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := d.readTime(tag)
+	return t, more(err)
+}
+
+func (d *Decoder) readTime(tag uint64) (time.Time, error) {
+	switch tag {
+	case TagDateTimeString:
+		s, err := d.ReadString()
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, ErrFormat
+		}
+		return t, nil
+	case TagEpochDateTime:
+		h, err := d.readHead()
+		if err != nil {
+			return time.Time{}, err
+		}
+		switch {
+		case h.major == majorUint || h.major == majorNegInt:
+			if h.arg > math.MaxInt64 {
+				return time.Time{}, ErrRange
+			}
+			sec := int64(h.arg)
+			if h.major == majorNegInt {
+				sec = -1 - sec
+			}
+			return time.Unix(sec, 0).UTC(), nil
+		case h.isFloat():
+			f := h.float()
+			if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > 1<<62 {
+				return time.Time{}, ErrRange
+			}
+			sec := math.Floor(f)
+			nsec := math.Round((f - sec) * 1e9)
+			return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+		}
+		return time.Time{}, ErrType
+	}
+	return time.Time{}, ErrType
+}
+
+// Skip consumes the next data item, including nested and tagged items.
+func (d *Decoder) Skip() error {
+	_, err := d.decodeInterface(true)
+	return err
+}
+
+// DecodeInterface reads the next data item as nil, bool, int64 (uint64 above
+// MaxInt64, *big.Int below MinInt64), float64, string, []byte,
+// []interface{}, map[string]interface{} (map[interface{}]interface{} for
+// non-string keys), time.Time for tags 0 and 1, *big.Int for bignums, Simple
+// for undefined and other simple values, or Tag for other tags. The
+// self-describe tag is dropped.
+func (d *Decoder) DecodeInterface() (interface{}, error) {
+	return d.decodeInterface(false)
+}
+
+func (d *Decoder) decodeInterface(skip bool) (interface{}, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	switch h.major {
+	case majorUint:
+		if h.arg > math.MaxInt64 {
+			return h.arg, nil
+		}
+		return int64(h.arg), nil
+	case majorNegInt:
+		if h.arg > math.MaxInt64 {
+			x := new(big.Int).SetUint64(h.arg)
+			return x.Sub(x.Neg(x), big.NewInt(1)), nil
+		}
+		return -1 - int64(h.arg), nil
+	case majorSimple:
+		switch {
+		case h.isFloat():
+			return h.float(), nil
+		case h.isBreak():
+			return nil, ErrFormat
+		case h.arg == simpleFalse:
+			return false, nil
+		case h.arg == simpleTrue:
+			return true, nil
+		case h.arg == simpleNull:
+			return nil, nil
+		}
+		return Simple(h.arg), nil
+	}
+
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	switch h.major {
+	case majorBytes:
+		return d.stringData(h)
+	case majorText:
+		return d.text(h)
+	case majorArray, majorMap:
+		n := -1
+		if !h.indefinite() {
+			if h.arg > math.MaxInt32 {
+				return nil, ErrRange
+			}
+			n = int(h.arg)
+		}
+		if h.major == majorArray {
+			return d.interfaceArray(n, skip)
+		}
+		return d.interfaceMap(n, skip)
+	}
+
+	// tags
+	switch h.arg {
+	case TagDateTimeString, TagEpochDateTime:
+		if !skip {
+			t, err := d.readTime(h.arg)
+			return t, more(err)
+		}
+	case TagPosBignum, TagNegBignum:
+		if !skip {
+			x, err := d.bignum(h.arg)
+			return x, more(err)
+		}
+	case TagSelfDescribe:
+		v, err := d.decodeInterface(skip)
+		return v, more(err)
+	}
+	v, err := d.decodeInterface(skip)
+	if err != nil {
+		return nil, more(err)
+	}
+	return Tag{Number: h.arg, Content: v}, nil
+}
+
+func minInt(n uint64, max int) int {
+	if n < uint64(max) {
+		return int(n)
+	}
+	return max
+}
+
+func (d *Decoder) interfaceArray(n int, skip bool) (interface{}, error) {
+	var a []interface{}
+	if !skip && n > 0 {
+		a = make([]interface{}, 0, minInt(uint64(n), 1<<12))
+	}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		v, err := d.decodeInterface(skip)
+		if err != nil {
+			return nil, more(err)
+		}
+		if !skip {
+			a = append(a, v)
+		}
+	}
+	if a == nil && !skip {
+		a = []interface{}{}
+	}
+	return a, nil
+}
+
+func (d *Decoder) interfaceMap(n int, skip bool) (interface{}, error) {
+	var keys, vals []interface{}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		k, err := d.decodeInterface(skip)
+		if err != nil {
+			return nil, more(err)
+		}
+		v, err := d.decodeInterface(skip)
+		if err != nil {
+			return nil, more(err)
+		}
+		if !skip {
+			keys, vals = append(keys, k), append(vals, v)
+		}
+	}
+	if skip {
+		return nil, nil
+	}
+	return buildMap(keys, vals)
+}
+
+// hashable reports whether k can be used as a map key.
+func hashable(k interface{}) bool {
+	switch k := k.(type) {
+	case nil:
+		return true
+	case Tag:
+		return hashable(k.Content)
+	}
+	return reflect.TypeOf(k).Comparable()
+}
+
+// buildMap returns a map[string]interface{} when all keys are strings.
+func buildMap(keys, vals []interface{}) (interface{}, error) {
+	strKeys := true
+	for _, k := range keys {
+		if _, ok := k.(string); !ok {
+			strKeys = false
+			break
+		}
+	}
+	if strKeys {
+		m := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			m[k.(string)] = vals[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, len(keys))
+	for i, k := range keys {
+		if !hashable(k) {
+			return nil, ErrType
+		}
+		m[k] = vals[i]
+	}
+	return m, nil
+}
+
+// Decode reads the next data item into the value pointed to by v. Struct
+// fields are matched by name, exactly or case-insensitively, or by integer
+// key; unknown keys are skipped. Decode returns io.EOF when no item remains.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrPointer
+	}
+	return d.decodeValue(rv.Elem())
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	h, err := d.peekHead()
+	if err != nil {
+		return err
+	}
+	// self-describe tags carry no meaning; drop them without recursing
+	for h.major == majorTag && h.arg == TagSelfDescribe {
+		d.readHead()
+		if h, err = d.peekHead(); err != nil {
+			return more(err)
+		}
+	}
+	if v.Type() == simpleType {
+		s, err := d.ReadSimple()
+		v.SetUint(uint64(s))
+		return err
+	}
+	if h.major == majorSimple && h.ai < aiUint8 && (h.arg == simpleNull || h.arg == uint64(Undefined)) {
+		d.readHead()
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		t, err := d.ReadTime()
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	case bigIntType:
+		x, err := d.ReadBigInt()
+		if err == nil {
+			v.Set(reflect.ValueOf(x).Elem())
+		}
+		return err
+	case tagType:
+		n, err := d.ReadTag()
+		if err != nil {
+			return err
+		}
+		c, err := d.DecodeInterface()
+		if err == nil {
+			v.Set(reflect.ValueOf(Tag{Number: n, Content: c}))
+		}
+		return more(err)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return ErrType
+		}
+		x, err := d.DecodeInterface()
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		b, err := d.ReadBool()
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := d.ReadInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return ErrRange
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := d.ReadUint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(u) {
+			return ErrRange
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := d.ReadFloat()
+		v.SetFloat(f)
+		return err
+	case reflect.String:
+		s, err := d.ReadString()
+		v.SetString(s)
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && h.major == majorBytes {
+			b, err := d.ReadBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		return d.decodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && h.major == majorBytes {
+			b, err := d.ReadBytes()
+			if err != nil {
+				return err
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		return d.decodeArray(v)
+	case reflect.Map:
+		return d.decodeMap(v)
+	case reflect.Struct:
+		return d.decodeStruct(v)
+	}
+	return ErrType
+}
+
+// next reports whether another element of a container of length n follows
+// element i, consuming the break of an indefinite-length container.
+func (d *Decoder) next(n, i int) (bool, error) {
+	if n >= 0 {
+		return i < n, nil
+	}
+	h, err := d.peekHead()
+	if err != nil {
+		return false, more(err)
+	}
+	if h.isBreak() {
+		d.readHead()
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *Decoder) decodeArray(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Slice {
+		size := 0
+		if n > 0 {
+			size = minInt(uint64(n), 1<<12)
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 0, size))
+	}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil || !ok {
+			return err
+		}
+		if v.Kind() == reflect.Array {
+			if i >= v.Len() {
+				err = d.Skip()
+			} else {
+				err = d.decodeValue(v.Index(i))
+			}
+			if err != nil {
+				return more(err)
+			}
+			continue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decodeValue(elem); err != nil {
+			return more(err)
+		}
+		v.Set(reflect.Append(v, elem))
+	}
+}
+
+func (d *Decoder) decodeMap(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil || !ok {
+			return err
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := d.decodeValue(key); err != nil {
+			return more(err)
+		}
+		if key.Kind() == reflect.Interface && !hashable(key.Interface()) {
+			return ErrType
+		}
+		val := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decodeValue(val); err != nil {
+			return more(err)
+		}
+		v.SetMapIndex(key, val)
+	}
+}
+
+func (d *Decoder) decodeStruct(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	fields := structFields(v.Type())
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil || !ok {
+			return err
+		}
+		key, err := d.DecodeInterface()
+		if err != nil {
+			return more(err)
+		}
+		f := findField(fields, key)
+		if f == nil {
+			err = d.Skip()
+		} else {
+			err = d.decodeValue(v.Field(f.index))
+		}
+		if err != nil {
+			return more(err)
+		}
+	}
+}
+
+func findField(fields []field, key interface{}) *field {
+	switch key := key.(type) {
+	case int64:
+		for i := range fields {
+			if fields[i].keyAsInt && fields[i].intKey == key {
+				return &fields[i]
+			}
+		}
+	case string:
+		for i := range fields {
+			if !fields[i].keyAsInt && fields[i].name == key {
+				return &fields[i]
+			}
+		}
+		for i := range fields {
+			if !fields[i].keyAsInt && strings.EqualFold(fields[i].name, key) {
+				return &fields[i]
+			}
+		}
+	}
+	return nil
+}
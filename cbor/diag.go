@@ -0,0 +1,200 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diagnose reads the next data item and returns it in the diagnostic
+// notation of RFC 8949 section 8, such as [1, {"a": h'00'}, 1(0)].
+// Indefinite-length items are marked with an underscore, as in [_ 1, 2].
+func (d *Decoder) Diagnose() (string, error) {
+	var sb strings.Builder
+	if err := d.diagnose(&sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (d *Decoder) diagnose(sb *strings.Builder) error {
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+	switch h.major {
+	case majorUint:
+		sb.WriteString(strconv.FormatUint(h.arg, 10))
+		return nil
+	case majorNegInt:
+		if h.arg == math.MaxUint64 {
+			sb.WriteString("-18446744073709551616")
+		} else {
+			sb.WriteString("-" + strconv.FormatUint(h.arg+1, 10))
+		}
+		return nil
+	case majorSimple:
+		return diagSimple(sb, h)
+	}
+
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	switch h.major {
+	case majorBytes, majorText:
+		if !h.indefinite() {
+			return d.diagString(sb, h)
+		}
+		sb.WriteString("(_ ")
+		for i := 0; ; i++ {
+			c, err := d.readHead()
+			if err != nil {
+				return more(err)
+			}
+			if c.isBreak() {
+				break
+			}
+			if c.major != h.major || c.indefinite() {
+				return ErrFormat
+			}
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := d.diagString(sb, c); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(")")
+		return nil
+	case majorArray, majorMap:
+		open, close := "[", "]"
+		if h.major == majorMap {
+			open, close = "{", "}"
+		}
+		sb.WriteString(open)
+		n := -1
+		if h.indefinite() {
+			sb.WriteString("_ ")
+		} else if h.arg > math.MaxInt32 {
+			return ErrRange
+		} else {
+			n = int(h.arg)
+		}
+		for i := 0; ; i++ {
+			ok, err := d.next(n, i)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := d.diagnose(sb); err != nil {
+				return more(err)
+			}
+			if h.major == majorMap {
+				sb.WriteString(": ")
+				if err := d.diagnose(sb); err != nil {
+					return more(err)
+				}
+			}
+		}
+		sb.WriteString(close)
+		return nil
+	}
+
+	// tags
+	sb.WriteString(strconv.FormatUint(h.arg, 10) + "(")
+	if err := d.diagnose(sb); err != nil {
+		return more(err)
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+// diagString writes the definite-length string whose head h was read.
+func (d *Decoder) diagString(sb *strings.Builder, h head) error {
+	b, err := d.raw(h.arg)
+	if err != nil {
+		return err
+	}
+	if h.major == majorBytes {
+		sb.WriteString("h'" + hex.EncodeToString(b) + "'")
+		return nil
+	}
+	if !utf8.Valid(b) {
+		return ErrFormat
+	}
+	sb.WriteByte('"')
+	for _, r := range string(b) {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7F:
+			sb.WriteString(`\u`)
+			s := strconv.FormatInt(int64(r), 16)
+			sb.WriteString(strings.Repeat("0", 4-len(s)) + s)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return nil
+}
+
+func diagSimple(sb *strings.Builder, h head) error {
+	switch {
+	case h.isBreak():
+		return ErrFormat
+	case h.isFloat():
+		sb.WriteString(formatFloat(h.float()))
+	case h.arg == simpleFalse:
+		sb.WriteString("false")
+	case h.arg == simpleTrue:
+		sb.WriteString("true")
+	case h.arg == simpleNull:
+		sb.WriteString("null")
+	case h.arg == uint64(Undefined):
+		sb.WriteString("undefined")
+	default:
+		sb.WriteString("simple(" + strconv.FormatUint(h.arg, 10) + ")")
+	}
+	return nil
+}
+
+// formatFloat formats v in its shortest form so that it always reads as a
+// float, as in 1.0 and 1.0e+300. Exponents are used below 1e-4 and from 1e16.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	format := byte('f')
+	if a := math.Abs(v); a != 0 && (a < 1e-4 || a >= 1e16) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(v, format, -1, 64)
+	mant, exp := s, ""
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		mant, exp = s[:i], s[i:]
+		// drop the leading zero Go puts in one-digit exponents
+		if len(exp) == 4 && exp[2] == '0' {
+			exp = exp[:2] + exp[3:]
+		}
+	}
+	if !strings.Contains(mant, ".") {
+		mant += ".0"
+	}
+	return mant + exp
+}
@@ -0,0 +1,460 @@
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+var be = bio.BigEndian
+
+// Encoder writes CBOR data items.
+//
+// When Canonical is set the Encoder produces the core deterministic encoding
+// of RFC 8949 section 4.2.1: floats use the shortest form that preserves their
+// value, map keys are sorted by their encoded bytes and indefinite-length
+// items are rejected with ErrIndefinite. Integers and lengths always use their
+// shortest form.
+type Encoder struct {
+	Canonical bool
+	w         *bio.Writer
+	depth     int
+}
+
+// NewEncoder returns an Encoder starting at offset 0 of w. Call Flush when done.
+func NewEncoder(w io.WriterAt) *Encoder {
+	return &Encoder{w: bio.NewWriter(w, bio.WithBufferSize(64<<10))}
+}
+
+// Flush writes buffered data.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Offset returns the number of bytes written so far.
+func (e *Encoder) Offset() int64 {
+	return e.w.GetOffset()
+}
+
+// writeHead writes the initial byte of a major type with argument n in its
+// shortest form.
+func (e *Encoder) writeHead(major byte, n uint64) error {
+	w := e.w
+	major <<= 5
+	switch {
+	case n < aiUint8:
+		w.WriteU8(major | byte(n))
+	case n <= math.MaxUint8:
+		w.WriteU8(major | aiUint8)
+		w.WriteU8(uint8(n))
+	case n <= math.MaxUint16:
+		w.WriteU8(major | aiUint16)
+		w.WriteU16(uint16(n), be)
+	case n <= math.MaxUint32:
+		w.WriteU8(major | aiUint32)
+		w.WriteU32(uint32(n), be)
+	default:
+		w.WriteU8(major | aiUint64)
+		w.WriteU64(n, be)
+	}
+	return w.Err()
+}
+
+// WriteUint writes an unsigned integer.
+func (e *Encoder) WriteUint(v uint64) error {
+	return e.writeHead(majorUint, v)
+}
+
+// WriteInt writes an integer.
+func (e *Encoder) WriteInt(v int64) error {
+	if v >= 0 {
+		return e.writeHead(majorUint, uint64(v))
+	}
+	return e.writeHead(majorNegInt, uint64(-1-v))
+}
+
+// WriteBigInt writes x as an integer when it fits 64 bits and as a bignum
+// otherwise.
+func (e *Encoder) WriteBigInt(x *big.Int) error {
+	if x.Sign() >= 0 {
+		if x.IsUint64() {
+			return e.writeHead(majorUint, x.Uint64())
+		}
+		if err := e.WriteTag(TagPosBignum); err != nil {
+			return err
+		}
+		return e.WriteBytes(x.Bytes())
+	}
+	n := new(big.Int).Neg(x)
+	n.Sub(n, big.NewInt(1))
+	if n.IsUint64() {
+		return e.writeHead(majorNegInt, n.Uint64())
+	}
+	if err := e.WriteTag(TagNegBignum); err != nil {
+		return err
+	}
+	return e.WriteBytes(n.Bytes())
+}
+
+// WriteBool writes true or false.
+func (e *Encoder) WriteBool(b bool) error {
+	if b {
+		return e.writeHead(majorSimple, simpleTrue)
+	}
+	return e.writeHead(majorSimple, simpleFalse)
+}
+
+// WriteNil writes null.
+func (e *Encoder) WriteNil() error {
+	return e.writeHead(majorSimple, simpleNull)
+}
+
+// WriteSimple writes a simple value. Values 24 to 31 are reserved.
+func (e *Encoder) WriteSimple(v Simple) error {
+	if v >= aiUint8 && v < 32 {
+		return ErrRange
+	}
+	return e.writeHead(majorSimple, uint64(v))
+}
+
+// WriteFloat16 writes v as a half precision float, rounding to nearest.
+func (e *Encoder) WriteFloat16(v float32) error {
+	e.w.WriteU8(majorSimple<<5 | aiFloat16)
+	e.w.WriteF16(v, be)
+	return e.w.Err()
+}
+
+// WriteFloat32 writes a single precision float.
+func (e *Encoder) WriteFloat32(v float32) error {
+	e.w.WriteU8(majorSimple<<5 | aiFloat32)
+	e.w.WriteF32(v, be)
+	return e.w.Err()
+}
+
+// WriteFloat64 writes a double precision float.
+func (e *Encoder) WriteFloat64(v float64) error {
+	e.w.WriteU8(majorSimple<<5 | aiFloat64)
+	e.w.WriteF64(v, be)
+	return e.w.Err()
+}
+
+// WriteFloat writes v in the shortest float form that preserves its value.
+// NaNs are written as the half precision quiet NaN.
+func (e *Encoder) WriteFloat(v float64) error {
+	if math.IsNaN(v) {
+		e.w.WriteU8(majorSimple<<5 | aiFloat16)
+		e.w.WriteU16(0x7E00, be)
+		return e.w.Err()
+	}
+	f := float32(v)
+	if float64(f) != v {
+		return e.WriteFloat64(v)
+	}
+	if h := bio.Float16frombits(bio.Float16bits(f)); h == f {
+		return e.WriteFloat16(f)
+	}
+	return e.WriteFloat32(f)
+}
+
+// WriteBytes writes a byte string.
+func (e *Encoder) WriteBytes(b []byte) error {
+	if err := e.writeHead(majorBytes, uint64(len(b))); err != nil {
+		return err
+	}
+	e.w.WriteRaw(b)
+	return e.w.Err()
+}
+
+// WriteString writes a text string.
+func (e *Encoder) WriteString(s string) error {
+	if err := e.writeHead(majorText, uint64(len(s))); err != nil {
+		return err
+	}
+	e.w.WriteRaw([]byte(s))
+	return e.w.Err()
+}
+
+// WriteArrayHeader starts an array of n elements, which are written next.
+func (e *Encoder) WriteArrayHeader(n int) error {
+	if n < 0 {
+		return ErrRange
+	}
+	return e.writeHead(majorArray, uint64(n))
+}
+
+// WriteMapHeader starts a map of n key-value pairs, which are written next.
+func (e *Encoder) WriteMapHeader(n int) error {
+	if n < 0 {
+		return ErrRange
+	}
+	return e.writeHead(majorMap, uint64(n))
+}
+
+// WriteTag writes a tag number; the tagged item is written next.
+func (e *Encoder) WriteTag(n uint64) error {
+	return e.writeHead(majorTag, n)
+}
+
+func (e *Encoder) beginIndefinite(major byte) error {
+	if e.Canonical {
+		return ErrIndefinite
+	}
+	e.w.WriteU8(major<<5 | aiIndefinite)
+	return e.w.Err()
+}
+
+// BeginArray starts an indefinite-length array. Write the elements and then
+// call WriteBreak.
+func (e *Encoder) BeginArray() error {
+	return e.beginIndefinite(majorArray)
+}
+
+// BeginMap starts an indefinite-length map. Write the keys and values and
+// then call WriteBreak.
+func (e *Encoder) BeginMap() error {
+	return e.beginIndefinite(majorMap)
+}
+
+// BeginBytes starts an indefinite-length byte string. Write the chunks with
+// WriteBytes and then call WriteBreak.
+func (e *Encoder) BeginBytes() error {
+	return e.beginIndefinite(majorBytes)
+}
+
+// BeginString starts an indefinite-length text string. Write the chunks with
+// WriteString and then call WriteBreak.
+func (e *Encoder) BeginString() error {
+	return e.beginIndefinite(majorText)
+}
+
+// WriteBreak ends an indefinite-length item.
+func (e *Encoder) WriteBreak() error {
+	e.w.WriteU8(breakCode)
+	return e.w.Err()
+}
+
+// WriteTime writes t as an epoch date/time (tag 1) with integer seconds when
+// it has no fractional second, and as an RFC 3339 date/time string (tag 0) in
+// UTC otherwise, which keeps nanoseconds.
+func (e *Encoder) WriteTime(t time.Time) error {
+	if t.Nanosecond() == 0 {
+		if err := e.WriteTag(TagEpochDateTime); err != nil {
+			return err
+		}
+		return e.WriteInt(t.Unix())
+	}
+	if err := e.WriteTag(TagDateTimeString); err != nil {
+		return err
+	}
+	return e.WriteString(t.UTC().Format(time.RFC3339Nano))
+}
+
+// Encode writes v. Structs are written as maps keyed by field name, or by
+// integer with the keyasint option. Map keys of string and integer kinds are
+// sorted; in Canonical mode all keys are sorted by their encoded bytes.
+func (e *Encoder) Encode(v interface{}) error {
+	return e.encodeValue(reflect.ValueOf(v))
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	if e.depth >= maxDepth {
+		return ErrDepth
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	if !v.IsValid() {
+		return e.WriteNil()
+	}
+	switch v.Type() {
+	case timeType:
+		return e.WriteTime(v.Interface().(time.Time))
+	case bigIntType:
+		x := v.Interface().(big.Int)
+		return e.WriteBigInt(&x)
+	case tagType:
+		t := v.Interface().(Tag)
+		if err := e.WriteTag(t.Number); err != nil {
+			return err
+		}
+		return e.encodeValue(reflect.ValueOf(t.Content))
+	case simpleType:
+		return e.WriteSimple(Simple(v.Uint()))
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		return e.encodeValue(v.Elem())
+	case reflect.Bool:
+		return e.WriteBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.WriteInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.WriteUint(v.Uint())
+	case reflect.Float32:
+		if e.Canonical {
+			return e.WriteFloat(v.Float())
+		}
+		return e.WriteFloat32(float32(v.Float()))
+	case reflect.Float64:
+		if e.Canonical {
+			return e.WriteFloat(v.Float())
+		}
+		return e.WriteFloat64(v.Float())
+	case reflect.String:
+		return e.WriteString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.WriteBytes(v.Bytes())
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return e.WriteBytes(b)
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	}
+	return ErrType
+}
+
+func (e *Encoder) encodeArray(v reflect.Value) error {
+	if err := e.WriteArrayHeader(v.Len()); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encodeValue(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pair is a map entry with its key already encoded, for canonical ordering.
+type pair struct {
+	key []byte
+	val reflect.Value
+}
+
+// encodeKey returns the canonical encoding of the key written by fn.
+func encodeKey(fn func(k *Encoder) error) ([]byte, error) {
+	var buf bio.Buffer
+	k := &Encoder{Canonical: true, w: bio.NewWriter(&buf)}
+	if err := fn(k); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writePairs writes a map header and the entries sorted by encoded key.
+func (e *Encoder) writePairs(pairs []pair) error {
+	sort.Slice(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].key, pairs[j].key) < 0 })
+	if err := e.WriteMapHeader(len(pairs)); err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		e.w.WriteRaw(p.key)
+		if err := e.w.Err(); err != nil {
+			return err
+		}
+		if err := e.encodeValue(p.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(v reflect.Value) error {
+	keys := v.MapKeys()
+	if e.Canonical {
+		pairs := make([]pair, len(keys))
+		for i, k := range keys {
+			b, err := encodeKey(func(ke *Encoder) error { return ke.encodeValue(k) })
+			if err != nil {
+				return err
+			}
+			pairs[i] = pair{b, v.MapIndex(k)}
+		}
+		return e.writePairs(pairs)
+	}
+
+	if err := e.WriteMapHeader(v.Len()); err != nil {
+		return err
+	}
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	}
+	for _, k := range keys {
+		if err := e.encodeValue(k); err != nil {
+			return err
+		}
+		if err := e.encodeValue(v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) writeFieldKey(f field) error {
+	if f.keyAsInt {
+		return e.WriteInt(f.intKey)
+	}
+	return e.WriteString(f.name)
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	var fields []field
+	for _, f := range structFields(v.Type()) {
+		if !f.omitEmpty || !isEmpty(v.Field(f.index)) {
+			fields = append(fields, f)
+		}
+	}
+	if e.Canonical {
+		pairs := make([]pair, len(fields))
+		for i, f := range fields {
+			b, err := encodeKey(func(ke *Encoder) error { return ke.writeFieldKey(f) })
+			if err != nil {
+				return err
+			}
+			pairs[i] = pair{b, v.Field(f.index)}
+		}
+		return e.writePairs(pairs)
+	}
+
+	if err := e.WriteMapHeader(len(fields)); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := e.writeFieldKey(f); err != nil {
+			return err
+		}
+		if err := e.encodeValue(v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
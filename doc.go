@@ -0,0 +1,9 @@
+// Package binaryio provides endian-aware readers and writers on top of
+// io.ReaderAt and io.WriterAt.
+//
+// A Reader or Writer keeps its own offset, error and scratch buffers and must
+// not be used by more than one goroutine at a time. The underlying
+// io.ReaderAt may be shared, so parallel parsing of one source is done by
+// giving each goroutine its own cursor via Reader.Clone or Reader.At.
+// Slices returned by ReadRaw are never reused by the Reader.
+package binaryio
@@ -0,0 +1,480 @@
+// Package elf parses ELF file, program and section headers, string tables and
+// symbol tables for both classes and byte orders, and patches them in place.
+package elf
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the elf package.
+var (
+	ErrFormat   = errors.New("elf: invalid format")
+	ErrNotFound = errors.New("elf: section not found")
+	ErrRange    = errors.New("elf: value does not fit the file class")
+)
+
+// Magic is the ELF identification prefix.
+const Magic = "\x7fELF"
+
+// Class is the EI_CLASS value selecting 32- or 64-bit structures.
+type Class byte
+
+// Classes.
+const (
+	Class32 Class = 1
+	Class64 Class = 2
+)
+
+// Data encodings (EI_DATA).
+const (
+	data2LSB = 1
+	data2MSB = 2
+)
+
+// Program header types.
+const (
+	ProgNull    = 0
+	ProgLoad    = 1
+	ProgDynamic = 2
+	ProgInterp  = 3
+	ProgNote    = 4
+	ProgPhdr    = 6
+	ProgTLS     = 7
+)
+
+// Section header types.
+const (
+	SecNull     = 0
+	SecProgbits = 1
+	SecSymtab   = 2
+	SecStrtab   = 3
+	SecRela     = 4
+	SecDynamic  = 6
+	SecNote     = 7
+	SecNobits   = 8
+	SecRel      = 9
+	SecDynsym   = 11
+)
+
+// Special section indexes.
+const (
+	sectionUndef  = 0
+	sectionXIndex = 0xFFFF
+)
+
+// progXNum in e_phnum means the program header count is in section 0's sh_info.
+const progXNum = 0xFFFF
+
+// Header is the ELF file header.
+type Header struct {
+	Class      Class
+	Order      bio.Endian
+	OSABI      byte
+	ABIVersion byte
+	Type       uint16
+	Machine    uint16
+	Version    uint32
+	Entry      uint64
+	Phoff      uint64
+	Shoff      uint64
+	Flags      uint32
+	Ehsize     uint16
+	Phentsize  uint16
+	Phnum      uint16
+	Shentsize  uint16
+	Shnum      uint16
+	Shstrndx   uint16
+}
+
+// Sizes of the class-dependent structures.
+func (h *Header) ehsize() int64 {
+	if h.Class == Class64 {
+		return 64
+	}
+	return 52
+}
+
+func (h *Header) phentsize() int64 {
+	if h.Class == Class64 {
+		return 56
+	}
+	return 32
+}
+
+func (h *Header) shentsize() int64 {
+	if h.Class == Class64 {
+		return 64
+	}
+	return 40
+}
+
+func (h *Header) symentsize() int64 {
+	if h.Class == Class64 {
+		return 24
+	}
+	return 16
+}
+
+// word reads an address or offset, which is 4 or 8 bytes depending on the class.
+func (h *Header) word(br *bio.Reader) uint64 {
+	if h.Class == Class64 {
+		return br.ReadU64(h.Order)
+	}
+	return uint64(br.ReadU32(h.Order))
+}
+
+// Prog is a program header. Pos is the position of the entry in the file.
+type Prog struct {
+	Type   uint32
+	Flags  uint32
+	Offset uint64
+	Vaddr  uint64
+	Paddr  uint64
+	Filesz uint64
+	Memsz  uint64
+	Align  uint64
+	Pos    int64
+	src    io.ReaderAt
+}
+
+// Data returns a reader over the file image of the segment.
+func (p *Prog) Data() *io.SectionReader {
+	return io.NewSectionReader(p.src, int64(p.Offset), int64(p.Filesz))
+}
+
+// Section is a section header. Pos is the position of the entry in the file.
+type Section struct {
+	Name      string
+	NameOff   uint32
+	Type      uint32
+	Flags     uint64
+	Addr      uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+	Pos       int64
+	src       io.ReaderAt
+}
+
+// Data returns a reader over the section contents. NOBITS sections are empty.
+func (s *Section) Data() *io.SectionReader {
+	if s.Type == SecNobits {
+		return io.NewSectionReader(s.src, int64(s.Offset), 0)
+	}
+	return io.NewSectionReader(s.src, int64(s.Offset), int64(s.Size))
+}
+
+// Bytes reads the whole section contents.
+func (s *Section) Bytes() ([]byte, error) {
+	if s.Type != SecNobits && s.Size > 0 {
+		// make sure the data exists before allocating for it
+		if s.Offset > math.MaxInt64-s.Size {
+			return nil, ErrFormat
+		}
+		if n, _ := s.src.ReadAt(make([]byte, 1), int64(s.Offset+s.Size-1)); n != 1 {
+			return nil, ErrFormat
+		}
+	}
+	d := s.Data()
+	br := bio.NewReader(d)
+	b := br.ReadRaw(uint64(d.Size()))
+	return b, br.Err()
+}
+
+// StringAt returns the NUL-terminated string at off in a string table section.
+func (s *Section) StringAt(off uint32) (string, error) {
+	b, err := s.Bytes()
+	if err != nil {
+		return "", err
+	}
+	str, ok := cstring(b, off)
+	if !ok {
+		return "", ErrFormat
+	}
+	return str, nil
+}
+
+func cstring(b []byte, off uint32) (string, bool) {
+	if uint64(off) >= uint64(len(b)) {
+		return "", false
+	}
+	for i := int(off); i < len(b); i++ {
+		if b[i] == 0 {
+			return string(b[off:i]), true
+		}
+	}
+	return "", false
+}
+
+// Symbol is a symbol table entry. Pos is the position of the entry in the file.
+type Symbol struct {
+	Name    string
+	NameOff uint32
+	Value   uint64
+	Size    uint64
+	Info    byte
+	Other   byte
+	Shndx   uint16
+	Pos     int64
+}
+
+// Bind returns the symbol binding (STB_*).
+func (s *Symbol) Bind() byte { return s.Info >> 4 }
+
+// Type returns the symbol type (STT_*).
+func (s *Symbol) Type() byte { return s.Info & 0xF }
+
+// File is a parsed ELF file.
+type File struct {
+	Header
+	Progs    []*Prog
+	Sections []*Section
+	src      io.ReaderAt
+}
+
+// Decode parses the file header, program headers and section headers of r and
+// resolves section names.
+func Decode(r io.ReaderAt) (*File, error) {
+	br := bio.NewReader(r)
+	f := &File{src: r}
+	h := &f.Header
+	if br.ReadS32(bio.BigEndian) != Magic {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	h.Class = Class(br.ReadU8())
+	data := br.ReadU8()
+	if br.ReadU8() != 1 {
+		return nil, ErrFormat
+	}
+	h.OSABI = br.ReadU8()
+	h.ABIVersion = br.ReadU8()
+	switch data {
+	case data2LSB:
+		h.Order = bio.LittleEndian
+	case data2MSB:
+		h.Order = bio.BigEndian
+	default:
+		return nil, ErrFormat
+	}
+	if h.Class != Class32 && h.Class != Class64 {
+		return nil, ErrFormat
+	}
+
+	br.SetOffset(16)
+	h.Type = br.ReadU16(h.Order)
+	h.Machine = br.ReadU16(h.Order)
+	h.Version = br.ReadU32(h.Order)
+	h.Entry = h.word(br)
+	h.Phoff = h.word(br)
+	h.Shoff = h.word(br)
+	h.Flags = br.ReadU32(h.Order)
+	h.Ehsize = br.ReadU16(h.Order)
+	h.Phentsize = br.ReadU16(h.Order)
+	h.Phnum = br.ReadU16(h.Order)
+	h.Shentsize = br.ReadU16(h.Order)
+	h.Shnum = br.ReadU16(h.Order)
+	h.Shstrndx = br.ReadU16(h.Order)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if h.Phnum > 0 && int64(h.Phentsize) != h.phentsize() ||
+		h.Shoff != 0 && int64(h.Shentsize) != h.shentsize() {
+		return nil, ErrFormat
+	}
+
+	if err := f.readSections(br); err != nil {
+		return nil, err
+	}
+	if err := f.readProgs(br); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readProgs reads the program header table. Its count is in section 0 when it
+// does not fit in the file header.
+func (f *File) readProgs(br *bio.Reader) error {
+	h := &f.Header
+	n := uint64(h.Phnum)
+	if n == progXNum {
+		if len(f.Sections) == 0 {
+			return ErrFormat
+		}
+		n = uint64(f.Sections[0].Info)
+	}
+	if n > 1<<20 {
+		return ErrFormat
+	}
+	for i := uint64(0); i < n; i++ {
+		p := &Prog{Pos: int64(h.Phoff) + int64(i)*h.phentsize(), src: f.src}
+		br.SetOffset(p.Pos)
+		p.Type = br.ReadU32(h.Order)
+		if h.Class == Class64 {
+			p.Flags = br.ReadU32(h.Order)
+		}
+		p.Offset = h.word(br)
+		p.Vaddr = h.word(br)
+		p.Paddr = h.word(br)
+		p.Filesz = h.word(br)
+		p.Memsz = h.word(br)
+		if h.Class == Class32 {
+			p.Flags = br.ReadU32(h.Order)
+		}
+		p.Align = h.word(br)
+		if br.Err() != nil {
+			return br.Err()
+		}
+		f.Progs = append(f.Progs, p)
+	}
+	return nil
+}
+
+func (f *File) readSection(br *bio.Reader, pos int64) (*Section, error) {
+	h := &f.Header
+	s := &Section{Pos: pos, src: f.src}
+	br.SetOffset(pos)
+	s.NameOff = br.ReadU32(h.Order)
+	s.Type = br.ReadU32(h.Order)
+	s.Flags = h.word(br)
+	s.Addr = h.word(br)
+	s.Offset = h.word(br)
+	s.Size = h.word(br)
+	s.Link = br.ReadU32(h.Order)
+	s.Info = br.ReadU32(h.Order)
+	s.Addralign = h.word(br)
+	s.Entsize = h.word(br)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	return s, nil
+}
+
+// readSections reads the section header table. Section 0 holds the real count
+// and string table index when they do not fit in the file header.
+func (f *File) readSections(br *bio.Reader) error {
+	h := &f.Header
+	if h.Shoff == 0 {
+		return nil
+	}
+	first, err := f.readSection(br, int64(h.Shoff))
+	if err != nil {
+		return err
+	}
+	n := uint64(h.Shnum)
+	if n == 0 {
+		n = first.Size
+	}
+	strndx := uint64(h.Shstrndx)
+	if strndx == sectionXIndex {
+		strndx = uint64(first.Link)
+	}
+	if n > 1<<20 || n > 0 && strndx >= n {
+		return ErrFormat
+	}
+
+	f.Sections = append(f.Sections, first)
+	for i := uint64(1); i < n; i++ {
+		s, err := f.readSection(br, int64(h.Shoff)+int64(i)*h.shentsize())
+		if err != nil {
+			return err
+		}
+		f.Sections = append(f.Sections, s)
+	}
+	if n == 0 || strndx == sectionUndef {
+		return nil
+	}
+
+	names, err := f.Sections[strndx].Bytes()
+	if err != nil {
+		return err
+	}
+	for _, s := range f.Sections {
+		var ok bool
+		if s.Name, ok = cstring(names, s.NameOff); !ok {
+			return ErrFormat
+		}
+	}
+	return nil
+}
+
+// Section returns the first section with the given name, or nil.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Symbols returns the entries of the SYMTAB section, without the null symbol at index 0.
+func (f *File) Symbols() ([]*Symbol, error) {
+	return f.symbols(SecSymtab)
+}
+
+// DynamicSymbols returns the entries of the DYNSYM section, without the null symbol at index 0.
+func (f *File) DynamicSymbols() ([]*Symbol, error) {
+	return f.symbols(SecDynsym)
+}
+
+func (f *File) symbols(typ uint32) ([]*Symbol, error) {
+	var sec *Section
+	for _, s := range f.Sections {
+		if s.Type == typ {
+			sec = s
+			break
+		}
+	}
+	if sec == nil {
+		return nil, ErrNotFound
+	}
+	if int(sec.Link) >= len(f.Sections) {
+		return nil, ErrFormat
+	}
+	names, err := f.Sections[sec.Link].Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &f.Header
+	size := h.symentsize()
+	br := bio.NewReader(f.src, bio.WithBufferSize(64<<10))
+	var syms []*Symbol
+	for pos := int64(sec.Offset) + size; pos+size <= int64(sec.Offset+sec.Size); pos += size {
+		s := &Symbol{Pos: pos}
+		br.SetOffset(pos)
+		s.NameOff = br.ReadU32(h.Order)
+		if h.Class == Class64 {
+			s.Info = br.ReadU8()
+			s.Other = br.ReadU8()
+			s.Shndx = br.ReadU16(h.Order)
+			s.Value = br.ReadU64(h.Order)
+			s.Size = br.ReadU64(h.Order)
+		} else {
+			s.Value = uint64(br.ReadU32(h.Order))
+			s.Size = uint64(br.ReadU32(h.Order))
+			s.Info = br.ReadU8()
+			s.Other = br.ReadU8()
+			s.Shndx = br.ReadU16(h.Order)
+		}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		var ok bool
+		if s.Name, ok = cstring(names, s.NameOff); !ok {
+			return nil, ErrFormat
+		}
+		syms = append(syms, s)
+	}
+	return syms, nil
+}
@@ -0,0 +1,252 @@
+package elf
+
+import (
+	stdelf "debug/elf"
+	"os"
+	"strings"
+	"testing"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// writeELF writes a relocatable file with .text, .symtab, .strtab and
+// .shstrtab sections and one PT_LOAD program header.
+func writeELF(t *testing.T, f *os.File, h *Header) {
+	shstrtab := "\x00.text\x00.symtab\x00.strtab\x00.shstrtab\x00"
+	strtab := "\x00main\x00counter\x00"
+	text := []byte{0x90, 0x90, 0xC3}
+
+	ew := NewWriter(f, h)
+	w := bio.NewWriter(f)
+	pos := h.ehsize()
+	h.Phoff = uint64(pos)
+	pos += h.phentsize()
+
+	place := func(b []byte) uint64 {
+		off := pos
+		w.SetOffset(off)
+		w.WriteRaw(b)
+		pos += int64(len(b))
+		return uint64(off)
+	}
+	textOff := place(text)
+	strOff := place([]byte(strtab))
+	shstrOff := place([]byte(shstrtab))
+	pos = (pos + 7) &^ 7
+	symOff := uint64(pos)
+	pos += 3 * h.symentsize()
+	h.Shoff = uint64(pos)
+	h.Ehsize = uint16(h.ehsize())
+	h.Phentsize = uint16(h.phentsize())
+	h.Phnum = 1
+	h.Shentsize = uint16(h.shentsize())
+	h.Shnum = 5
+	h.Shstrndx = 4
+	if err := ew.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+
+	prog := &Prog{Type: ProgLoad, Flags: 5, Offset: textOff, Vaddr: 0x1000, Paddr: 0x1000, Filesz: 3, Memsz: 3, Align: 0x1000, Pos: int64(h.Phoff)}
+	if err := ew.WriteProg(prog); err != nil {
+		t.Fatal(err)
+	}
+	sections := []*Section{
+		{},
+		{NameOff: 1, Type: SecProgbits, Flags: 6, Addr: 0x1000, Offset: textOff, Size: 3, Addralign: 1},
+		{NameOff: 7, Type: SecSymtab, Offset: symOff, Size: uint64(3 * h.symentsize()), Link: 3, Info: 1, Addralign: 8, Entsize: uint64(h.symentsize())},
+		{NameOff: 15, Type: SecStrtab, Offset: strOff, Size: uint64(len(strtab)), Addralign: 1},
+		{NameOff: 23, Type: SecStrtab, Offset: shstrOff, Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+	for i, s := range sections {
+		s.Pos = int64(h.Shoff) + int64(i)*h.shentsize()
+		if err := ew.WriteSection(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symbols := []*Symbol{
+		{},
+		{NameOff: 1, Value: 0x1000, Size: 3, Info: 0x12, Shndx: 1},
+		{NameOff: 6, Value: 0x2000, Size: 4, Info: 0x11, Other: 2, Shndx: 0xFFF1},
+	}
+	for i, s := range symbols {
+		s.Pos = int64(symOff) + int64(i)*h.symentsize()
+		if err := ew.WriteSymbol(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	testFileName := "test.elf"
+	defer os.Remove(testFileName)
+
+	for _, class := range []Class{Class32, Class64} {
+		for _, order := range []bio.Endian{bio.LittleEndian, bio.BigEndian} {
+			fw, err := os.Create(testFileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeELF(t, fw, &Header{Class: class, Order: order, Type: 1, Machine: 62, Version: 1, Entry: 0x1000})
+
+			f, err := Decode(fw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			std, err := stdelf.NewFile(fw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Entry != std.Entry || int(f.Machine) != int(std.Machine) || len(f.Sections) != len(std.Sections) {
+				t.Fatalf("Invalid header %+v", f.Header)
+			}
+			for i, s := range f.Sections {
+				ss := std.Sections[i]
+				if s.Name != ss.Name || s.Offset != ss.Offset || s.Size != ss.Size || s.Link != ss.Link {
+					t.Fatalf("Invalid section %d %+v", i, s)
+				}
+			}
+			if len(f.Progs) != 1 || f.Progs[0].Filesz != std.Progs[0].Filesz || f.Progs[0].Flags != uint32(std.Progs[0].Flags) {
+				t.Fatalf("Invalid program header %+v", f.Progs[0])
+			}
+			text, err := f.Section(".text").Bytes()
+			if err != nil || string(text) != "\x90\x90\xC3" {
+				t.Fatalf("Invalid .text % x %v", text, err)
+			}
+			if name, err := f.Section(".strtab").StringAt(6); name != "counter" || err != nil {
+				t.Fatalf("Invalid string %q %v", name, err)
+			}
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdSyms, err := std.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(syms) != len(stdSyms) {
+				t.Fatalf("Invalid symbol count %d", len(syms))
+			}
+			for i, s := range syms {
+				ss := stdSyms[i]
+				if s.Name != ss.Name || s.Value != ss.Value || s.Size != ss.Size || s.Info != ss.Info || s.Other != ss.Other || s.Shndx != uint16(ss.Section) {
+					t.Fatalf("Invalid symbol %+v", s)
+				}
+			}
+			if syms[0].Bind() != 1 || syms[0].Type() != 2 {
+				t.Fatalf("Invalid symbol info %02x", syms[0].Info)
+			}
+			if _, err := f.DynamicSymbols(); err != ErrNotFound {
+				t.Fatalf("Invalid dynsym error %v", err)
+			}
+
+			// Patch in place.
+			ew := NewWriter(fw, &f.Header)
+			f.Entry = 0x1001
+			syms[1].Value = 0x3000
+			f.Sections[1].Flags |= 1
+			f.Progs[0].Memsz = 0x100
+			if err := ew.WriteHeader(&f.Header); err != nil {
+				t.Fatal(err)
+			}
+			if err := ew.WriteSymbol(syms[1]); err != nil {
+				t.Fatal(err)
+			}
+			if err := ew.WriteSection(f.Sections[1]); err != nil {
+				t.Fatal(err)
+			}
+			if err := ew.WriteProg(f.Progs[0]); err != nil {
+				t.Fatal(err)
+			}
+			g, err := Decode(fw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gsyms, _ := g.Symbols()
+			if g.Entry != 0x1001 || gsyms[1].Value != 0x3000 || g.Sections[1].Flags != 7 || g.Progs[0].Memsz != 0x100 {
+				t.Fatalf("Patch failed %+v", g.Header)
+			}
+
+			// program header count moved to section 0
+			g.Phnum = progXNum
+			g.Sections[0].Info = 1
+			if err := ew.WriteHeader(&g.Header); err != nil {
+				t.Fatal(err)
+			}
+			if err := ew.WriteSection(g.Sections[0]); err != nil {
+				t.Fatal(err)
+			}
+			if g, err = Decode(fw); err != nil || len(g.Progs) != 1 || g.Progs[0].Memsz != 0x100 {
+				t.Fatalf("Invalid PN_XNUM decode %v", err)
+			}
+
+			// section data past the end of the file
+			s := *g.Sections[1]
+			s.Size = 1 << 40
+			if _, err := s.Bytes(); err != ErrFormat {
+				t.Fatalf("Invalid section size error %v", err)
+			}
+			s.Offset = 1 << 63
+			if _, err := s.Bytes(); err != ErrFormat {
+				t.Fatalf("Invalid section offset error %v", err)
+			}
+
+			// 64-bit values in a 32-bit file
+			if g.Class == Class32 {
+				g.Entry = 1 << 32
+				if err := ew.WriteHeader(&g.Header); err != ErrRange {
+					t.Fatalf("Invalid header range error %v", err)
+				}
+				if err := ew.WriteSection(&s); err != ErrRange {
+					t.Fatalf("Invalid section range error %v", err)
+				}
+			}
+			fw.Close()
+		}
+	}
+
+	if _, err := Decode(strings.NewReader("MZ\x90\x00")); err != ErrFormat {
+		t.Fatalf("Invalid format error %v", err)
+	}
+}
+
+func TestExecutable(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	std, err := stdelf.Open(exe)
+	if err != nil {
+		t.Skip("not an ELF executable")
+	}
+	defer std.Close()
+	fr, err := os.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	f, err := Decode(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Progs) != len(std.Progs) || len(f.Sections) != len(std.Sections) {
+		t.Fatalf("Invalid header counts %d %d", len(f.Progs), len(f.Sections))
+	}
+	for i, s := range f.Sections {
+		if s.Name != std.Sections[i].Name || s.Addr != std.Sections[i].Addr {
+			t.Fatalf("Invalid section %s", s.Name)
+		}
+	}
+	stdSyms, err := std.Symbols()
+	if err != nil {
+		t.Skip("no symbol table")
+	}
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(syms) != len(stdSyms) || syms[len(syms)-1].Name != stdSyms[len(stdSyms)-1].Name {
+		t.Fatalf("Invalid symbols")
+	}
+}
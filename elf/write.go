@@ -0,0 +1,146 @@
+package elf
+
+import (
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Writer patches ELF structures in place. Each structure is written back to the
+// position it was read from, in the class and byte order of the header. Class32
+// files report ErrRange for addresses, offsets and sizes above 32 bits.
+type Writer struct {
+	w *bio.Writer
+	h Header
+}
+
+// NewWriter returns a Writer for files with the class and byte order of h.
+func NewWriter(w io.WriterAt, h *Header) *Writer {
+	return &Writer{w: bio.NewWriter(w), h: *h}
+}
+
+// fits reports whether the words vs fit the class.
+func (ew *Writer) fits(vs ...uint64) bool {
+	if ew.h.Class == Class64 {
+		return true
+	}
+	for _, v := range vs {
+		if v > math.MaxUint32 {
+			return false
+		}
+	}
+	return true
+}
+
+func (ew *Writer) word(v uint64) {
+	if ew.h.Class == Class64 {
+		ew.w.WriteU64(v, ew.h.Order)
+	} else {
+		ew.w.WriteU32(uint32(v), ew.h.Order)
+	}
+}
+
+// WriteHeader writes the identification and file header at offset 0.
+func (ew *Writer) WriteHeader(h *Header) error {
+	if h.Class != ew.h.Class || h.Order != ew.h.Order {
+		return ErrFormat
+	}
+	if !ew.fits(h.Entry, h.Phoff, h.Shoff) {
+		return ErrRange
+	}
+	w := ew.w
+	w.SetOffset(0)
+	w.WriteS32(Magic, bio.BigEndian)
+	w.WriteU8(byte(h.Class))
+	if h.Order == bio.LittleEndian {
+		w.WriteU8(data2LSB)
+	} else {
+		w.WriteU8(data2MSB)
+	}
+	w.WriteU8(1)
+	w.WriteU8(h.OSABI)
+	w.WriteU8(h.ABIVersion)
+	w.WriteRaw(make([]byte, 7))
+	w.WriteU16(h.Type, h.Order)
+	w.WriteU16(h.Machine, h.Order)
+	w.WriteU32(h.Version, h.Order)
+	ew.word(h.Entry)
+	ew.word(h.Phoff)
+	ew.word(h.Shoff)
+	w.WriteU32(h.Flags, h.Order)
+	w.WriteU16(h.Ehsize, h.Order)
+	w.WriteU16(h.Phentsize, h.Order)
+	w.WriteU16(h.Phnum, h.Order)
+	w.WriteU16(h.Shentsize, h.Order)
+	w.WriteU16(h.Shnum, h.Order)
+	w.WriteU16(h.Shstrndx, h.Order)
+	return w.Err()
+}
+
+// WriteProg writes p at p.Pos.
+func (ew *Writer) WriteProg(p *Prog) error {
+	if !ew.fits(p.Offset, p.Vaddr, p.Paddr, p.Filesz, p.Memsz, p.Align) {
+		return ErrRange
+	}
+	w, o := ew.w, ew.h.Order
+	w.SetOffset(p.Pos)
+	w.WriteU32(p.Type, o)
+	if ew.h.Class == Class64 {
+		w.WriteU32(p.Flags, o)
+	}
+	ew.word(p.Offset)
+	ew.word(p.Vaddr)
+	ew.word(p.Paddr)
+	ew.word(p.Filesz)
+	ew.word(p.Memsz)
+	if ew.h.Class == Class32 {
+		w.WriteU32(p.Flags, o)
+	}
+	ew.word(p.Align)
+	return w.Err()
+}
+
+// WriteSection writes the header of s at s.Pos. The name is written as NameOff only.
+func (ew *Writer) WriteSection(s *Section) error {
+	if !ew.fits(s.Flags, s.Addr, s.Offset, s.Size, s.Addralign, s.Entsize) {
+		return ErrRange
+	}
+	w, o := ew.w, ew.h.Order
+	w.SetOffset(s.Pos)
+	w.WriteU32(s.NameOff, o)
+	w.WriteU32(s.Type, o)
+	ew.word(s.Flags)
+	ew.word(s.Addr)
+	ew.word(s.Offset)
+	ew.word(s.Size)
+	w.WriteU32(s.Link, o)
+	w.WriteU32(s.Info, o)
+	ew.word(s.Addralign)
+	ew.word(s.Entsize)
+	return w.Err()
+}
+
+// WriteSymbol writes s at s.Pos. The name is written as NameOff only.
+func (ew *Writer) WriteSymbol(s *Symbol) error {
+	if !ew.fits(s.Value, s.Size) {
+		return ErrRange
+	}
+	w, o := ew.w, ew.h.Order
+	w.SetOffset(s.Pos)
+	w.WriteU32(s.NameOff, o)
+	if ew.h.Class == Class64 {
+		w.WriteU8(s.Info)
+		w.WriteU8(s.Other)
+		w.WriteU16(s.Shndx, o)
+		w.WriteU64(s.Value, o)
+		w.WriteU64(s.Size, o)
+	} else {
+		w.WriteU32(uint32(s.Value), o)
+		w.WriteU32(uint32(s.Size), o)
+		w.WriteU8(s.Info)
+		w.WriteU8(s.Other)
+		w.WriteU16(s.Shndx, o)
+	}
+	return w.Err()
+}
@@ -0,0 +1,13 @@
+package binaryio
+
+import (
+	"errors"
+)
+
+// Errors reported through Reader.Err and Writer.Err.
+var (
+	ErrInvalidWidth = errors.New("binaryio: invalid field width")
+	ErrOverflow     = errors.New("binaryio: value out of range")
+	ErrInvalidTime  = errors.New("binaryio: invalid time")
+	ErrInvalidBCD   = errors.New("binaryio: invalid BCD digit")
+)
@@ -0,0 +1,75 @@
+package binaryio
+
+import (
+	"math"
+)
+
+// Float16bits returns the IEEE 754 binary16 representation of f, rounded to
+// nearest, ties to even. Values too large for binary16 become infinities and
+// NaNs stay NaNs.
+func Float16bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xFF
+	mant := b & 0x7FFFFF
+
+	if exp == 0xFF {
+		if mant == 0 {
+			return sign | 0x7C00
+		}
+		return sign | 0x7E00 | uint16(mant>>13)
+	}
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	}
+	if e <= 0 {
+		// subnormal; a carry out of the mantissa yields the smallest normal
+		if e < -10 {
+			return sign
+		}
+		return sign | uint16(roundShift(uint64(mant|0x800000), uint(14-e)))
+	}
+	// a carry out of the mantissa bumps the exponent, up to infinity
+	return sign | (uint16(e)<<10 + uint16(roundShift(uint64(mant), 13)))
+}
+
+// Float16frombits returns the float32 value of the IEEE 754 binary16 b.
+// Every binary16 value is exactly representable.
+func Float16frombits(b uint16) float32 {
+	sign := uint32(b&0x8000) << 16
+	exp := uint32(b>>10) & 0x1F
+	mant := uint32(b & 0x3FF)
+
+	switch exp {
+	case 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	case 0:
+		v := float32(math.Ldexp(float64(mant), -24))
+		if sign != 0 {
+			v = -v
+		}
+		return math.Float32frombits(sign | math.Float32bits(v))
+	}
+	return math.Float32frombits(sign | (exp-15+127)<<23 | mant<<13)
+}
+
+// ReadF16 reads an IEEE 754 half precision float.
+func (br *Reader) ReadF16(e Endian) float32 {
+	if br.err != nil {
+		return 0
+	}
+	b := br.ReadU16(e)
+	if br.err != nil {
+		return 0
+	}
+	return Float16frombits(b)
+}
+
+// WriteF16 writes v as an IEEE 754 half precision float, rounding to nearest.
+func (bw *Writer) WriteF16(v float32, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.WriteU16(Float16bits(v), e)
+}
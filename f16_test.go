@@ -0,0 +1,94 @@
+package binaryio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestF16(t *testing.T) {
+	// -----------------------------
+	// Conversion
+	// -----------------------------
+	tests := []struct {
+		bits uint16
+		v    float32
+	}{
+		{0x0000, 0},
+		{0x8000, float32(math.Copysign(0, -1))},
+		{0x3C00, 1},
+		{0x3E00, 1.5},
+		{0xC400, -4},
+		{0x7BFF, 65504},
+		{0x0400, 0.00006103515625},            // smallest normal
+		{0x0001, float32(math.Ldexp(1, -24))}, // smallest subnormal
+		{0x7C00, float32(math.Inf(1))},
+		{0xFC00, float32(math.Inf(-1))},
+	}
+	for _, tt := range tests {
+		if v := Float16frombits(tt.bits); v != tt.v || math.Signbit(float64(v)) != math.Signbit(float64(tt.v)) {
+			t.Fatalf("Invalid Float16frombits %04x: %v", tt.bits, v)
+		}
+		if b := Float16bits(tt.v); b != tt.bits {
+			t.Fatalf("Invalid Float16bits %v: %04x", tt.v, b)
+		}
+	}
+	rounding := []struct {
+		v    float32
+		bits uint16
+	}{
+		{1 + float32(math.Ldexp(1, -11)), 0x3C00}, // tie, rounds to even
+		{1 + float32(math.Ldexp(3, -11)), 0x3C02}, // tie, rounds up to even
+		{65520, 0x7C00},                            // rounds up to infinity
+		{1e10, 0x7C00},                             // overflow
+		{float32(math.Ldexp(1, -25)), 0x0000},      // half of the smallest subnormal
+		{float32(math.Ldexp(3, -26)), 0x0001},      // above half
+		{float32(math.Ldexp(0x7FF, -25)), 0x0400},  // carries into the smallest normal
+		{float32(math.Ldexp(1, -30)), 0x0000},      // underflow
+		{-float32(math.Ldexp(1, -30)), 0x8000},     // underflow keeps the sign
+		{float32(math.Ldexp(0x1FFF, -28)), 0x0200}, // subnormal rounding
+		{float32(math.Ldexp(0x7FF, -22)), 0x0FFF},  // exact normal
+	}
+	for _, tt := range rounding {
+		if b := Float16bits(tt.v); b != tt.bits {
+			t.Fatalf("Invalid Float16bits rounding %v: %04x", tt.v, b)
+		}
+	}
+	if v := Float16frombits(Float16bits(float32(math.NaN()))); !math.IsNaN(float64(v)) {
+		t.Fatalf("Invalid Float16 NaN %v", v)
+	}
+	if !math.IsNaN(float64(Float16frombits(0x7C01))) {
+		t.Fatalf("Invalid Float16frombits NaN")
+	}
+
+	// -----------------------------
+	// Read / Write
+	// -----------------------------
+	testFileName := "test.bin"
+	fw := openWriteFile(testFileName, t)
+	w := NewWriter(fw)
+	n := w.WriteF16(1.5, BigEndian)
+	n += w.WriteF16(-4, LittleEndian)
+	if n != 4 || w.Err() != nil {
+		t.Fatalf("Invalid WriteF16 %d %v", n, w.Err())
+	}
+	fw.Close()
+
+	fr := openReadFile(testFileName, t)
+	r := NewReader(fr)
+	if raw := r.ReadRaw(4); !bytes.Equal(raw, []byte{0x3E, 0x00, 0x00, 0xC4}) {
+		t.Fatalf("Invalid WriteF16 % x", raw)
+	}
+	r.SetOffset(0)
+	if v := r.ReadF16(BigEndian); v != 1.5 {
+		t.Fatalf("Invalid ReadF16 %v", v)
+	}
+	if v := r.ReadF16(LittleEndian); v != -4 {
+		t.Fatalf("Invalid ReadF16 %v", v)
+	}
+	if r.ReadF16(BigEndian); r.Err() == nil {
+		t.Fatalf("ReadF16 past the end must fail")
+	}
+	fr.Close()
+	removeFile(testFileName, t)
+}
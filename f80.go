@@ -0,0 +1,118 @@
+package binaryio
+
+import (
+	"math"
+)
+
+// roundShift returns m>>s rounded to nearest, ties to even.
+func roundShift(m uint64, s uint) uint64 {
+	if s == 0 {
+		return m
+	}
+	if s > 64 {
+		return 0
+	}
+	if s == 64 {
+		if m > 1<<63 {
+			return 1
+		}
+		return 0
+	}
+	q := m >> s
+	rem := m & (1<<s - 1)
+	half := uint64(1) << (s - 1)
+	if rem > half || (rem == half && q&1 == 1) {
+		q++
+	}
+	return q
+}
+
+// f80ToFloat64 converts an x87 extended precision value to the nearest float64.
+func f80ToFloat64(se uint16, m uint64) float64 {
+	sign := 1.0
+	if se&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(se & 0x7FFF)
+
+	if exp == 0x7FFF {
+		if m<<1 == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	if m == 0 {
+		return math.Copysign(0, sign)
+	}
+	if exp == 0 {
+		exp = 1 // denormal
+	}
+	for m&(1<<63) == 0 {
+		m <<= 1
+		exp--
+	}
+
+	e := exp - 16383 // value = 1.xxx * 2^e
+	if e > 1023 {
+		return math.Inf(int(sign))
+	}
+	shift := uint(11)
+	if e < -1022 {
+		shift += uint(-1022 - e)
+	}
+	q := roundShift(m, shift)
+	return sign * math.Ldexp(float64(q), e-63+int(shift))
+}
+
+// float64ToF80 converts v to the x87 extended precision representation.
+// Every float64 is exactly representable.
+func float64ToF80(v float64) (uint16, uint64) {
+	var se uint16
+	if math.Signbit(v) {
+		se = 0x8000
+	}
+	switch {
+	case math.IsNaN(v):
+		return se | 0x7FFF, 0xC000000000000000
+	case math.IsInf(v, 0):
+		return se | 0x7FFF, 0x8000000000000000
+	case v == 0:
+		return se, 0
+	}
+	frac, exp := math.Frexp(math.Abs(v))
+	m := uint64(math.Ldexp(frac, 64))
+	return se | uint16(exp-1+16383), m
+}
+
+// ReadF80 reads an 80-bit x87 extended precision float (e.g. the AIFF sample rate)
+// and rounds it to the nearest float64.
+func (br *Reader) ReadF80(e Endian) float64 {
+	if br.err != nil {
+		return 0
+	}
+	var se uint16
+	var m uint64
+	if e == LittleEndian {
+		m = br.ReadU64(e)
+		se = br.ReadU16(e)
+	} else {
+		se = br.ReadU16(e)
+		m = br.ReadU64(e)
+	}
+	if br.err != nil {
+		return 0
+	}
+	return f80ToFloat64(se, m)
+}
+
+// WriteF80 writes v as an 80-bit x87 extended precision float.
+func (bw *Writer) WriteF80(v float64, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	se, m := float64ToF80(v)
+	if e == LittleEndian {
+		return bw.WriteU64(m, e) + bw.WriteU16(se, e)
+	}
+	return bw.WriteU16(se, e) + bw.WriteU64(m, e)
+}
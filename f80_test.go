@@ -0,0 +1,85 @@
+package binaryio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestF80(t *testing.T) {
+	// -----------------------------
+	// AIFF sample rates
+	// -----------------------------
+	rates := []struct {
+		v   float64
+		raw []byte
+	}{
+		{8000, []byte{0x40, 0x0B, 0xFA, 0x00, 0, 0, 0, 0, 0, 0}},
+		{22050, []byte{0x40, 0x0D, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}},
+		{44100, []byte{0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}},
+		{48000, []byte{0x40, 0x0E, 0xBB, 0x80, 0, 0, 0, 0, 0, 0}},
+		{96000, []byte{0x40, 0x0F, 0xBB, 0x80, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, rate := range rates {
+		r := NewReader(bytes.NewReader(rate.raw))
+		if v := r.ReadF80(BigEndian); v != rate.v || r.Err() != nil {
+			t.Fatalf("Invalid ReadF80 %v", v)
+		}
+
+		testFileName := "test.bin"
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw)
+		if n := w.WriteF80(rate.v, BigEndian); n != 10 || w.Err() != nil {
+			t.Fatalf("Invalid WriteF80 %d", n)
+		}
+		fw.Close()
+		fr := openReadFile(testFileName, t)
+		if raw := NewReader(fr).ReadRaw(10); !bytes.Equal(raw, rate.raw) {
+			t.Fatalf("Invalid WriteF80 % x", raw)
+		}
+		fr.Close()
+		removeFile(testFileName, t)
+	}
+
+	// -----------------------------
+	// Special values and rounding
+	// -----------------------------
+	tests := []struct {
+		se   uint16
+		m    uint64
+		want float64
+	}{
+		{0x7FFF, 0x8000000000000000, math.Inf(1)},
+		{0xFFFF, 0x8000000000000000, math.Inf(-1)},
+		{0x8000, 0, math.Copysign(0, -1)},
+		{0x3FFF, 0x8000000000000400, 1},                      // tie, rounds to even
+		{0x3FFF, 0x8000000000000C00, 1 + math.Ldexp(1, -51)}, // tie, rounds up to even
+		{0x3FFF, 0x8000000000000401, 1 + math.Ldexp(1, -52)},
+		{0x3BCD, 0x8000000000000000, math.Ldexp(1, -1074)}, // smallest float64 denormal
+		{0x3BCC, 0x8000000000000000, 0},                    // half of it, ties to even
+		{0x3BCC, 0xC000000000000000, math.Ldexp(1, -1074)},
+		{0x43FF, 0x8000000000000000, math.Inf(1)},
+		{0x0000, 0x0000000000000001, 0}, // 80-bit denormal
+	}
+	for _, tt := range tests {
+		if v := f80ToFloat64(tt.se, tt.m); v != tt.want || math.Signbit(v) != math.Signbit(tt.want) {
+			t.Fatalf("Invalid f80 %04x %016x: %v", tt.se, tt.m, v)
+		}
+	}
+	if !math.IsNaN(f80ToFloat64(0x7FFF, 0xC000000000000000)) {
+		t.Fatalf("Invalid f80 NaN")
+	}
+	for _, v := range []float64{math.NaN(), math.Inf(-1), math.Ldexp(1, -1074), math.MaxFloat64, -math.Pi} {
+		se, m := float64ToF80(v)
+		got := f80ToFloat64(se, m)
+		if got != v && !(math.IsNaN(v) && math.IsNaN(got)) {
+			t.Fatalf("Invalid f80 round trip %v: %v", v, got)
+		}
+	}
+	{
+		r := NewReader(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 0x80, 0xFF, 0xBF}))
+		if v := r.ReadF80(LittleEndian); v != -1 {
+			t.Fatalf("Invalid ReadF80 little endian %v", v)
+		}
+	}
+}
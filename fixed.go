@@ -0,0 +1,163 @@
+package binaryio
+
+import (
+	"math"
+	"math/big"
+)
+
+// RoundingMode selects how WriteFixed rounds values that are not exactly representable.
+type RoundingMode int
+
+// Rounding modes for WriteFixed.
+const (
+	RoundNearestEven RoundingMode = iota
+	RoundNearestAway
+	RoundTowardZero
+	RoundDown
+	RoundUp
+)
+
+func (m RoundingMode) round(x float64) float64 {
+	switch m {
+	case RoundNearestAway:
+		return math.Round(x)
+	case RoundTowardZero:
+		return math.Trunc(x)
+	case RoundDown:
+		return math.Floor(x)
+	case RoundUp:
+		return math.Ceil(x)
+	}
+	return math.RoundToEven(x)
+}
+
+func fixedWidth(intBits, fracBits int) int {
+	n := intBits + fracBits
+	if intBits < 0 || fracBits < 0 || n < 8 || n > 64 || n%8 != 0 {
+		return 0
+	}
+	return n / 8
+}
+
+func (br *Reader) readFixedRaw(intBits, fracBits int, signed bool, e Endian) (int64, uint64) {
+	nbytes := fixedWidth(intBits, fracBits)
+	if nbytes == 0 {
+		br.setErr(ErrInvalidWidth)
+		return 0, 0
+	}
+	if signed {
+		return br.ReadIN(nbytes, e), 0
+	}
+	return 0, br.ReadUN(nbytes, e)
+}
+
+// ReadFixed reads a binary fixed-point number with intBits integer bits
+// (including the sign bit when signed) and fracBits fractional bits.
+func (br *Reader) ReadFixed(intBits, fracBits int, signed bool, e Endian) float64 {
+	if br.err != nil {
+		return 0
+	}
+	i, u := br.readFixedRaw(intBits, fracBits, signed, e)
+	if br.err != nil {
+		return 0
+	}
+	if signed {
+		return math.Ldexp(float64(i), -fracBits)
+	}
+	return math.Ldexp(float64(u), -fracBits)
+}
+
+// ReadFixedRat is like ReadFixed but returns the exact value.
+func (br *Reader) ReadFixedRat(intBits, fracBits int, signed bool, e Endian) *big.Rat {
+	if br.err != nil {
+		return nil
+	}
+	i, u := br.readFixedRaw(intBits, fracBits, signed, e)
+	if br.err != nil {
+		return nil
+	}
+	num := new(big.Int).SetUint64(u)
+	if signed {
+		num.SetInt64(i)
+	}
+	den := new(big.Int).Lsh(big.NewInt(1), uint(fracBits))
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// WriteFixed writes v as a binary fixed-point number, rounding with mode.
+// Values outside the representable range are reported as ErrOverflow.
+func (bw *Writer) WriteFixed(v float64, intBits, fracBits int, signed bool, mode RoundingMode, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	nbytes := fixedWidth(intBits, fracBits)
+	if nbytes == 0 {
+		bw.setErr(ErrInvalidWidth)
+		return 0
+	}
+	x := mode.round(math.Ldexp(v, fracBits))
+	n := 8 * nbytes
+	if signed {
+		limit := math.Ldexp(1, n-1)
+		if math.IsNaN(x) || x < -limit || x >= limit {
+			bw.setErr(ErrOverflow)
+			return 0
+		}
+		return bw.WriteIN(int64(x), nbytes, e)
+	}
+	if math.IsNaN(x) || x < 0 || x >= math.Ldexp(1, n) {
+		bw.setErr(ErrOverflow)
+		return 0
+	}
+	return bw.WriteUN(uint64(x), nbytes, e)
+}
+
+// ReadFixed8Dot8 reads a signed 8.8 number (ISO-BMFF volume).
+func (br *Reader) ReadFixed8Dot8(e Endian) float64 {
+	return br.ReadFixed(8, 8, true, e)
+}
+
+// ReadFixed16Dot16 reads a signed 16.16 number (ISO-BMFF matrix, TrueType Fixed).
+func (br *Reader) ReadFixed16Dot16(e Endian) float64 {
+	return br.ReadFixed(16, 16, true, e)
+}
+
+// ReadUFixed16Dot16 reads an unsigned 16.16 number (ISO-BMFF width, height and rate).
+func (br *Reader) ReadUFixed16Dot16(e Endian) float64 {
+	return br.ReadFixed(16, 16, false, e)
+}
+
+// ReadFixed2Dot30 reads a signed 2.30 number (ISO-BMFF matrix u, v, w).
+func (br *Reader) ReadFixed2Dot30(e Endian) float64 {
+	return br.ReadFixed(2, 30, true, e)
+}
+
+// ReadF2Dot14 reads a signed 2.14 number (TrueType F2Dot14).
+func (br *Reader) ReadF2Dot14(e Endian) float64 {
+	return br.ReadFixed(2, 14, true, e)
+}
+
+// WriteFixed8Dot8 ...
+func (bw *Writer) WriteFixed8Dot8(v float64, e Endian) int {
+	return bw.WriteFixed(v, 8, 8, true, RoundNearestEven, e)
+}
+
+// WriteFixed16Dot16 ...
+func (bw *Writer) WriteFixed16Dot16(v float64, e Endian) int {
+	return bw.WriteFixed(v, 16, 16, true, RoundNearestEven, e)
+}
+
+// WriteUFixed16Dot16 ...
+func (bw *Writer) WriteUFixed16Dot16(v float64, e Endian) int {
+	return bw.WriteFixed(v, 16, 16, false, RoundNearestEven, e)
+}
+
+// WriteFixed2Dot30 ...
+func (bw *Writer) WriteFixed2Dot30(v float64, e Endian) int {
+	return bw.WriteFixed(v, 2, 30, true, RoundNearestEven, e)
+}
+
+// WriteF2Dot14 ...
+func (bw *Writer) WriteF2Dot14(v float64, e Endian) int {
+	return bw.WriteFixed(v, 2, 14, true, RoundNearestEven, e)
+}
@@ -0,0 +1,89 @@
+package binaryio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFixed(t *testing.T) {
+	// -----------------------------
+	// ReadFixed
+	// -----------------------------
+	{
+		r := NewReader(bytes.NewReader([]byte{
+			0x00, 0x01, 0x00, 0x00, // 16.16 1.0
+			0x40, 0x00, 0x00, 0x00, // 2.30 1.0
+			0x01, 0x00, // 8.8 1.0
+			0xC0, 0x00, // F2Dot14 -1.0
+			0x70, 0x00, // F2Dot14 1.75
+			0xFF, 0xFF, 0x80, 0x00, // 16.16 -0.5
+			0xFF, 0xFF, 0x80, 0x00, // u16.16 65535.5
+		}))
+		if v := r.ReadFixed16Dot16(BigEndian); v != 1 {
+			t.Fatalf("Invalid ReadFixed16Dot16 %v", v)
+		}
+		if v := r.ReadFixed2Dot30(BigEndian); v != 1 {
+			t.Fatalf("Invalid ReadFixed2Dot30 %v", v)
+		}
+		if v := r.ReadFixed8Dot8(BigEndian); v != 1 {
+			t.Fatalf("Invalid ReadFixed8Dot8 %v", v)
+		}
+		if v := r.ReadF2Dot14(BigEndian); v != -1 {
+			t.Fatalf("Invalid ReadF2Dot14 %v", v)
+		}
+		if v := r.ReadF2Dot14(BigEndian); v != 1.75 {
+			t.Fatalf("Invalid ReadF2Dot14 %v", v)
+		}
+		if v := r.ReadFixedRat(16, 16, true, BigEndian); v.RatString() != "-1/2" {
+			t.Fatalf("Invalid ReadFixedRat %v", v)
+		}
+		if v := r.ReadUFixed16Dot16(BigEndian); v != 65535.5 {
+			t.Fatalf("Invalid ReadUFixed16Dot16 %v", v)
+		}
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		r.ReadFixed(3, 3, true, BigEndian)
+		if r.Err() != ErrInvalidWidth {
+			t.Fatalf("Invalid ReadFixed width error %v", r.Err())
+		}
+	}
+	// -----------------------------
+	// WriteFixed
+	// -----------------------------
+	{
+		testFileName := "test.bin"
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw)
+		w.WriteFixed16Dot16(-1.5, LittleEndian)
+		w.WriteFixed(1.0/3, 8, 8, false, RoundDown, BigEndian)
+		w.WriteFixed(1.0/3, 8, 8, false, RoundUp, BigEndian)
+		w.WriteFixed(0.5/256, 8, 8, false, RoundNearestEven, BigEndian)
+		w.WriteFixed(0.5/256, 8, 8, false, RoundNearestAway, BigEndian)
+		w.WriteF2Dot14(-2, BigEndian)
+		if w.Err() != nil {
+			t.Fatal(w.Err())
+		}
+		if w.WriteF2Dot14(2, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("WriteF2Dot14 must overflow")
+		}
+		fw.Close()
+
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		if v := r.ReadFixed16Dot16(LittleEndian); v != -1.5 {
+			t.Fatalf("Invalid WriteFixed16Dot16 %v", v)
+		}
+		for _, want := range []uint16{0x55, 0x56, 0x00, 0x01} {
+			if v := r.ReadU16(BigEndian); v != want {
+				t.Fatalf("Invalid WriteFixed rounding %x != %x", v, want)
+			}
+		}
+		if v := r.ReadF2Dot14(BigEndian); v != -2 {
+			t.Fatalf("Invalid WriteF2Dot14 %v", v)
+		}
+		fr.Close()
+
+		removeFile(testFileName, t)
+	}
+}
@@ -0,0 +1,264 @@
+package binaryio
+
+import (
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+)
+
+// Hash16 is the common interface implemented by all 16-bit hash functions.
+type Hash16 interface {
+	hash.Hash
+	Sum16() uint16
+}
+
+// CRC16Params describes a CRC-16 variant in the Rocksoft model.
+type CRC16Params struct {
+	Poly   uint16
+	Init   uint16
+	RefIn  bool
+	RefOut bool
+	XorOut uint16
+}
+
+// Predefined CRC-16 variants.
+var (
+	CRC16CCITTFalse = CRC16Params{Poly: 0x1021, Init: 0xFFFF, XorOut: 0x0000}
+	CRC16XModem     = CRC16Params{Poly: 0x1021, Init: 0x0000, XorOut: 0x0000}
+	CRC16Kermit     = CRC16Params{Poly: 0x1021, Init: 0x0000, RefIn: true, RefOut: true, XorOut: 0x0000}
+	CRC16X25        = CRC16Params{Poly: 0x1021, Init: 0xFFFF, RefIn: true, RefOut: true, XorOut: 0xFFFF}
+	CRC16ARC        = CRC16Params{Poly: 0x8005, Init: 0x0000, RefIn: true, RefOut: true, XorOut: 0x0000}
+	CRC16Modbus     = CRC16Params{Poly: 0x8005, Init: 0xFFFF, RefIn: true, RefOut: true, XorOut: 0x0000}
+)
+
+// NewCRC32IEEE returns a CRC-32 hash using the IEEE polynomial (PNG, zip, gzip).
+func NewCRC32IEEE() hash.Hash32 {
+	return crc32.NewIEEE()
+}
+
+// NewCRC32C returns a CRC-32 hash using the Castagnoli polynomial.
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+}
+
+// NewCRC32MPEG2 returns the non-reflected CRC-32/MPEG-2 hash used by MPEG-TS sections.
+func NewCRC32MPEG2() hash.Hash32 {
+	return &crc32MPEG2{crc: 0xFFFFFFFF}
+}
+
+// NewAdler32 returns an Adler-32 hash.
+func NewAdler32() hash.Hash32 {
+	return adler32.New()
+}
+
+// NewCRC16 returns a CRC-16 hash for the given variant.
+func NewCRC16(p CRC16Params) Hash16 {
+	c := &crc16{params: p}
+	for i := 0; i < 256; i++ {
+		c.table[i] = crc16Entry(p, uint16(i))
+	}
+	c.Reset()
+	return c
+}
+
+// NewFletcher16 returns a Fletcher-16 checksum.
+func NewFletcher16() Hash16 {
+	return &fletcher16{}
+}
+
+// NewFletcher32 returns a Fletcher-32 checksum over little-endian 16-bit words.
+// An odd trailing byte is padded with zero.
+func NewFletcher32() hash.Hash32 {
+	return &fletcher32{}
+}
+
+// -----------------------------
+// CRC-16
+// -----------------------------
+
+type crc16 struct {
+	params CRC16Params
+	table  [256]uint16
+	crc    uint16
+}
+
+func reflect16(v uint16) uint16 {
+	var r uint16
+	for i := uint(0); i < 16; i++ {
+		if v&(1<<i) != 0 {
+			r |= 1 << (15 - i)
+		}
+	}
+	return r
+}
+
+func crc16Entry(p CRC16Params, i uint16) uint16 {
+	if p.RefIn {
+		poly := reflect16(p.Poly)
+		crc := i
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+		return crc
+	}
+	crc := i << 8
+	for j := 0; j < 8; j++ {
+		if crc&0x8000 != 0 {
+			crc = crc<<1 ^ p.Poly
+		} else {
+			crc <<= 1
+		}
+	}
+	return crc
+}
+
+func (c *crc16) Reset() {
+	c.crc = c.params.Init
+	if c.params.RefIn {
+		c.crc = reflect16(c.crc)
+	}
+}
+
+func (c *crc16) Size() int      { return 2 }
+func (c *crc16) BlockSize() int { return 1 }
+
+func (c *crc16) Write(p []byte) (int, error) {
+	crc := c.crc
+	if c.params.RefIn {
+		for _, b := range p {
+			crc = crc>>8 ^ c.table[byte(crc)^b]
+		}
+	} else {
+		for _, b := range p {
+			crc = crc<<8 ^ c.table[byte(crc>>8)^b]
+		}
+	}
+	c.crc = crc
+	return len(p), nil
+}
+
+func (c *crc16) Sum16() uint16 {
+	crc := c.crc
+	if c.params.RefIn != c.params.RefOut {
+		crc = reflect16(crc)
+	}
+	return crc ^ c.params.XorOut
+}
+
+func (c *crc16) Sum(in []byte) []byte {
+	s := c.Sum16()
+	return append(in, byte(s>>8), byte(s))
+}
+
+// -----------------------------
+// CRC-32/MPEG-2
+// -----------------------------
+
+var crc32MPEG2Table = func() (t [256]uint32) {
+	for i := range t {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+type crc32MPEG2 struct {
+	crc uint32
+}
+
+func (c *crc32MPEG2) Reset()         { c.crc = 0xFFFFFFFF }
+func (c *crc32MPEG2) Size() int      { return 4 }
+func (c *crc32MPEG2) BlockSize() int { return 1 }
+func (c *crc32MPEG2) Sum32() uint32  { return c.crc }
+
+func (c *crc32MPEG2) Write(p []byte) (int, error) {
+	crc := c.crc
+	for _, b := range p {
+		crc = crc<<8 ^ crc32MPEG2Table[byte(crc>>24)^b]
+	}
+	c.crc = crc
+	return len(p), nil
+}
+
+func (c *crc32MPEG2) Sum(in []byte) []byte {
+	s := c.crc
+	return append(in, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+// -----------------------------
+// Fletcher
+// -----------------------------
+
+type fletcher16 struct {
+	s1, s2 uint16
+}
+
+func (f *fletcher16) Reset()         { f.s1, f.s2 = 0, 0 }
+func (f *fletcher16) Size() int      { return 2 }
+func (f *fletcher16) BlockSize() int { return 1 }
+func (f *fletcher16) Sum16() uint16  { return f.s2<<8 | f.s1 }
+
+func (f *fletcher16) Write(p []byte) (int, error) {
+	for _, b := range p {
+		f.s1 = (f.s1 + uint16(b)) % 255
+		f.s2 = (f.s2 + f.s1) % 255
+	}
+	return len(p), nil
+}
+
+func (f *fletcher16) Sum(in []byte) []byte {
+	s := f.Sum16()
+	return append(in, byte(s>>8), byte(s))
+}
+
+type fletcher32 struct {
+	s1, s2  uint32
+	pending bool
+	low     byte
+}
+
+func (f *fletcher32) Reset()         { *f = fletcher32{} }
+func (f *fletcher32) Size() int      { return 4 }
+func (f *fletcher32) BlockSize() int { return 2 }
+
+func (f *fletcher32) add(w uint16) {
+	f.s1 = (f.s1 + uint32(w)) % 65535
+	f.s2 = (f.s2 + f.s1) % 65535
+}
+
+func (f *fletcher32) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if f.pending {
+			f.add(uint16(b)<<8 | uint16(f.low))
+			f.pending = false
+		} else {
+			f.low = b
+			f.pending = true
+		}
+	}
+	return len(p), nil
+}
+
+func (f *fletcher32) Sum32() uint32 {
+	t := *f
+	if t.pending {
+		t.add(uint16(t.low))
+	}
+	return t.s2<<16 | t.s1
+}
+
+func (f *fletcher32) Sum(in []byte) []byte {
+	s := f.Sum32()
+	return append(in, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
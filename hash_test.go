@@ -0,0 +1,117 @@
+package binaryio
+
+import (
+	"bytes"
+	"hash"
+	"hash/crc32"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	check := []byte("123456789")
+
+	// -----------------------------
+	// CRC-16
+	// -----------------------------
+	{
+		tests := []struct {
+			name   string
+			params CRC16Params
+			want   uint16
+		}{
+			{"CCITT-FALSE", CRC16CCITTFalse, 0x29B1},
+			{"XMODEM", CRC16XModem, 0x31C3},
+			{"KERMIT", CRC16Kermit, 0x2189},
+			{"X-25", CRC16X25, 0x906E},
+			{"ARC", CRC16ARC, 0xBB3D},
+			{"MODBUS", CRC16Modbus, 0x4B37},
+		}
+		for _, tt := range tests {
+			h := NewCRC16(tt.params)
+			h.Write(check)
+			if h.Sum16() != tt.want {
+				t.Fatalf("Invalid CRC16 %s: %04x", tt.name, h.Sum16())
+			}
+		}
+	}
+	// -----------------------------
+	// CRC-32 / Adler-32
+	// -----------------------------
+	{
+		tests := []struct {
+			name string
+			h    hash.Hash32
+			want uint32
+		}{
+			{"IEEE", NewCRC32IEEE(), 0xCBF43926},
+			{"Castagnoli", NewCRC32C(), 0xE3069283},
+			{"MPEG-2", NewCRC32MPEG2(), 0x0376E6E7},
+			{"Adler-32", NewAdler32(), 0x091E01DE},
+		}
+		for _, tt := range tests {
+			tt.h.Write(check)
+			if tt.h.Sum32() != tt.want {
+				t.Fatalf("Invalid %s: %08x", tt.name, tt.h.Sum32())
+			}
+		}
+	}
+	// -----------------------------
+	// Fletcher
+	// -----------------------------
+	{
+		h := NewFletcher16()
+		h.Write([]byte("abcdef"))
+		if h.Sum16() != 0x2057 {
+			t.Fatalf("Invalid Fletcher16: %04x", h.Sum16())
+		}
+	}
+	{
+		h := NewFletcher32()
+		h.Write([]byte("abc"))
+		h.Write([]byte("de"))
+		if h.Sum32() != 0xF04FC729 {
+			t.Fatalf("Invalid Fletcher32: %08x", h.Sum32())
+		}
+		h.Write([]byte("fgh"))
+		if h.Sum32() != 0xEBE19591 {
+			t.Fatalf("Invalid Fletcher32: %08x", h.Sum32())
+		}
+	}
+}
+
+func TestWriterHash(t *testing.T) {
+	testFileName := "test.bin"
+
+	fw := openWriteFile(testFileName, t)
+	w := NewWriter(fw)
+	w.WriteU32(4, BigEndian)
+	w.StartHash(NewCRC32IEEE())
+	w.WriteS32("IEND", BigEndian)
+	w.WriteRaw([]byte{1, 2, 3, 4})
+	crc := w.SumHash32()
+	w.WriteU32(crc, BigEndian)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	if crc != crc32.ChecksumIEEE([]byte{'I', 'E', 'N', 'D', 1, 2, 3, 4}) {
+		t.Fatalf("Invalid Writer CRC %08x", crc)
+	}
+	fw.Close()
+
+	fr := openReadFile(testFileName, t)
+	r := NewReader(fr)
+	r.ReadU32(BigEndian)
+	r.StartHash(NewCRC32IEEE())
+	r.ReadS32(BigEndian)
+	r.ReadU32(BigEndian)
+	sum := r.SumHash()
+	if r.ReadU32(BigEndian) != crc {
+		t.Fatalf("Invalid Reader CRC")
+	}
+	if !bytes.Equal(sum, []byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}) {
+		t.Fatalf("Invalid Reader SumHash %x", sum)
+	}
+	fr.Close()
+
+	removeFile(testFileName, t)
+}
@@ -0,0 +1,65 @@
+package binaryio
+
+import (
+	"math/big"
+)
+
+// U128 is an unsigned 128-bit integer split into high and low halves.
+type U128 struct {
+	Hi uint64
+	Lo uint64
+}
+
+// Big returns v as a big.Int.
+func (v U128) Big() *big.Int {
+	b := new(big.Int).SetUint64(v.Hi)
+	b.Lsh(b, 64)
+	return b.Or(b, new(big.Int).SetUint64(v.Lo))
+}
+
+// String returns the decimal representation of v.
+func (v U128) String() string {
+	return v.Big().String()
+}
+
+// ReadU128 ...
+func (br *Reader) ReadU128(e Endian) U128 {
+	if br.err != nil {
+		return U128{}
+	}
+	var v U128
+	if e == LittleEndian {
+		v.Lo = br.ReadU64(e)
+		v.Hi = br.ReadU64(e)
+	} else {
+		v.Hi = br.ReadU64(e)
+		v.Lo = br.ReadU64(e)
+	}
+	if br.err != nil {
+		return U128{}
+	}
+	return v
+}
+
+// ReadU128Big is like ReadU128 but returns a big.Int.
+func (br *Reader) ReadU128Big(e Endian) *big.Int {
+	if br.err != nil {
+		return nil
+	}
+	v := br.ReadU128(e)
+	if br.err != nil {
+		return nil
+	}
+	return v.Big()
+}
+
+// WriteU128 ...
+func (bw *Writer) WriteU128(v U128, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	if e == LittleEndian {
+		return bw.WriteU64(v.Lo, e) + bw.WriteU64(v.Hi, e)
+	}
+	return bw.WriteU64(v.Hi, e) + bw.WriteU64(v.Lo, e)
+}
@@ -0,0 +1,14 @@
+// Package ascii holds string checks shared by the archive format packages.
+package ascii
+
+import "unicode/utf8"
+
+// ValidString reports whether s consists entirely of ASCII characters.
+func ValidString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
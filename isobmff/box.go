@@ -0,0 +1,171 @@
+// Package isobmff walks and rewrites ISO Base Media File Format (MP4, MOV)
+// box trees.
+package isobmff
+
+import (
+	"errors"
+	"io"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the isobmff package.
+var (
+	ErrFormat         = errors.New("isobmff: invalid box")
+	ErrNotFound       = errors.New("isobmff: box not found")
+	ErrOffsetOverflow = errors.New("isobmff: chunk offset does not fit in stco")
+)
+
+// Box is a single box. Offset is the position of the box header in the source
+// and Size covers the header and the payload.
+type Box struct {
+	Type       string
+	Offset     int64
+	Size       uint64
+	HeaderSize int
+	UserType   bio.UUID // only for "uuid" boxes
+	src        io.ReaderAt
+}
+
+// DataOffset returns the position of the box payload.
+func (b *Box) DataOffset() int64 {
+	return b.Offset + int64(b.HeaderSize)
+}
+
+// DataSize returns the size of the box payload.
+func (b *Box) DataSize() uint64 {
+	return b.Size - uint64(b.HeaderSize)
+}
+
+// Data returns a reader over the box payload.
+func (b *Box) Data() *io.SectionReader {
+	return io.NewSectionReader(b.src, b.DataOffset(), int64(b.DataSize()))
+}
+
+func (b *Box) reader() *bio.Reader {
+	br := bio.NewReader(b.src)
+	br.SetOffset(b.DataOffset())
+	return br
+}
+
+// Reader iterates over sibling boxes in a byte range.
+type Reader struct {
+	src io.ReaderAt
+	br  *bio.Reader
+	end int64
+}
+
+// NewReader returns a Reader over the top-level boxes of a file of the given size.
+func NewReader(r io.ReaderAt, size int64) *Reader {
+	return &Reader{src: r, br: bio.NewReader(r), end: size}
+}
+
+// containers maps container box types to the number of bytes preceding their children.
+var containers = map[string]int{
+	"moov": 0, "trak": 0, "edts": 0, "mdia": 0, "minf": 0, "dinf": 0,
+	"stbl": 0, "mvex": 0, "moof": 0, "traf": 0, "mfra": 0, "udta": 0,
+	"meta": 4, "stsd": 8,
+}
+
+// IsContainer reports whether boxes of type typ hold child boxes.
+func IsContainer(typ string) bool {
+	_, ok := containers[typ]
+	return ok
+}
+
+// Children returns a Reader over the child boxes of b.
+func Children(b *Box) *Reader {
+	start := b.DataOffset() + int64(containers[b.Type])
+	br := bio.NewReader(b.src)
+	br.SetOffset(start)
+	return &Reader{src: b.src, br: br, end: b.Offset + int64(b.Size)}
+}
+
+// Next returns the next box, or io.EOF when the range is exhausted.
+func (r *Reader) Next() (*Box, error) {
+	br := r.br
+	off := br.GetOffset()
+	if off+8 > r.end {
+		return nil, io.EOF
+	}
+	b := &Box{Offset: off, HeaderSize: 8, src: r.src}
+	b.Size = uint64(br.ReadU32(bio.BigEndian))
+	b.Type = br.ReadS32(bio.BigEndian)
+	switch b.Size {
+	case 0:
+		b.Size = uint64(r.end - off)
+	case 1:
+		b.Size = br.ReadU64(bio.BigEndian)
+		b.HeaderSize += 8
+	}
+	if b.Type == "uuid" {
+		b.UserType = br.ReadUUID()
+		b.HeaderSize += 16
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if b.Size < uint64(b.HeaderSize) || b.Size > uint64(r.end-off) {
+		return nil, ErrFormat
+	}
+	br.SetOffset(off + int64(b.Size))
+	return b, nil
+}
+
+// WalkFunc is called for every box with the path of its ancestors.
+type WalkFunc func(path []*Box, b *Box) error
+
+// Walk visits the box tree of a file of the given size depth-first.
+func Walk(r io.ReaderAt, size int64, fn WalkFunc) error {
+	return walk(NewReader(r, size), nil, fn)
+}
+
+func walk(r *Reader, path []*Box, fn WalkFunc) error {
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(path, b); err != nil {
+			return err
+		}
+		if IsContainer(b.Type) {
+			if err := walk(Children(b), append(path[:len(path):len(path)], b), fn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Find returns the first box matching the type path, e.g. "moov", "trak", "tkhd".
+func Find(r *Reader, types ...string) (*Box, error) {
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b.Type != types[0] {
+			continue
+		}
+		if len(types) == 1 {
+			return b, nil
+		}
+		if c, err := Find(Children(b), types[1:]...); err != ErrNotFound {
+			return c, err
+		}
+	}
+}
+
+// ReadFullBoxHeader reads the version and flags of a full box.
+func ReadFullBoxHeader(b *Box) (version uint8, flags uint32, err error) {
+	br := b.reader()
+	version = br.ReadU8()
+	flags = br.ReadU24(bio.BigEndian)
+	return version, flags, br.Err()
+}
@@ -0,0 +1,261 @@
+package isobmff
+
+import (
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Ftyp is a file type box.
+type Ftyp struct {
+	MajorBrand       string
+	MinorVersion     uint32
+	CompatibleBrands []string
+}
+
+// ParseFtyp decodes an ftyp box.
+func ParseFtyp(b *Box) (*Ftyp, error) {
+	if b.Type != "ftyp" || b.DataSize() < 8 {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	f := &Ftyp{}
+	f.MajorBrand = br.ReadS32(bio.BigEndian)
+	f.MinorVersion = br.ReadU32(bio.BigEndian)
+	for i := uint64(8); i+4 <= b.DataSize(); i += 4 {
+		f.CompatibleBrands = append(f.CompatibleBrands, br.ReadS32(bio.BigEndian))
+	}
+	return f, br.Err()
+}
+
+// Matrix is a transformation matrix {a, b, u, c, d, v, x, y, w}.
+type Matrix [9]float64
+
+func readMatrix(br *bio.Reader) Matrix {
+	var m Matrix
+	for i := range m {
+		if i%3 == 2 {
+			m[i] = br.ReadFixed2Dot30(bio.BigEndian)
+		} else {
+			m[i] = br.ReadFixed16Dot16(bio.BigEndian)
+		}
+	}
+	return m
+}
+
+func readTimes(br *bio.Reader, version uint8) (created, modified time.Time) {
+	kind := bio.TimeMP4V0
+	if version == 1 {
+		kind = bio.TimeMP4V1
+	}
+	created = br.ReadTime(kind, bio.BigEndian)
+	modified = br.ReadTime(kind, bio.BigEndian)
+	return created, modified
+}
+
+func readDuration(br *bio.Reader, version uint8) uint64 {
+	if version == 1 {
+		return br.ReadU64(bio.BigEndian)
+	}
+	return uint64(br.ReadU32(bio.BigEndian))
+}
+
+// Mvhd is a movie header box.
+type Mvhd struct {
+	Version          uint8
+	Flags            uint32
+	CreationTime     time.Time
+	ModificationTime time.Time
+	Timescale        uint32
+	Duration         uint64
+	Rate             float64
+	Volume           float64
+	Matrix           Matrix
+	NextTrackID      uint32
+}
+
+// ParseMvhd decodes an mvhd box.
+func ParseMvhd(b *Box) (*Mvhd, error) {
+	if b.Type != "mvhd" {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	m := &Mvhd{}
+	m.Version = br.ReadU8()
+	m.Flags = br.ReadU24(bio.BigEndian)
+	m.CreationTime, m.ModificationTime = readTimes(br, m.Version)
+	m.Timescale = br.ReadU32(bio.BigEndian)
+	m.Duration = readDuration(br, m.Version)
+	m.Rate = br.ReadFixed16Dot16(bio.BigEndian)
+	m.Volume = br.ReadFixed8Dot8(bio.BigEndian)
+	br.ReadRaw(10) // reserved
+	m.Matrix = readMatrix(br)
+	br.ReadRaw(24) // pre_defined
+	m.NextTrackID = br.ReadU32(bio.BigEndian)
+	return m, br.Err()
+}
+
+// Tkhd is a track header box.
+type Tkhd struct {
+	Version          uint8
+	Flags            uint32
+	CreationTime     time.Time
+	ModificationTime time.Time
+	TrackID          uint32
+	Duration         uint64
+	Layer            int16
+	AlternateGroup   int16
+	Volume           float64
+	Matrix           Matrix
+	Width            float64
+	Height           float64
+}
+
+// ParseTkhd decodes a tkhd box.
+func ParseTkhd(b *Box) (*Tkhd, error) {
+	if b.Type != "tkhd" {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	t := &Tkhd{}
+	t.Version = br.ReadU8()
+	t.Flags = br.ReadU24(bio.BigEndian)
+	t.CreationTime, t.ModificationTime = readTimes(br, t.Version)
+	t.TrackID = br.ReadU32(bio.BigEndian)
+	br.ReadU32(bio.BigEndian) // reserved
+	t.Duration = readDuration(br, t.Version)
+	br.ReadRaw(8) // reserved
+	t.Layer = br.ReadI16(bio.BigEndian)
+	t.AlternateGroup = br.ReadI16(bio.BigEndian)
+	t.Volume = br.ReadFixed8Dot8(bio.BigEndian)
+	br.ReadU16(bio.BigEndian) // reserved
+	t.Matrix = readMatrix(br)
+	t.Width = br.ReadUFixed16Dot16(bio.BigEndian)
+	t.Height = br.ReadUFixed16Dot16(bio.BigEndian)
+	return t, br.Err()
+}
+
+// SampleEntry is an entry of an stsd box. Box covers the whole entry.
+type SampleEntry struct {
+	Format             string
+	DataReferenceIndex uint16
+	Box                *Box
+}
+
+// ParseStsd decodes the sample entries of an stsd box.
+func ParseStsd(b *Box) ([]SampleEntry, error) {
+	if b.Type != "stsd" || b.DataSize() < 8 {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	br.ReadU32(bio.BigEndian) // version and flags
+	n := br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if uint64(n)*8 > b.DataSize()-8 {
+		return nil, ErrFormat // each entry is at least a box header
+	}
+	r := Children(b)
+	entries := make([]SampleEntry, 0, n)
+	for i := uint32(0); i < n; i++ {
+		e, err := r.Next()
+		if err != nil {
+			return nil, ErrFormat
+		}
+		er := e.reader()
+		er.ReadRaw(6) // reserved
+		entries = append(entries, SampleEntry{e.Type, er.ReadU16(bio.BigEndian), e})
+		if er.Err() != nil {
+			return nil, er.Err()
+		}
+	}
+	return entries, nil
+}
+
+// SttsEntry is a run of samples with the same duration.
+type SttsEntry struct {
+	Count uint32
+	Delta uint32
+}
+
+// ParseStts decodes a decoding time-to-sample box.
+func ParseStts(b *Box) ([]SttsEntry, error) {
+	if b.Type != "stts" || b.DataSize() < 8 {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	br.ReadU32(bio.BigEndian) // version and flags
+	n := br.ReadU32(bio.BigEndian)
+	if br.Err() != nil || uint64(n)*8 > b.DataSize()-8 {
+		return nil, ErrFormat
+	}
+	raw := make([]uint32, 2*n)
+	br.ReadU32s(raw, bio.BigEndian)
+	entries := make([]SttsEntry, n)
+	for i := range entries {
+		entries[i] = SttsEntry{raw[2*i], raw[2*i+1]}
+	}
+	return entries, br.Err()
+}
+
+// Stsz is a sample size box. Sizes is empty when all samples have SampleSize.
+type Stsz struct {
+	SampleSize  uint32
+	SampleCount uint32
+	Sizes       []uint32
+}
+
+// ParseStsz decodes an stsz box.
+func ParseStsz(b *Box) (*Stsz, error) {
+	if b.Type != "stsz" || b.DataSize() < 12 {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	br.ReadU32(bio.BigEndian) // version and flags
+	s := &Stsz{}
+	s.SampleSize = br.ReadU32(bio.BigEndian)
+	s.SampleCount = br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if s.SampleSize == 0 {
+		if uint64(s.SampleCount)*4 > b.DataSize()-12 {
+			return nil, ErrFormat
+		}
+		s.Sizes = make([]uint32, s.SampleCount)
+		br.ReadU32s(s.Sizes, bio.BigEndian)
+	}
+	return s, br.Err()
+}
+
+// ParseChunkOffsets decodes an stco or co64 box.
+func ParseChunkOffsets(b *Box) ([]uint64, error) {
+	if b.Type != "stco" && b.Type != "co64" || b.DataSize() < 8 {
+		return nil, ErrFormat
+	}
+	br := b.reader()
+	br.ReadU32(bio.BigEndian) // version and flags
+	n := br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if b.Type == "co64" {
+		if uint64(n)*8 > b.DataSize()-8 {
+			return nil, ErrFormat
+		}
+		offsets := make([]uint64, n)
+		br.ReadU64s(offsets, bio.BigEndian)
+		return offsets, br.Err()
+	}
+	if uint64(n)*4 > b.DataSize()-8 {
+		return nil, ErrFormat
+	}
+	offsets := make([]uint64, n)
+	raw := make([]uint32, n)
+	br.ReadU32s(raw, bio.BigEndian)
+	for i, v := range raw {
+		offsets[i] = uint64(v)
+	}
+	return offsets, br.Err()
+}
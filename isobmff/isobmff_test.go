@@ -0,0 +1,383 @@
+package isobmff
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+func begin(w *bio.Writer, typ string) int64 {
+	start := w.GetOffset()
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteS32(typ, bio.BigEndian)
+	return start
+}
+
+func end(w *bio.Writer, start int64) {
+	off := w.GetOffset()
+	w.SetOffset(start)
+	w.WriteU32(uint32(off-start), bio.BigEndian)
+	w.SetOffset(off)
+}
+
+var created = time.Date(2020, 9, 7, 0, 0, 0, 0, time.UTC)
+
+// writeMovie writes ftyp, free (largesize), mdat and a single-track moov.
+func writeMovie(t *testing.T, name string) *os.File {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bio.NewWriter(fw)
+
+	s := begin(w, "ftyp")
+	w.WriteS32("isom", bio.BigEndian)
+	w.WriteU32(512, bio.BigEndian)
+	w.WriteS32("isom", bio.BigEndian)
+	w.WriteS32("avc1", bio.BigEndian)
+	end(w, s)
+
+	w.WriteU32(1, bio.BigEndian)
+	w.WriteS32("free", bio.BigEndian)
+	w.WriteU64(20, bio.BigEndian)
+	w.WriteU32(0, bio.BigEndian)
+
+	s = begin(w, "mdat")
+	chunk := w.GetOffset()
+	w.WriteRaw([]byte("AAAABBBBBBCC"))
+	end(w, s)
+
+	moov := begin(w, "moov")
+	s = begin(w, "mvhd")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteTime(created, bio.TimeMP4V0, bio.BigEndian)
+	w.WriteTime(created, bio.TimeMP4V0, bio.BigEndian)
+	w.WriteU32(1000, bio.BigEndian)
+	w.WriteU32(3000, bio.BigEndian)
+	w.WriteFixed16Dot16(1, bio.BigEndian)
+	w.WriteFixed8Dot8(1, bio.BigEndian)
+	w.WriteRaw(make([]byte, 10))
+	for i, v := range []float64{1, 0, 0, 0, 1, 0, 0, 0, 1} {
+		if i%3 == 2 {
+			w.WriteFixed2Dot30(v, bio.BigEndian)
+		} else {
+			w.WriteFixed16Dot16(v, bio.BigEndian)
+		}
+	}
+	w.WriteRaw(make([]byte, 24))
+	w.WriteU32(2, bio.BigEndian)
+	end(w, s)
+
+	trak := begin(w, "trak")
+	s = begin(w, "tkhd")
+	w.WriteU32(3, bio.BigEndian)
+	w.WriteTime(created, bio.TimeMP4V0, bio.BigEndian)
+	w.WriteTime(created, bio.TimeMP4V0, bio.BigEndian)
+	w.WriteU32(1, bio.BigEndian)
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(3000, bio.BigEndian)
+	w.WriteRaw(make([]byte, 8))
+	w.WriteI16(0, bio.BigEndian)
+	w.WriteI16(0, bio.BigEndian)
+	w.WriteFixed8Dot8(0, bio.BigEndian)
+	w.WriteU16(0, bio.BigEndian)
+	w.WriteRaw(make([]byte, 36))
+	w.WriteUFixed16Dot16(1920, bio.BigEndian)
+	w.WriteUFixed16Dot16(1080, bio.BigEndian)
+	end(w, s)
+	mdia := begin(w, "mdia")
+	minf := begin(w, "minf")
+	stbl := begin(w, "stbl")
+	s = begin(w, "stsd")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(1, bio.BigEndian)
+	e := begin(w, "avc1")
+	w.WriteRaw(make([]byte, 6))
+	w.WriteU16(1, bio.BigEndian)
+	end(w, e)
+	end(w, s)
+	s = begin(w, "stts")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(1, bio.BigEndian)
+	w.WriteU32s([]uint32{3, 1000}, bio.BigEndian)
+	end(w, s)
+	s = begin(w, "stsz")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(3, bio.BigEndian)
+	w.WriteU32s([]uint32{4, 6, 2}, bio.BigEndian)
+	end(w, s)
+	s = begin(w, "stco")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(2, bio.BigEndian)
+	w.WriteU32s([]uint32{uint32(chunk), uint32(chunk + 4)}, bio.BigEndian)
+	end(w, s)
+	s = begin(w, "co64")
+	w.WriteU32(0, bio.BigEndian)
+	w.WriteU32(1, bio.BigEndian)
+	w.WriteU64(uint64(chunk+10), bio.BigEndian)
+	end(w, s)
+	end(w, stbl)
+	end(w, minf)
+	end(w, mdia)
+	end(w, trak)
+	end(w, moov)
+
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	return fw
+}
+
+func chunks(t *testing.T, f *os.File) []string {
+	fi, _ := f.Stat()
+	var out []string
+	err := Walk(f, fi.Size(), func(path []*Box, b *Box) error {
+		if b.Type != "stco" && b.Type != "co64" {
+			return nil
+		}
+		offsets, err := ParseChunkOffsets(b)
+		if err != nil {
+			return err
+		}
+		for _, off := range offsets {
+			p := make([]byte, 2)
+			f.ReadAt(p, int64(off))
+			out = append(out, string(p))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// eofReaderAt returns io.EOF with reads that end at size.
+type eofReaderAt struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (e eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := e.r.ReadAt(p, off)
+	if err == nil && off+int64(n) == e.size {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestWalk(t *testing.T) {
+	testFileName := "test.mp4"
+	defer os.Remove(testFileName)
+	f := writeMovie(t, testFileName)
+	defer f.Close()
+	fi, _ := f.Stat()
+
+	var paths []string
+	err := Walk(f, fi.Size(), func(path []*Box, b *Box) error {
+		var p []string
+		for _, a := range path {
+			p = append(p, a.Type)
+		}
+		paths = append(paths, strings.Join(append(p, b.Type), "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ftyp free mdat moov moov/mvhd moov/trak moov/trak/tkhd moov/trak/mdia moov/trak/mdia/minf " +
+		"moov/trak/mdia/minf/stbl moov/trak/mdia/minf/stbl/stsd moov/trak/mdia/minf/stbl/stsd/avc1 " +
+		"moov/trak/mdia/minf/stbl/stts moov/trak/mdia/minf/stbl/stsz moov/trak/mdia/minf/stbl/stco " +
+		"moov/trak/mdia/minf/stbl/co64"
+	if strings.Join(paths, " ") != want {
+		t.Fatalf("Invalid walk %v", paths)
+	}
+
+	{
+		b, err := Find(NewReader(f, fi.Size()), "ftyp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ftyp, err := ParseFtyp(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ftyp.MajorBrand != "isom" || ftyp.MinorVersion != 512 || len(ftyp.CompatibleBrands) != 2 {
+			t.Fatalf("Invalid ftyp %+v", ftyp)
+		}
+	}
+	{
+		b, err := Find(NewReader(f, fi.Size()), "free")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Size != 20 || b.HeaderSize != 16 {
+			t.Fatalf("Invalid largesize box %+v", b)
+		}
+	}
+	{
+		b, err := Find(NewReader(f, fi.Size()), "moov", "mvhd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ParseMvhd(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.CreationTime.Equal(created) || m.Timescale != 1000 || m.Duration != 3000 ||
+			m.Rate != 1 || m.Volume != 1 || m.Matrix != (Matrix{1, 0, 0, 0, 1, 0, 0, 0, 1}) || m.NextTrackID != 2 {
+			t.Fatalf("Invalid mvhd %+v", m)
+		}
+	}
+	{
+		b, err := Find(NewReader(f, fi.Size()), "moov", "trak", "tkhd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk, err := ParseTkhd(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tk.Flags != 3 || tk.TrackID != 1 || tk.Width != 1920 || tk.Height != 1080 {
+			t.Fatalf("Invalid tkhd %+v", tk)
+		}
+		if v, flags, err := ReadFullBoxHeader(b); v != 0 || flags != 3 || err != nil {
+			t.Fatalf("Invalid full box header %d %d %v", v, flags, err)
+		}
+	}
+	{
+		stbl, err := Find(NewReader(f, fi.Size()), "moov", "trak", "mdia", "minf", "stbl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := Find(Children(stbl), "stsd")
+		entries, err := ParseStsd(b)
+		if err != nil || len(entries) != 1 || entries[0].Format != "avc1" || entries[0].DataReferenceIndex != 1 {
+			t.Fatalf("Invalid stsd %+v %v", entries, err)
+		}
+		b, _ = Find(Children(stbl), "stts")
+		stts, err := ParseStts(b)
+		if err != nil || len(stts) != 1 || stts[0] != (SttsEntry{3, 1000}) {
+			t.Fatalf("Invalid stts %+v %v", stts, err)
+		}
+		b, _ = Find(Children(stbl), "stsz")
+		stsz, err := ParseStsz(b)
+		if err != nil || stsz.SampleCount != 3 || len(stsz.Sizes) != 3 || stsz.Sizes[1] != 6 {
+			t.Fatalf("Invalid stsz %+v %v", stsz, err)
+		}
+	}
+	if c := chunks(t, f); strings.Join(c, ",") != "AA,BB,CC" {
+		t.Fatalf("Invalid chunks %v", c)
+	}
+	if _, err := Find(NewReader(f, fi.Size()), "moov", "mvex"); err != ErrNotFound {
+		t.Fatalf("Invalid Find error %v", err)
+	}
+
+	// size 0 extends to the end of the file
+	{
+		r := NewReader(bytes.NewReader([]byte("\x00\x00\x00\x00mdatxxxx")), 12)
+		b, err := r.Next()
+		if err != nil || b.Type != "mdat" || b.Size != 12 {
+			t.Fatalf("Invalid size 0 box %+v %v", b, err)
+		}
+	}
+
+	// largesize beyond the int64 range
+	{
+		r := NewReader(bytes.NewReader([]byte("\x00\x00\x00\x01mdat\xFF\xFF\xFF\xFF\xFF\xFF\xFF\xFF")), 16)
+		if _, err := r.Next(); err != ErrFormat {
+			t.Fatalf("Invalid largesize error %v", err)
+		}
+	}
+
+	// counts larger than the box
+	for _, box := range []string{
+		"\x00\x00\x00\x10stco\x00\x00\x00\x00\x10\x00\x00\x00",
+		"\x00\x00\x00\x10co64\x00\x00\x00\x00\x10\x00\x00\x00",
+		"\x00\x00\x00\x10stsd\x00\x00\x00\x00\x10\x00\x00\x00",
+	} {
+		b, err := NewReader(bytes.NewReader([]byte(box)), 16).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Type == "stsd" {
+			_, err = ParseStsd(b)
+		} else {
+			_, err = ParseChunkOffsets(b)
+		}
+		if err != ErrFormat {
+			t.Fatalf("Invalid %s count error %v", b.Type, err)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	testFileName := "test.mp4"
+	outFileName := "out.mp4"
+	defer os.Remove(testFileName)
+	defer os.Remove(outFileName)
+
+	f := writeMovie(t, testFileName)
+	defer f.Close()
+	fi, _ := f.Stat()
+
+	out, err := os.Create(outFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := FastStart(f, fi.Size(), out); err != nil {
+		t.Fatal(err)
+	}
+
+	ofi, _ := out.Stat()
+	if ofi.Size() != fi.Size() {
+		t.Fatalf("Invalid output size %d", ofi.Size())
+	}
+	var types []string
+	r := NewReader(out, ofi.Size())
+	for b, err := r.Next(); err == nil; b, err = r.Next() {
+		types = append(types, b.Type)
+	}
+	if strings.Join(types, " ") != "ftyp free moov mdat" {
+		t.Fatalf("Invalid order %v", types)
+	}
+	if c := chunks(t, out); strings.Join(c, ",") != "AA,BB,CC" {
+		t.Fatalf("Invalid chunks after FastStart %v", c)
+	}
+
+	// io.EOF alongside a full read is success
+	{
+		var dst bio.Buffer
+		if err := FastStart(eofReaderAt{f, fi.Size()}, fi.Size(), &dst); err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(dst)) != fi.Size() {
+			t.Fatalf("Invalid output size %d", len(dst))
+		}
+	}
+
+	{
+		stco, err := Find(NewReader(f, fi.Size()), "moov", "trak", "mdia", "minf", "stbl", "stco")
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, _ := ParseChunkOffsets(stco)
+		if err := ShiftChunkOffsets(f, fi.Size(), 2); err != nil {
+			t.Fatal(err)
+		}
+		after, _ := ParseChunkOffsets(stco)
+		if after[0] != before[0]+2 || after[1] != before[1]+2 {
+			t.Fatalf("Invalid ShiftChunkOffsets %v %v", before, after)
+		}
+		if err := ShiftChunkOffsets(f, fi.Size(), 1<<32); err != ErrOffsetOverflow {
+			t.Fatalf("Invalid ShiftChunkOffsets error %v", err)
+		}
+	}
+}
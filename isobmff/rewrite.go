@@ -0,0 +1,154 @@
+package isobmff
+
+import (
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// ReadWriterAt is a source that can be patched in place.
+type ReadWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// chunkOffsetBoxes returns every stco and co64 box below moov.
+func chunkOffsetBoxes(moov *Box) ([]*Box, error) {
+	var boxes []*Box
+	err := walk(Children(moov), []*Box{moov}, func(path []*Box, b *Box) error {
+		if b.Type == "stco" || b.Type == "co64" {
+			boxes = append(boxes, b)
+		}
+		return nil
+	})
+	return boxes, err
+}
+
+// patchChunkOffsets rewrites the entries of the given boxes through w, where
+// shift is the distance between a box in the source and its copy in w.
+func patchChunkOffsets(w *bio.Writer, boxes []*Box, shift int64, remap func(uint64) (uint64, error)) error {
+	for _, b := range boxes {
+		offsets, err := ParseChunkOffsets(b)
+		if err != nil {
+			return err
+		}
+		w.SetOffset(b.DataOffset() + 8 + shift)
+		for _, off := range offsets {
+			v, err := remap(off)
+			if err != nil {
+				return err
+			}
+			if b.Type == "co64" {
+				w.WriteU64(v, bio.BigEndian)
+			} else if v > math.MaxUint32 {
+				return ErrOffsetOverflow
+			} else {
+				w.WriteU32(uint32(v), bio.BigEndian)
+			}
+		}
+	}
+	return w.Err()
+}
+
+// ShiftChunkOffsets adds delta to every chunk offset in the moov box of f, in place.
+func ShiftChunkOffsets(f ReadWriterAt, size int64, delta int64) error {
+	moov, err := Find(NewReader(f, size), "moov")
+	if err != nil {
+		return err
+	}
+	boxes, err := chunkOffsetBoxes(moov)
+	if err != nil {
+		return err
+	}
+	w := bio.NewWriter(f)
+	return patchChunkOffsets(w, boxes, 0, func(off uint64) (uint64, error) {
+		v := int64(off) + delta
+		if v < 0 {
+			return 0, ErrFormat
+		}
+		return uint64(v), nil
+	})
+}
+
+// FastStart copies a file of the given size from src to dst with the moov box
+// moved in front of the first mdat box, updating all chunk offsets.
+func FastStart(src io.ReaderAt, size int64, dst io.WriterAt) error {
+	var boxes []*Box
+	var moov *Box
+	r := NewReader(src, size)
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if b.Type == "moov" && moov == nil {
+			moov = b
+			continue
+		}
+		boxes = append(boxes, b)
+	}
+	if moov == nil {
+		return ErrNotFound
+	}
+
+	at := len(boxes)
+	for i, b := range boxes {
+		if b.Type == "mdat" {
+			at = i
+			break
+		}
+	}
+	boxes = append(boxes[:at], append([]*Box{moov}, boxes[at:]...)...)
+
+	// new position of every top-level box
+	pos := make([]int64, len(boxes))
+	var off int64
+	for i, b := range boxes {
+		pos[i] = off
+		off += int64(b.Size)
+	}
+
+	w := bio.NewWriter(dst)
+	buf := make([]byte, 64<<10)
+	var moovPos int64
+	for i, b := range boxes {
+		if b == moov {
+			moovPos = pos[i]
+		}
+		w.SetOffset(pos[i])
+		for n := int64(0); n < int64(b.Size); {
+			k := int64(len(buf))
+			if rest := int64(b.Size) - n; rest < k {
+				k = rest
+			}
+			if m, err := src.ReadAt(buf[:k], b.Offset+n); int64(m) < k {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			w.WriteRaw(buf[:k])
+			n += k
+		}
+	}
+	if w.Err() != nil {
+		return w.Err()
+	}
+
+	cob, err := chunkOffsetBoxes(moov)
+	if err != nil {
+		return err
+	}
+	return patchChunkOffsets(w, cob, moovPos-moov.Offset, func(off uint64) (uint64, error) {
+		for i, b := range boxes {
+			if b != moov && int64(off) >= b.Offset && off < uint64(b.Offset)+b.Size {
+				return uint64(int64(off) + pos[i] - b.Offset), nil
+			}
+		}
+		return 0, ErrFormat
+	})
+}
@@ -0,0 +1,268 @@
+// Package jpeg scans and rewrites JPEG marker segments, giving access to
+// metadata payloads without decoding the image.
+package jpeg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the jpeg package.
+var (
+	ErrFormat   = errors.New("jpeg: invalid marker")
+	ErrTooLarge = errors.New("jpeg: segment payload too large")
+)
+
+// Markers.
+const (
+	ECS   = 0x00 // pseudo marker for entropy-coded data following SOS
+	TEM   = 0x01
+	SOF0  = 0xC0
+	SOF2  = 0xC2
+	DHT   = 0xC4
+	RST0  = 0xD0
+	RST7  = 0xD7
+	SOI   = 0xD8
+	EOI   = 0xD9
+	SOS   = 0xDA
+	DQT   = 0xDB
+	DRI   = 0xDD
+	APP0  = 0xE0
+	APP1  = 0xE1
+	APP2  = 0xE2
+	APP13 = 0xED
+	APP14 = 0xEE
+	COM   = 0xFE
+)
+
+// Payload prefixes identifying common APPn segments.
+const (
+	exifPrefix = "Exif\x00\x00"
+	xmpPrefix  = "http://ns.adobe.com/xap/1.0/\x00"
+	iccPrefix  = "ICC_PROFILE\x00"
+)
+
+// Segment is a marker segment. Offset is the position of the marker and
+// DataOffset the position of the payload after the length field. For ECS
+// segments Offset and DataOffset are equal and Length covers the entropy-coded bytes.
+type Segment struct {
+	Marker     byte
+	Offset     int64
+	DataOffset int64
+	Length     int64
+	src        io.ReaderAt
+}
+
+func standalone(m byte) bool {
+	return m == SOI || m == EOI || m == TEM || m >= RST0 && m <= RST7
+}
+
+// Data returns a reader over the segment payload.
+func (s *Segment) Data() *io.SectionReader {
+	return io.NewSectionReader(s.src, s.DataOffset, s.Length)
+}
+
+// Bytes reads the whole segment payload.
+func (s *Segment) Bytes() ([]byte, error) {
+	r := bio.NewReader(s.src)
+	r.SetOffset(s.DataOffset)
+	b := r.ReadRaw(uint64(s.Length))
+	return b, r.Err()
+}
+
+func (s *Segment) hasPrefix(marker byte, prefix string) bool {
+	if s.Marker != marker || s.Length < int64(len(prefix)) {
+		return false
+	}
+	p := make([]byte, len(prefix))
+	if _, err := s.src.ReadAt(p, s.DataOffset); err != nil {
+		return false
+	}
+	return string(p) == prefix
+}
+
+func (s *Segment) sub(prefix string) *io.SectionReader {
+	n := int64(len(prefix))
+	return io.NewSectionReader(s.src, s.DataOffset+n, s.Length-n)
+}
+
+// IsEXIF reports whether s is an APP1 EXIF segment.
+func (s *Segment) IsEXIF() bool {
+	return s.hasPrefix(APP1, exifPrefix)
+}
+
+// EXIF returns the TIFF structure of an APP1 EXIF segment, or nil.
+func (s *Segment) EXIF() *io.SectionReader {
+	if !s.IsEXIF() {
+		return nil
+	}
+	return s.sub(exifPrefix)
+}
+
+// IsXMP reports whether s is an APP1 XMP segment.
+func (s *Segment) IsXMP() bool {
+	return s.hasPrefix(APP1, xmpPrefix)
+}
+
+// XMP returns the XMP packet of an APP1 XMP segment, or nil.
+func (s *Segment) XMP() *io.SectionReader {
+	if !s.IsXMP() {
+		return nil
+	}
+	return s.sub(xmpPrefix)
+}
+
+// IsICC reports whether s is an APP2 ICC profile segment.
+func (s *Segment) IsICC() bool {
+	return s.hasPrefix(APP2, iccPrefix) && s.Length >= int64(len(iccPrefix))+2
+}
+
+// ICC returns the sequence number, the total count and the profile chunk of an
+// APP2 ICC segment. ok is false for other segments.
+func (s *Segment) ICC() (seq, count int, chunk *io.SectionReader, ok bool) {
+	if !s.IsICC() {
+		return 0, 0, nil, false
+	}
+	br := bio.NewReader(s.src)
+	br.SetOffset(s.DataOffset + int64(len(iccPrefix)))
+	seq = int(br.ReadU8())
+	count = int(br.ReadU8())
+	if br.Err() != nil {
+		return 0, 0, nil, false
+	}
+	n := int64(len(iccPrefix)) + 2
+	return seq, count, io.NewSectionReader(s.src, s.DataOffset+n, s.Length-n), true
+}
+
+// Reader iterates over the segments of a JPEG stream.
+type Reader struct {
+	src  io.ReaderAt
+	br   *bio.Reader
+	scan bool
+	done bool
+}
+
+// NewReader checks the SOI marker of r.
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	br := bio.NewReader(r, bio.WithBufferSize(64<<10))
+	if br.ReadU16(bio.BigEndian) != 0xFF00|SOI {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	br.SetOffset(0)
+	return &Reader{src: r, br: br}, nil
+}
+
+// Next returns the next segment, or io.EOF after EOI. The entropy-coded data
+// following SOS is returned as an ECS segment.
+func (r *Reader) Next() (*Segment, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.scan {
+		r.scan = false
+		if s := r.scanECS(); s.Length > 0 {
+			return s, nil
+		}
+	}
+
+	br := r.br
+	if br.ReadU8() != 0xFF {
+		if br.Err() == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	m := byte(0xFF)
+	for m == 0xFF && br.Err() == nil {
+		m = br.ReadU8() // skip fill bytes
+	}
+	if br.Err() != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if m == 0x00 {
+		return nil, ErrFormat
+	}
+
+	off := br.GetOffset()
+	s := &Segment{Marker: m, Offset: off - 2, DataOffset: off, src: r.src}
+	if standalone(m) {
+		r.done = m == EOI
+		return s, nil
+	}
+	n := int64(br.ReadU16(bio.BigEndian))
+	if br.Err() != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if n < 2 {
+		return nil, ErrFormat
+	}
+	s.DataOffset = off + 2
+	s.Length = n - 2
+	br.SetOffset(s.DataOffset + s.Length)
+	r.scan = m == SOS
+	return s, nil
+}
+
+// scanECS consumes entropy-coded data up to the next marker. Stuffed 0xFF00
+// bytes and RSTn markers belong to the data. A stream truncated inside the data
+// ends the segment at the last byte read.
+func (r *Reader) scanECS() *Segment {
+	// scan on a cursor so running into the end of the source leaves r.br usable
+	start := r.br.GetOffset()
+	br := r.br.At(start)
+	end := start
+	for {
+		if br.ReadU8() != 0xFF {
+			if br.Err() != nil {
+				break
+			}
+			end = br.GetOffset()
+			continue
+		}
+		next := br.ReadU8()
+		if br.Err() == nil && (next == 0x00 || next >= RST0 && next <= RST7) {
+			end = br.GetOffset()
+			continue
+		}
+		break
+	}
+	r.br.SetOffset(end)
+	return &Segment{Marker: ECS, Offset: start, DataOffset: start, Length: end - start, src: r.src}
+}
+
+// ICCProfile reassembles an ICC profile split over APP2 segments.
+func ICCProfile(segments []*Segment) ([]byte, error) {
+	var chunks [][]byte
+	for _, s := range segments {
+		seq, count, chunk, ok := s.ICC()
+		if !ok {
+			continue
+		}
+		if chunks == nil {
+			chunks = make([][]byte, count)
+		}
+		if seq < 1 || seq > len(chunks) || count != len(chunks) {
+			return nil, ErrFormat
+		}
+		b := make([]byte, chunk.Size())
+		if _, err := chunk.ReadAt(b, 0); err != nil {
+			return nil, err
+		}
+		chunks[seq-1] = b
+	}
+	for _, c := range chunks {
+		if c == nil {
+			return nil, ErrFormat
+		}
+	}
+	return bytes.Join(chunks, nil), nil
+}
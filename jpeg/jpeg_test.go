@@ -0,0 +1,234 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdjpeg "image/jpeg"
+	"io"
+	"os"
+	"testing"
+
+	bio "github.com/takurooo/binaryio"
+	"github.com/takurooo/binaryio/tiff"
+)
+
+func encodeImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < 16; i++ {
+		img.Set(i, i, color.RGBA{255, 0, 0, 255})
+	}
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func segments(t *testing.T, src io.ReaderAt) []*Segment {
+	r, err := NewReader(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var segs []*Segment
+	for {
+		s, err := r.Next()
+		if err == io.EOF {
+			return segs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		segs = append(segs, s)
+	}
+}
+
+func markers(segs []*Segment) []byte {
+	var m []byte
+	for _, s := range segs {
+		m = append(m, s.Marker)
+	}
+	return m
+}
+
+func exifPayload(t *testing.T) []byte {
+	gps := &tiff.IFD{}
+	gps.Set(tiff.NewASCII(0x0001, "N"))
+	gps.Set(tiff.NewRationals(0x0002, tiff.Rat{Num: 35, Den: 1}, tiff.Rat{Num: 41, Den: 1}))
+	ifd0 := &tiff.IFD{Sub: map[uint16][]*tiff.IFD{tiff.TagGPSIFD: {gps}}}
+	ifd0.Set(tiff.NewASCII(tiff.TagMake, "binaryio"))
+	f := &tiff.File{Order: bio.BigEndian, IFDs: []*tiff.IFD{ifd0}}
+
+	buf := append(bio.Buffer(nil), exifPrefix...)
+	if err := f.Encode(&offsetWriterAt{&buf, int64(len(exifPrefix))}); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestReader(t *testing.T) {
+	src := encodeImage(t)
+	segs := segments(t, bytes.NewReader(src))
+	m := markers(segs)
+	if m[0] != SOI || m[len(m)-1] != EOI || bytes.IndexByte(m, SOF0) < 0 || bytes.IndexByte(m, DHT) < 0 || bytes.IndexByte(m, DQT) < 0 {
+		t.Fatalf("Invalid markers % x", m)
+	}
+	sos := bytes.IndexByte(m, SOS)
+	if sos < 0 || m[sos+1] != ECS {
+		t.Fatalf("SOS must be followed by entropy-coded data: % x", m)
+	}
+	ecs := segs[sos+1]
+	if ecs.Offset+ecs.Length != segs[len(segs)-1].Offset {
+		t.Fatalf("Invalid ECS range %d+%d", ecs.Offset, ecs.Length)
+	}
+
+	// Stuffed bytes, RST markers and fill bytes.
+	stream := []byte{
+		0xFF, SOI,
+		0xFF, SOS, 0x00, 0x03, 0x00,
+		0x12, 0xFF, 0x00, 0x34, 0xFF, RST0, 0x56,
+		0xFF, 0xFF, 0xFF, EOI,
+	}
+	segs = segments(t, bytes.NewReader(stream))
+	if !bytes.Equal(markers(segs), []byte{SOI, SOS, ECS, EOI}) {
+		t.Fatalf("Invalid markers % x", markers(segs))
+	}
+	if segs[1].Length != 1 || segs[2].Offset != 7 || segs[2].Length != 7 || segs[3].Offset != 16 {
+		t.Fatalf("Invalid segments %+v %+v %+v", segs[1], segs[2], segs[3])
+	}
+
+	r, _ := NewReader(bytes.NewReader(stream[:12]))
+	var err error
+	for err == nil {
+		_, err = r.Next()
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid truncation error %v", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte{0x89, 'P'})); err != ErrFormat {
+		t.Fatalf("Invalid format error %v", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	testFileName := "test.jpg"
+	strippedFileName := "stripped.jpg"
+	scrubbedFileName := "scrubbed.jpg"
+	defer os.Remove(testFileName)
+	defer os.Remove(strippedFileName)
+	defer os.Remove(scrubbedFileName)
+
+	src := encodeImage(t)
+	exif := exifPayload(t)
+	xmp := []byte(xmpPrefix + "<x:xmpmeta/>")
+	profile := bytes.Repeat([]byte("icc"), 100)
+
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	inserted := false
+	err = Rewrite(bytes.NewReader(src), fw, func(s *Segment, w *Writer) (bool, error) {
+		if s.Marker != DQT || inserted {
+			return true, nil
+		}
+		inserted = true
+		for _, data := range [][]byte{exif, xmp} {
+			if err := w.WriteSegment(APP1, data); err != nil {
+				return false, err
+			}
+		}
+		for i, chunk := range [][]byte{profile[:200], profile[200:]} {
+			data := append([]byte(iccPrefix), byte(i+1), 2)
+			if err := w.WriteSegment(APP2, append(data, chunk...)); err != nil {
+				return false, err
+			}
+		}
+		return true, w.WriteSegment(COM, []byte("comment"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segs := segments(t, fw)
+	var found int
+	for _, s := range segs {
+		switch {
+		case s.IsEXIF():
+			f, err := tiff.Decode(s.EXIF())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.IFDs[0].Field(tiff.TagMake).String() != "binaryio" || f.IFDs[0].Sub[tiff.TagGPSIFD] == nil {
+				t.Fatalf("Invalid EXIF")
+			}
+			found++
+		case s.IsXMP():
+			b := make([]byte, s.XMP().Size())
+			s.XMP().ReadAt(b, 0)
+			if string(b) != "<x:xmpmeta/>" {
+				t.Fatalf("Invalid XMP %q", b)
+			}
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatalf("EXIF and XMP not found")
+	}
+	icc, err := ICCProfile(segs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(icc, profile) {
+		t.Fatalf("Invalid ICC profile")
+	}
+	if _, err := stdjpeg.Decode(io.NewSectionReader(fw, 0, 1<<20)); err != nil {
+		t.Fatalf("Rewritten file must decode: %v", err)
+	}
+
+	fs, err := os.Create(scrubbedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := RemoveGPS(fw, fs); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range segments(t, fs) {
+		if s.IsEXIF() {
+			f, err := tiff.Decode(s.EXIF())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.IFDs[0].Field(tiff.TagGPSIFD) != nil || f.IFDs[0].Field(tiff.TagMake) == nil {
+				t.Fatalf("GPS IFD not removed")
+			}
+		}
+	}
+
+	fst, err := os.Create(strippedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fst.Close()
+	if err := StripMetadata(fw, fst); err != nil {
+		t.Fatal(err)
+	}
+	stripped := segments(t, fst)
+	for _, s := range stripped {
+		if s.Metadata() && s.Marker != APP0 && !s.IsICC() {
+			t.Fatalf("Segment %02x not stripped", s.Marker)
+		}
+	}
+	if _, err := ICCProfile(stripped); err != nil {
+		t.Fatalf("ICC profile must be kept: %v", err)
+	}
+	if _, err := stdjpeg.Decode(io.NewSectionReader(fst, 0, 1<<20)); err != nil {
+		t.Fatalf("Stripped file must decode: %v", err)
+	}
+
+	if err := NewWriter(fw).WriteSegment(COM, make([]byte, 0xFFFF)); err != ErrTooLarge {
+		t.Fatalf("Invalid size error %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package jpeg
+
+import (
+	"io"
+
+	bio "github.com/takurooo/binaryio"
+	"github.com/takurooo/binaryio/tiff"
+)
+
+// Writer writes a JPEG stream segment by segment.
+type Writer struct {
+	w *bio.Writer
+}
+
+// NewWriter returns a Writer starting at offset 0 of w.
+func NewWriter(w io.WriterAt) *Writer {
+	return &Writer{w: bio.NewWriter(w)}
+}
+
+// WriteMarker writes a standalone marker such as SOI or EOI.
+func (jw *Writer) WriteMarker(m byte) error {
+	jw.w.WriteU8(0xFF)
+	jw.w.WriteU8(m)
+	return jw.w.Err()
+}
+
+// WriteSegment writes a marker segment with the given payload.
+func (jw *Writer) WriteSegment(m byte, data []byte) error {
+	if len(data) > 0xFFFF-2 {
+		return ErrTooLarge
+	}
+	jw.w.WriteU8(0xFF)
+	jw.w.WriteU8(m)
+	jw.w.WriteU16(uint16(len(data)+2), bio.BigEndian)
+	jw.w.WriteRaw(data)
+	return jw.w.Err()
+}
+
+// CopySegment copies s unchanged from its source, including entropy-coded data.
+func (jw *Writer) CopySegment(s *Segment) error {
+	switch {
+	case s.Marker == ECS:
+	case standalone(s.Marker):
+		return jw.WriteMarker(s.Marker)
+	default:
+		jw.w.WriteU8(0xFF)
+		jw.w.WriteU8(s.Marker)
+		jw.w.WriteU16(uint16(s.Length+2), bio.BigEndian)
+		if err := jw.w.Err(); err != nil {
+			return err
+		}
+	}
+	br := bio.NewReader(s.src)
+	br.SetOffset(s.DataOffset)
+	for n := s.Length; n > 0; {
+		chunk := n
+		if chunk > 64<<10 {
+			chunk = 64 << 10
+		}
+		b := br.ReadRaw(uint64(chunk))
+		if br.Err() != nil {
+			return br.Err()
+		}
+		jw.w.WriteRaw(b)
+		if jw.w.Err() != nil {
+			return jw.w.Err()
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// Metadata reports whether s is an APPn or COM segment, which can be removed
+// without breaking the image.
+func (s *Segment) Metadata() bool {
+	return s.Marker >= APP0 && s.Marker <= APP0+15 || s.Marker == COM
+}
+
+// EditFunc decides what happens to a segment during Rewrite. It may write new
+// segments through w, which are placed before s, and returns whether s is kept.
+type EditFunc func(s *Segment, w *Writer) (keep bool, err error)
+
+// Rewrite copies the JPEG stream in src to dst, letting fn drop metadata
+// segments or insert new ones. Other segments are always kept.
+func Rewrite(src io.ReaderAt, dst io.WriterAt, fn EditFunc) error {
+	r, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+	w := NewWriter(dst)
+	for {
+		s, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keep, err := fn(s, w)
+		if err != nil {
+			return err
+		}
+		if keep || !s.Metadata() {
+			if err := w.CopySegment(s); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StripMetadata copies src to dst without APPn and COM segments. The JFIF APP0,
+// ICC profile APP2 and Adobe APP14 segments are kept since they affect how
+// colors are decoded.
+func StripMetadata(src io.ReaderAt, dst io.WriterAt) error {
+	return Rewrite(src, dst, func(s *Segment, w *Writer) (bool, error) {
+		return s.Marker == APP0 || s.Marker == APP14 || s.IsICC(), nil
+	})
+}
+
+// RemoveGPS copies src to dst, re-encoding EXIF segments without the GPS IFD.
+// XMP segments are copied unchanged, so exif:GPS properties in them remain;
+// drop those segments with Rewrite if they must go too.
+func RemoveGPS(src io.ReaderAt, dst io.WriterAt) error {
+	return Rewrite(src, dst, func(s *Segment, w *Writer) (bool, error) {
+		if !s.IsEXIF() {
+			return true, nil
+		}
+		f, err := tiff.Decode(s.EXIF())
+		if err != nil {
+			return false, err
+		}
+		if len(f.IFDs) == 0 || f.IFDs[0].Field(tiff.TagGPSIFD) == nil {
+			return true, nil
+		}
+		f.IFDs[0].Remove(tiff.TagGPSIFD)
+		exif := append(bio.Buffer(nil), exifPrefix...)
+		if err := f.Encode(&offsetWriterAt{&exif, int64(len(exifPrefix))}); err != nil {
+			return false, err
+		}
+		return false, w.WriteSegment(APP1, exif)
+	})
+}
+
+// offsetWriterAt shifts writes by a fixed base so a TIFF structure can follow
+// the EXIF header.
+type offsetWriterAt struct {
+	w    io.WriterAt
+	base int64
+}
+
+func (o *offsetWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return o.w.WriteAt(p, o.base+off)
+}
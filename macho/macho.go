@@ -0,0 +1,425 @@
+// Package macho parses Mach-O files and fat (universal) binaries: headers,
+// segments and sections, and the UUID, build version and code signature commands.
+package macho
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the macho package.
+var (
+	ErrFormat = errors.New("macho: invalid format")
+	ErrFat    = errors.New("macho: fat binary, use DecodeFat")
+)
+
+// Magic numbers, as read big-endian from the start of the file.
+const (
+	Magic32    = 0xFEEDFACE
+	Magic64    = 0xFEEDFACF
+	Cigam32    = 0xCEFAEDFE
+	Cigam64    = 0xCFFAEDFE
+	MagicFat   = 0xCAFEBABE
+	MagicFat64 = 0xCAFEBABF
+)
+
+// Load command types.
+const (
+	LoadSegment       = 0x1
+	LoadSymtab        = 0x2
+	LoadDysymtab      = 0xB
+	LoadLoadDylib     = 0xC
+	LoadIDDylib       = 0xD
+	LoadSegment64     = 0x19
+	LoadUUID          = 0x1B
+	LoadCodeSignature = 0x1D
+	LoadBuildVersion  = 0x32
+	LoadReqDyld       = 0x80000000
+	LoadMain          = 0x28 | LoadReqDyld
+)
+
+// FatArch is one slice of a fat binary.
+type FatArch struct {
+	CPUType    uint32
+	CPUSubtype uint32
+	Offset     uint64
+	Size       uint64
+	Align      uint32
+	src        io.ReaderAt
+}
+
+// Data returns a reader over the slice, which can be passed to Decode.
+func (a *FatArch) Data() *io.SectionReader {
+	return io.NewSectionReader(a.src, int64(a.Offset), int64(a.Size))
+}
+
+// Fat is a fat binary header. Fat headers are always big-endian.
+type Fat struct {
+	Magic  uint32
+	Arches []*FatArch
+}
+
+// DecodeFat parses the fat header of r.
+func DecodeFat(r io.ReaderAt) (*Fat, error) {
+	br := bio.NewReader(r)
+	fat := &Fat{Magic: br.ReadU32(bio.BigEndian)}
+	n := br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if fat.Magic != MagicFat && fat.Magic != MagicFat64 || n > 1<<10 {
+		return nil, ErrFormat
+	}
+	for i := uint32(0); i < n; i++ {
+		a := &FatArch{src: r}
+		a.CPUType = br.ReadU32(bio.BigEndian)
+		a.CPUSubtype = br.ReadU32(bio.BigEndian)
+		if fat.Magic == MagicFat64 {
+			a.Offset = br.ReadU64(bio.BigEndian)
+			a.Size = br.ReadU64(bio.BigEndian)
+			a.Align = br.ReadU32(bio.BigEndian)
+			br.ReadU32(bio.BigEndian) // reserved
+		} else {
+			a.Offset = uint64(br.ReadU32(bio.BigEndian))
+			a.Size = uint64(br.ReadU32(bio.BigEndian))
+			a.Align = br.ReadU32(bio.BigEndian)
+		}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		if a.Align < 64 && a.Offset&(1<<a.Align-1) != 0 {
+			return nil, ErrFormat
+		}
+		fat.Arches = append(fat.Arches, a)
+	}
+	return fat, nil
+}
+
+// Header is the Mach-O header. Reserved is only present in 64-bit files.
+type Header struct {
+	Magic      uint32
+	CPUType    uint32
+	CPUSubtype uint32
+	FileType   uint32
+	NCmds      uint32
+	SizeOfCmds uint32
+	Flags      uint32
+	Reserved   uint32
+}
+
+// Command is a raw load command. Offset is the position of the command header.
+type Command struct {
+	Cmd    uint32
+	Size   uint32
+	Offset int64
+	src    io.ReaderAt
+}
+
+// Data returns a reader over the command body after the cmd and cmdsize fields.
+func (c *Command) Data() *io.SectionReader {
+	return io.NewSectionReader(c.src, c.Offset+8, int64(c.Size)-8)
+}
+
+// Segment is an LC_SEGMENT or LC_SEGMENT_64 command.
+type Segment struct {
+	Name     string
+	Addr     uint64
+	Memsz    uint64
+	Offset   uint64
+	Filesz   uint64
+	MaxProt  uint32
+	InitProt uint32
+	Flags    uint32
+	Sections []*Section
+}
+
+// Section is a section of a segment.
+type Section struct {
+	Name      string
+	Segment   string
+	Addr      uint64
+	Size      uint64
+	Offset    uint32
+	Align     uint32
+	RelOff    uint32
+	NReloc    uint32
+	Flags     uint32
+	Reserved1 uint32
+	Reserved2 uint32
+	Reserved3 uint32
+	src       io.ReaderAt
+}
+
+// Data returns a reader over the section contents in the file.
+func (s *Section) Data() *io.SectionReader {
+	return io.NewSectionReader(s.src, int64(s.Offset), int64(s.Size))
+}
+
+// Version is a packed xxxx.yy.zz version number.
+type Version uint32
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v>>16, v>>8&0xFF, v&0xFF)
+}
+
+// BuildTool is a tool entry of LC_BUILD_VERSION.
+type BuildTool struct {
+	Tool    uint32
+	Version Version
+}
+
+// BuildVersion is an LC_BUILD_VERSION command.
+type BuildVersion struct {
+	Platform uint32
+	MinOS    Version
+	SDK      Version
+	Tools    []BuildTool
+}
+
+// CodeSignature is an LC_CODE_SIGNATURE command locating the signature blob in
+// the __LINKEDIT segment.
+type CodeSignature struct {
+	DataOff  uint32
+	DataSize uint32
+	src      io.ReaderAt
+}
+
+// File is a parsed Mach-O file.
+type File struct {
+	Header
+	Order         bio.Endian
+	Is64          bool
+	Commands      []*Command
+	Segments      []*Segment
+	UUID          *bio.UUID
+	BuildVersion  *BuildVersion
+	CodeSignature *CodeSignature
+}
+
+// Decode parses the header and load commands of a thin Mach-O file. Fat
+// binaries return ErrFat.
+func Decode(r io.ReaderAt) (*File, error) {
+	br := bio.NewReader(r)
+	f := &File{}
+	magic := br.ReadU32(bio.BigEndian)
+	switch magic {
+	case Magic32, Magic64:
+		f.Order = bio.BigEndian
+	case Cigam32, Cigam64:
+		f.Order = bio.LittleEndian
+	case MagicFat, MagicFat64:
+		return nil, ErrFat
+	default:
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	f.Is64 = magic == Magic64 || magic == Cigam64
+	o := f.Order
+	h := &f.Header
+	br.SetOffset(0)
+	h.Magic = br.ReadU32(o)
+	h.CPUType = br.ReadU32(o)
+	h.CPUSubtype = br.ReadU32(o)
+	h.FileType = br.ReadU32(o)
+	h.NCmds = br.ReadU32(o)
+	h.SizeOfCmds = br.ReadU32(o)
+	h.Flags = br.ReadU32(o)
+	if f.Is64 {
+		h.Reserved = br.ReadU32(o)
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	// cmdsize is a multiple of 8 in 64-bit files and of 4 in 32-bit files.
+	align := uint32(4)
+	if f.Is64 {
+		align = 8
+	}
+	off := br.GetOffset()
+	end := off + int64(h.SizeOfCmds)
+	for i := uint32(0); i < h.NCmds; i++ {
+		br.SetOffset(off)
+		c := &Command{Cmd: br.ReadU32(o), Size: br.ReadU32(o), Offset: off, src: r}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		if c.Size < 8 || c.Size%align != 0 || off+int64(c.Size) > end {
+			return nil, ErrFormat
+		}
+		f.Commands = append(f.Commands, c)
+		if err := f.parseCommand(br, c); err != nil {
+			return nil, err
+		}
+		off += int64(c.Size)
+	}
+	return f, nil
+}
+
+func fixedString(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func (f *File) parseCommand(br *bio.Reader, c *Command) error {
+	o := f.Order
+	br.SetOffset(c.Offset + 8)
+	switch c.Cmd {
+	case LoadSegment, LoadSegment64:
+		is64 := c.Cmd == LoadSegment64
+		word := func() uint64 {
+			if is64 {
+				return br.ReadU64(o)
+			}
+			return uint64(br.ReadU32(o))
+		}
+		s := &Segment{Name: fixedString(string(br.ReadRaw(16)))}
+		s.Addr = word()
+		s.Memsz = word()
+		s.Offset = word()
+		s.Filesz = word()
+		s.MaxProt = br.ReadU32(o)
+		s.InitProt = br.ReadU32(o)
+		nsects := br.ReadU32(o)
+		s.Flags = br.ReadU32(o)
+		if br.Err() != nil {
+			return br.Err()
+		}
+		hdr, sect := int64(56), int64(68)
+		if is64 {
+			hdr, sect = 72, 80
+		}
+		if hdr+int64(nsects)*sect > int64(c.Size) {
+			return ErrFormat
+		}
+		for i := uint32(0); i < nsects; i++ {
+			x := &Section{Name: fixedString(string(br.ReadRaw(16))), Segment: fixedString(string(br.ReadRaw(16))), src: c.src}
+			x.Addr = word()
+			x.Size = word()
+			x.Offset = br.ReadU32(o)
+			x.Align = br.ReadU32(o)
+			x.RelOff = br.ReadU32(o)
+			x.NReloc = br.ReadU32(o)
+			x.Flags = br.ReadU32(o)
+			x.Reserved1 = br.ReadU32(o)
+			x.Reserved2 = br.ReadU32(o)
+			if is64 {
+				x.Reserved3 = br.ReadU32(o)
+			}
+			s.Sections = append(s.Sections, x)
+		}
+		f.Segments = append(f.Segments, s)
+	case LoadUUID:
+		if c.Size < 24 {
+			return ErrFormat
+		}
+		u := br.ReadUUID()
+		f.UUID = &u
+	case LoadBuildVersion:
+		b := &BuildVersion{Platform: br.ReadU32(o), MinOS: Version(br.ReadU32(o)), SDK: Version(br.ReadU32(o))}
+		n := br.ReadU32(o)
+		if br.Err() != nil {
+			return br.Err()
+		}
+		if 24+int64(n)*8 > int64(c.Size) {
+			return ErrFormat
+		}
+		for i := uint32(0); i < n; i++ {
+			b.Tools = append(b.Tools, BuildTool{br.ReadU32(o), Version(br.ReadU32(o))})
+		}
+		f.BuildVersion = b
+	case LoadCodeSignature:
+		f.CodeSignature = &CodeSignature{DataOff: br.ReadU32(o), DataSize: br.ReadU32(o), src: c.src}
+	}
+	return br.Err()
+}
+
+// Segment returns the segment with the given name, or nil.
+func (f *File) Segment(name string) *Segment {
+	for _, s := range f.Segments {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Section returns the section with the given segment and section names, or nil.
+func (f *File) Section(segment, name string) *Section {
+	if s := f.Segment(segment); s != nil {
+		for _, x := range s.Sections {
+			if x.Name == name {
+				return x
+			}
+		}
+	}
+	return nil
+}
+
+// Code signature blob magic numbers.
+const (
+	MagicEmbeddedSignature = 0xFADE0CC0
+	MagicCodeDirectory     = 0xFADE0C02
+	MagicRequirements      = 0xFADE0C01
+	MagicBlobWrapper       = 0xFADE0B01
+)
+
+// Blob is an entry of the code signature super blob index. Offset is relative
+// to the start of the signature.
+type Blob struct {
+	Type   uint32
+	Offset uint32
+	Magic  uint32
+	Length uint32
+}
+
+// Data returns a reader over the signature.
+func (c *CodeSignature) Data() *io.SectionReader {
+	return io.NewSectionReader(c.src, int64(c.DataOff), int64(c.DataSize))
+}
+
+// Blobs parses the index of the embedded signature super blob. Signature
+// blobs are big-endian regardless of the file byte order.
+func (c *CodeSignature) Blobs() ([]Blob, error) {
+	br := bio.NewReader(c.Data())
+	if br.ReadU32(bio.BigEndian) != MagicEmbeddedSignature {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	length := br.ReadU32(bio.BigEndian)
+	n := br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if length > c.DataSize || 12+int64(n)*8 > int64(length) {
+		return nil, ErrFormat
+	}
+	blobs := make([]Blob, n)
+	for i := range blobs {
+		blobs[i].Type = br.ReadU32(bio.BigEndian)
+		blobs[i].Offset = br.ReadU32(bio.BigEndian)
+	}
+	for i := range blobs {
+		b := &blobs[i]
+		if uint64(b.Offset)+8 > uint64(length) {
+			return nil, ErrFormat
+		}
+		br.SetOffset(int64(b.Offset))
+		b.Magic = br.ReadU32(bio.BigEndian)
+		b.Length = br.ReadU32(bio.BigEndian)
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	return blobs, nil
+}
@@ -0,0 +1,172 @@
+package macho
+
+import (
+	"bytes"
+	stdmacho "debug/macho"
+	"io/ioutil"
+	"testing"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// buffer is a growable in-memory io.WriterAt.
+type buffer []byte
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(*b) {
+		*b = append(*b, make([]byte, end-len(*b))...)
+	}
+	return copy((*b)[off:], p), nil
+}
+
+var testUUID, _ = bio.ParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+
+// thin builds a Mach-O file with a __TEXT segment, LC_UUID, LC_BUILD_VERSION
+// and LC_CODE_SIGNATURE.
+func thin(t *testing.T, is64 bool, o bio.Endian) []byte {
+	var buf buffer
+	w := bio.NewWriter(&buf)
+	word := func(v uint64) {
+		if is64 {
+			w.WriteU64(v, o)
+		} else {
+			w.WriteU32(uint32(v), o)
+		}
+	}
+	name := func(s string) { w.WriteRaw(append([]byte(s), make([]byte, 16-len(s))...)) }
+
+	segSize, magic, cpu := uint32(56+68), uint32(Magic32), uint32(18)
+	if is64 {
+		segSize, magic, cpu = 72+80, Magic64, 0x01000007
+	}
+	ncmds, sizeofcmds := uint32(4), segSize+24+32+16
+	w.WriteU32s([]uint32{magic, cpu, 3, 2, ncmds, sizeofcmds, 0x85}, o)
+	if is64 {
+		w.WriteU32(0, o)
+	}
+
+	cmd := uint32(LoadSegment)
+	if is64 {
+		cmd = LoadSegment64
+	}
+	w.WriteU32s([]uint32{cmd, segSize}, o)
+	name("__TEXT")
+	word(0x100000000)
+	word(0x1000)
+	word(0)
+	word(0x1000)
+	w.WriteU32s([]uint32{5, 5, 1, 0}, o)
+	name("__text")
+	name("__TEXT")
+	word(0x100000400)
+	word(4)
+	w.WriteU32s([]uint32{0x400, 2, 0, 0, 0x80000400, 0, 0}, o)
+	if is64 {
+		w.WriteU32(0, o)
+	}
+
+	w.WriteU32s([]uint32{LoadUUID, 24}, o)
+	w.WriteUUID(testUUID)
+	w.WriteU32s([]uint32{LoadBuildVersion, 32, 1, 0x000E0000, 0x000E0200, 1, 3, 0x03200100}, o)
+	w.WriteU32s([]uint32{LoadCodeSignature, 16, 0x800, 28}, o)
+
+	w.SetOffset(0x400)
+	w.WriteRaw([]byte{0xC0, 0x03, 0x5F, 0xD6})
+	w.SetOffset(0x800)
+	w.WriteU32s([]uint32{MagicEmbeddedSignature, 28, 1, 0, 20, MagicCodeDirectory, 8}, bio.BigEndian)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	return buf
+}
+
+func TestFile(t *testing.T) {
+	for _, is64 := range []bool{false, true} {
+		for _, o := range []bio.Endian{bio.LittleEndian, bio.BigEndian} {
+			b := thin(t, is64, o)
+			f, err := Decode(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			std, err := stdmacho.NewFile(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Is64 != is64 || f.Order != o || f.NCmds != std.Ncmd || f.Flags != uint32(std.Flags) || len(f.Commands) != 4 {
+				t.Fatalf("Invalid header %+v", f.Header)
+			}
+			seg, stdSeg := f.Segment("__TEXT"), std.Segment("__TEXT")
+			if seg == nil || seg.Addr != stdSeg.Addr || seg.Filesz != stdSeg.Filesz || seg.MaxProt != stdSeg.Maxprot {
+				t.Fatalf("Invalid segment %+v", seg)
+			}
+			sect := f.Section("__TEXT", "__text")
+			if sect == nil || sect.Addr != std.Section("__text").Addr || sect.Flags != std.Section("__text").Flags {
+				t.Fatalf("Invalid section %+v", sect)
+			}
+			code, _ := ioutil.ReadAll(sect.Data())
+			if !bytes.Equal(code, []byte{0xC0, 0x03, 0x5F, 0xD6}) {
+				t.Fatalf("Invalid section data % x", code)
+			}
+			if f.UUID == nil || *f.UUID != testUUID {
+				t.Fatalf("Invalid UUID %v", f.UUID)
+			}
+			bv := f.BuildVersion
+			if bv == nil || bv.MinOS.String() != "14.0.0" || bv.SDK.String() != "14.2.0" || len(bv.Tools) != 1 || bv.Tools[0].Version.String() != "800.1.0" {
+				t.Fatalf("Invalid build version %+v", bv)
+			}
+			blobs, err := f.CodeSignature.Blobs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(blobs) != 1 || blobs[0] != (Blob{Type: 0, Offset: 20, Magic: MagicCodeDirectory, Length: 8}) {
+				t.Fatalf("Invalid blobs %+v", blobs)
+			}
+		}
+	}
+
+	bad := thin(t, true, bio.LittleEndian)
+	bad[32+4] = 0x94 // cmdsize not a multiple of 8
+	if _, err := Decode(bytes.NewReader(bad)); err != ErrFormat {
+		t.Fatalf("Invalid alignment error %v", err)
+	}
+}
+
+func TestFat(t *testing.T) {
+	slices := [][]byte{thin(t, false, bio.BigEndian), thin(t, true, bio.LittleEndian)}
+	var buf buffer
+	w := bio.NewWriter(&buf)
+	w.WriteU32s([]uint32{MagicFat, 2}, bio.BigEndian)
+	w.WriteU32s([]uint32{18, 0, 0x1000, uint32(len(slices[0])), 12}, bio.BigEndian)
+	w.WriteU32s([]uint32{0x01000007, 3, 0x2000, uint32(len(slices[1])), 12}, bio.BigEndian)
+	w.SetOffset(0x1000)
+	w.WriteRaw(slices[0])
+	w.SetOffset(0x2000)
+	w.WriteRaw(slices[1])
+
+	if _, err := Decode(bytes.NewReader(buf)); err != ErrFat {
+		t.Fatalf("Invalid fat error %v", err)
+	}
+	fat, err := DecodeFat(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std, err := stdmacho.NewFatFile(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fat.Arches) != len(std.Arches) {
+		t.Fatalf("Invalid arch count %d", len(fat.Arches))
+	}
+	for i, a := range fat.Arches {
+		if a.Offset != uint64(std.Arches[i].Offset) || a.Size != uint64(std.Arches[i].Size) {
+			t.Fatalf("Invalid arch %+v", a)
+		}
+		f, err := Decode(a.Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Is64 != (i == 1) || f.CPUType != a.CPUType {
+			t.Fatalf("Invalid slice header %+v", f.Header)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package binaryio
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrReadOnly is returned when writing to a read-only MmapFile.
+var ErrReadOnly = errors.New("binaryio: mmap is read-only")
+
+var errNegativeOffset = errors.New("binaryio: negative offset")
+
+// MmapFile is a memory-mapped file usable as both io.ReaderAt and io.WriterAt.
+// Readers created on it return ReadRaw slices that point directly into the mapping,
+// and Writers created on a writable one copy straight into it.
+// If the platform or the file does not support mmap, the contents are loaded into
+// memory instead and written back on Sync.
+//
+// Readers and Writers created on an MmapFile access the mapping directly, not
+// through ReadAt and WriteAt. Every one of them must be dropped before Close;
+// using one afterwards faults on the unmapped memory.
+type MmapFile struct {
+	f        *os.File
+	data     []byte
+	writable bool
+	mapped   bool
+}
+
+// OpenMmap maps the named file read-only.
+func OpenMmap(path string) (*MmapFile, error) {
+	return openMmap(path, false)
+}
+
+// OpenMmapRW maps the named file read-write. The file size cannot change.
+func OpenMmapRW(path string) (*MmapFile, error) {
+	return openMmap(path, true)
+}
+
+func openMmap(path string, writable bool) (*MmapFile, error) {
+	flag := os.O_RDONLY
+	if writable {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := fi.Size()
+	if int64(int(size)) != size {
+		f.Close()
+		return nil, errors.New("binaryio: file too large to map")
+	}
+
+	m := &MmapFile{f: f, writable: writable}
+	if size > 0 {
+		if data, err := mmap(f, int(size), writable); err == nil {
+			m.data = data
+			m.mapped = true
+			return m, nil
+		}
+	}
+
+	// fallback
+	m.data = make([]byte, size)
+	if _, err := f.ReadAt(m.data, 0); err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Bytes returns the mapped contents. The slice is valid until Close.
+func (m *MmapFile) Bytes() []byte {
+	return m.data
+}
+
+// writableBytes returns the contents if they can be modified, for NewWriter.
+func (m *MmapFile) writableBytes() []byte {
+	if !m.writable || m.data == nil {
+		return nil
+	}
+	return m.data
+}
+
+// Len returns the size of the mapping.
+func (m *MmapFile) Len() int {
+	return len(m.data)
+}
+
+// ReadAt ...
+func (m *MmapFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt ...
+func (m *MmapFile) WriteAt(p []byte, off int64) (int, error) {
+	if !m.writable {
+		return 0, ErrReadOnly
+	}
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.ErrShortWrite
+	}
+	n := copy(m.data[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Sync flushes modifications to the underlying file.
+func (m *MmapFile) Sync() error {
+	if !m.writable {
+		return nil
+	}
+	if m.mapped {
+		return msync(m.data)
+	}
+	if _, err := m.f.WriteAt(m.data, 0); err != nil {
+		return err
+	}
+	return m.f.Sync()
+}
+
+// Close syncs a writable mapping, unmaps it and closes the file. ReadAt and
+// WriteAt on m fail afterwards, but Readers and Writers created on m, and slices
+// from Bytes or ReadRaw, must not be used at all.
+func (m *MmapFile) Close() error {
+	err := m.Sync()
+	if m.mapped {
+		if uerr := munmap(m.data); err == nil {
+			err = uerr
+		}
+		m.mapped = false
+	}
+	m.data = nil
+	if cerr := m.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package binaryio
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("binaryio: mmap not supported")
+
+func mmap(f *os.File, size int, writable bool) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(b []byte) error {
+	return nil
+}
+
+func msync(b []byte) error {
+	return nil
+}
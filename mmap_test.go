@@ -0,0 +1,93 @@
+package binaryio
+
+import (
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	testFileName := "test.bin"
+
+	fw := openWriteFile(testFileName, t)
+	w := NewWriter(fw)
+	w.WriteU32(0x12345678, BigEndian)
+	w.WriteS32("data", BigEndian)
+	w.WriteU64(0, LittleEndian)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	fw.Close()
+
+	{
+		m, err := OpenMmap(testFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewReader(m)
+		if r.ReadU32(BigEndian) != 0x12345678 {
+			t.Fatalf("Invalid ReadU32")
+		}
+		raw := r.ReadRaw(4)
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		if string(raw) != "data" || &raw[0] != &m.Bytes()[4] {
+			t.Fatalf("Invalid ReadRaw %q", raw)
+		}
+		r.ReadRaw(9)
+		if r.Err() == nil {
+			t.Fatalf("ReadRaw past end must fail")
+		}
+		r = NewReader(m)
+		r.SetOffset(-1)
+		if r.ReadU8(); r.Err() == nil {
+			t.Fatalf("ReadU8 at a negative offset must fail")
+		}
+		if w := NewWriter(m); w.WriteU8(0) != 0 || w.Err() != ErrReadOnly {
+			t.Fatalf("Invalid read-only write %v", w.Err())
+		}
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{
+		m, err := OpenMmapRW(testFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(m, WithBufferSize(64))
+		w.SetOffset(8)
+		w.WriteU64(0x0102030405060708, LittleEndian)
+		if w.Err() != nil {
+			t.Fatal(w.Err())
+		}
+		if m.Bytes()[8] != 0x08 {
+			t.Fatalf("Writer must write into the mapping")
+		}
+		w.WriteU8(0)
+		if w.Err() == nil {
+			t.Fatalf("WriteU8 past end must fail")
+		}
+		w = NewWriter(m)
+		w.SetOffset(-1)
+		if w.WriteU8(0); w.Err() == nil {
+			t.Fatalf("WriteU8 at a negative offset must fail")
+		}
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		r.SetOffset(8)
+		if r.ReadU64(LittleEndian) != 0x0102030405060708 {
+			t.Fatalf("Invalid ReadU64 after mmap write")
+		}
+		fr.Close()
+	}
+
+	removeFile(testFileName, t)
+}
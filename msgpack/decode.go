@@ -0,0 +1,731 @@
+package msgpack
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Decoder reads MessagePack values.
+type Decoder struct {
+	br    *bio.Reader
+	depth int
+}
+
+// NewDecoder returns a Decoder starting at offset 0 of r.
+func NewDecoder(r io.ReaderAt) *Decoder {
+	return &Decoder{br: bio.NewReader(r, bio.WithBufferSize(64<<10))}
+}
+
+// Offset returns the position of the next value.
+func (d *Decoder) Offset() int64 {
+	return d.br.GetOffset()
+}
+
+// fail returns the reader error, reporting a truncated value as io.ErrUnexpectedEOF.
+func (d *Decoder) fail() error {
+	return more(d.br.Err())
+}
+
+// more reports the end of input inside a value as io.ErrUnexpectedEOF.
+func more(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// peek returns the format code of the next value, or io.EOF at the end of input.
+func (d *Decoder) peek() (byte, error) {
+	off := d.br.GetOffset()
+	c := d.br.ReadU8()
+	if err := d.br.Err(); err != nil {
+		return 0, err
+	}
+	d.br.SetOffset(off)
+	return c, nil
+}
+
+func (d *Decoder) readCode() (byte, error) {
+	c := d.br.ReadU8()
+	if err := d.br.Err(); err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
+// length reads a big-endian length field of width 1, 2 or 4 bytes.
+func (d *Decoder) length(width int) (int, error) {
+	var n uint64
+	switch width {
+	case 1:
+		n = uint64(d.br.ReadU8())
+	case 2:
+		n = uint64(d.br.ReadU16(be))
+	case 4:
+		n = uint64(d.br.ReadU32(be))
+	}
+	if d.br.Err() != nil {
+		return 0, d.fail()
+	}
+	if n > math.MaxInt32 {
+		return 0, ErrRange
+	}
+	return int(n), nil
+}
+
+func (d *Decoder) raw(n int) ([]byte, error) {
+	if n == 0 {
+		return []byte{}, nil
+	}
+	// make sure the data exists before allocating for it
+	if k, _ := d.br.ReadAt(make([]byte, 1), d.br.GetOffset()+int64(n)-1); k != 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.br.ReadRaw(uint64(n))
+	if d.br.Err() != nil {
+		return nil, d.fail()
+	}
+	return b, nil
+}
+
+// IsNil reports whether the next value is nil without consuming it.
+func (d *Decoder) IsNil() bool {
+	c, err := d.peek()
+	return err == nil && c == codeNil
+}
+
+// ReadNil reads nil.
+func (d *Decoder) ReadNil() error {
+	c, err := d.readCode()
+	if err != nil {
+		return err
+	}
+	if c != codeNil {
+		return ErrType
+	}
+	return nil
+}
+
+// ReadBool reads a bool.
+func (d *Decoder) ReadBool() (bool, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return false, err
+	}
+	switch c {
+	case codeTrue:
+		return true, nil
+	case codeFalse:
+		return false, nil
+	}
+	return false, ErrType
+}
+
+// readInteger reads any integer format. neg reports a negative value held in i;
+// otherwise the value is in u.
+func (d *Decoder) readInteger() (i int64, u uint64, neg bool, err error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	br := d.br
+	switch {
+	case c <= 0x7F:
+		u = uint64(c)
+	case c >= 0xE0:
+		i, neg = int64(int8(c)), true
+	case c == codeUint8:
+		u = uint64(br.ReadU8())
+	case c == codeUint16:
+		u = uint64(br.ReadU16(be))
+	case c == codeUint32:
+		u = uint64(br.ReadU32(be))
+	case c == codeUint64:
+		u = br.ReadU64(be)
+	case c == codeInt8:
+		i = int64(br.ReadI8())
+	case c == codeInt16:
+		i = int64(br.ReadI16(be))
+	case c == codeInt32:
+		i = int64(br.ReadI32(be))
+	case c == codeInt64:
+		i = br.ReadI64(be)
+	default:
+		return 0, 0, false, ErrType
+	}
+	if br.Err() != nil {
+		return 0, 0, false, d.fail()
+	}
+	if c >= codeInt8 && c <= codeInt64 {
+		if i >= 0 {
+			return 0, uint64(i), false, nil
+		}
+		neg = true
+	}
+	return i, u, neg, nil
+}
+
+// ReadInt reads an integer that fits an int64.
+func (d *Decoder) ReadInt() (int64, error) {
+	i, u, neg, err := d.readInteger()
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		return i, nil
+	}
+	if u > math.MaxInt64 {
+		return 0, ErrRange
+	}
+	return int64(u), nil
+}
+
+// ReadUint reads a non-negative integer.
+func (d *Decoder) ReadUint() (uint64, error) {
+	_, u, neg, err := d.readInteger()
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		return 0, ErrRange
+	}
+	return u, nil
+}
+
+// ReadFloat reads a float32, float64 or integer as a float64.
+func (d *Decoder) ReadFloat() (float64, error) {
+	c, err := d.peek()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case codeFloat32:
+		d.br.ReadU8()
+		v := d.br.ReadF32(be)
+		if d.br.Err() != nil {
+			return 0, d.fail()
+		}
+		return float64(v), nil
+	case codeFloat64:
+		d.br.ReadU8()
+		v := d.br.ReadF64(be)
+		if d.br.Err() != nil {
+			return 0, d.fail()
+		}
+		return v, nil
+	}
+	i, u, neg, err := d.readInteger()
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		return float64(i), nil
+	}
+	return float64(u), nil
+}
+
+// readLen reads the header of a str or bin value and returns its length.
+func (d *Decoder) readLen(str, bin bool) (int, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case str && c&0xE0 == fixStr:
+		return int(c & 0x1F), nil
+	case str && c >= codeStr8 && c <= codeStr32:
+		return d.length(1 << (c - codeStr8))
+	case bin && c >= codeBin8 && c <= codeBin32:
+		return d.length(1 << (c - codeBin8))
+	}
+	return 0, ErrType
+}
+
+// ReadString reads a str or bin value as a string.
+func (d *Decoder) ReadString() (string, error) {
+	n, err := d.readLen(true, true)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.raw(n)
+	return string(b), err
+}
+
+// ReadBytes reads a bin or str value.
+func (d *Decoder) ReadBytes() ([]byte, error) {
+	n, err := d.readLen(true, true)
+	if err != nil {
+		return nil, err
+	}
+	return d.raw(n)
+}
+
+// ReadArrayHeader reads the header of an array and returns its length. The
+// elements follow and can be decoded one at a time.
+func (d *Decoder) ReadArrayHeader() (int, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xF0 == fixArray:
+		return int(c & 0x0F), nil
+	case c == codeArray16:
+		return d.length(2)
+	case c == codeArray32:
+		return d.length(4)
+	}
+	return 0, ErrType
+}
+
+// ReadMapHeader reads the header of a map and returns its number of pairs.
+func (d *Decoder) ReadMapHeader() (int, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xF0 == fixMap:
+		return int(c & 0x0F), nil
+	case c == codeMap16:
+		return d.length(2)
+	case c == codeMap32:
+		return d.length(4)
+	}
+	return 0, ErrType
+}
+
+// ReadExt reads an extension value, including timestamps.
+func (d *Decoder) ReadExt() (Ext, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return Ext{}, err
+	}
+	var n int
+	switch {
+	case c >= codeFixExt1 && c <= codeFixExt16:
+		n = 1 << (c - codeFixExt1)
+	case c >= codeExt8 && c <= codeExt32:
+		if n, err = d.length(1 << (c - codeExt8)); err != nil {
+			return Ext{}, err
+		}
+	default:
+		return Ext{}, ErrType
+	}
+	typ := d.br.ReadI8()
+	if d.br.Err() != nil {
+		return Ext{}, d.fail()
+	}
+	data, err := d.raw(n)
+	return Ext{Type: typ, Data: data}, err
+}
+
+// ReadTime reads a timestamp extension. Times are returned in UTC.
+func (d *Decoder) ReadTime() (time.Time, error) {
+	x, err := d.ReadExt()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return extTime(x)
+}
+
+func extTime(x Ext) (time.Time, error) {
+	if x.Type != TimestampType {
+		return time.Time{}, ErrType
+	}
+	b := x.Data
+	switch len(b) {
+	case 4:
+		sec := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+		return time.Unix(int64(sec), 0).UTC(), nil
+	case 8:
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		nsec := v >> 34
+		if nsec >= 1e9 {
+			return time.Time{}, ErrFormat
+		}
+		return time.Unix(int64(v&(1<<34-1)), int64(nsec)).UTC(), nil
+	case 12:
+		nsec := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+		var sec uint64
+		for _, c := range b[4:] {
+			sec = sec<<8 | uint64(c)
+		}
+		if nsec >= 1e9 {
+			return time.Time{}, ErrFormat
+		}
+		return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+	}
+	return time.Time{}, ErrFormat
+}
+
+// Skip consumes the next value, including nested arrays and maps.
+func (d *Decoder) Skip() error {
+	_, err := d.decodeInterface(true)
+	return err
+}
+
+// DecodeInterface reads the next value as nil, bool, int64 (uint64 above
+// MaxInt64), float32, float64, string, []byte, []interface{},
+// map[string]interface{} (map[interface{}]interface{} for non-string keys),
+// time.Time or Ext.
+func (d *Decoder) DecodeInterface() (interface{}, error) {
+	return d.decodeInterface(false)
+}
+
+func (d *Decoder) decodeInterface(skip bool) (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == codeNil:
+		return nil, d.ReadNil()
+	case c == codeTrue || c == codeFalse:
+		return d.ReadBool()
+	case c <= 0x7F || c >= 0xE0 || c >= codeUint8 && c <= codeInt64:
+		i, u, neg, err := d.readInteger()
+		if neg || u <= math.MaxInt64 {
+			if !neg {
+				i = int64(u)
+			}
+			return i, err
+		}
+		return u, err
+	case c == codeFloat32:
+		v, err := d.ReadFloat()
+		return float32(v), err
+	case c == codeFloat64:
+		return d.ReadFloat()
+	case c&0xE0 == fixStr || c >= codeStr8 && c <= codeStr32:
+		return d.ReadString()
+	case c >= codeBin8 && c <= codeBin32:
+		return d.ReadBytes()
+	case c >= codeFixExt1 && c <= codeFixExt16 || c >= codeExt8 && c <= codeExt32:
+		x, err := d.ReadExt()
+		if err != nil || x.Type != TimestampType {
+			return x, err
+		}
+		return extTime(x)
+	}
+
+	if d.depth >= maxDepth {
+		return nil, ErrDepth
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	if c&0xF0 == fixArray || c == codeArray16 || c == codeArray32 {
+		n, err := d.ReadArrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		var a []interface{}
+		if !skip {
+			a = make([]interface{}, 0, minInt(n, 1<<12))
+		}
+		for i := 0; i < n; i++ {
+			v, err := d.decodeInterface(skip)
+			if err != nil {
+				return nil, more(err)
+			}
+			if !skip {
+				a = append(a, v)
+			}
+		}
+		return a, nil
+	}
+	if c&0xF0 == fixMap || c == codeMap16 || c == codeMap32 {
+		n, err := d.ReadMapHeader()
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]interface{}, 0, minInt(n, 1<<12))
+		vals := make([]interface{}, 0, minInt(n, 1<<12))
+		for i := 0; i < n; i++ {
+			k, err := d.decodeInterface(skip)
+			if err != nil {
+				return nil, more(err)
+			}
+			v, err := d.decodeInterface(skip)
+			if err != nil {
+				return nil, more(err)
+			}
+			if !skip {
+				keys, vals = append(keys, k), append(vals, v)
+			}
+		}
+		if skip {
+			return nil, nil
+		}
+		return buildMap(keys, vals)
+	}
+	d.br.ReadU8()
+	return nil, ErrFormat
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// hashable reports whether k can be used as a map key.
+func hashable(k interface{}) bool {
+	return k == nil || reflect.TypeOf(k).Comparable()
+}
+
+// buildMap returns a map[string]interface{} when all keys are strings.
+func buildMap(keys, vals []interface{}) (interface{}, error) {
+	strKeys := true
+	for _, k := range keys {
+		if _, ok := k.(string); !ok {
+			strKeys = false
+			break
+		}
+	}
+	if strKeys {
+		m := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			m[k.(string)] = vals[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, len(keys))
+	for i, k := range keys {
+		if !hashable(k) {
+			return nil, ErrType
+		}
+		m[k] = vals[i]
+	}
+	return m, nil
+}
+
+// Decode reads the next value into the value pointed to by v. Struct fields
+// are matched by name, exactly or case-insensitively; unknown keys are skipped.
+// Decode returns io.EOF when no value remains.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrPointer
+	}
+	return d.decodeValue(rv.Elem())
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if c == codeNil {
+		d.br.ReadU8()
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		t, err := d.ReadTime()
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	case extType:
+		x, err := d.ReadExt()
+		if err == nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return ErrType
+		}
+		x, err := d.DecodeInterface()
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		b, err := d.ReadBool()
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := d.ReadInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return ErrRange
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := d.ReadUint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(u) {
+			return ErrRange
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := d.ReadFloat()
+		v.SetFloat(f)
+		return err
+	case reflect.String:
+		s, err := d.ReadString()
+		v.SetString(s)
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (c >= codeBin8 && c <= codeBin32 || c&0xE0 == fixStr || c >= codeStr8 && c <= codeStr32) {
+			b, err := d.ReadBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		return d.decodeArray(v)
+	case reflect.Array:
+		return d.decodeArray(v)
+	case reflect.Map:
+		return d.decodeMap(v)
+	case reflect.Struct:
+		return d.decodeStruct(v)
+	}
+	return ErrType
+}
+
+func (d *Decoder) enter() error {
+	if d.depth >= maxDepth {
+		return ErrDepth
+	}
+	d.depth++
+	return nil
+}
+
+func (d *Decoder) decodeArray(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), 0, minInt(n, 1<<12)))
+	}
+	for i := 0; i < n; i++ {
+		if v.Kind() == reflect.Array {
+			if i >= v.Len() {
+				if err := d.Skip(); err != nil {
+					return more(err)
+				}
+				continue
+			}
+			if err := d.decodeValue(v.Index(i)); err != nil {
+				return more(err)
+			}
+			continue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decodeValue(elem); err != nil {
+			return more(err)
+		}
+		v.Set(reflect.Append(v, elem))
+	}
+	return nil
+}
+
+func (d *Decoder) decodeMap(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), minInt(n, 1<<12)))
+	}
+	for i := 0; i < n; i++ {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := d.decodeValue(key); err != nil {
+			return more(err)
+		}
+		if key.Kind() == reflect.Interface && !hashable(key.Interface()) {
+			return ErrType
+		}
+		val := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decodeValue(val); err != nil {
+			return more(err)
+		}
+		v.SetMapIndex(key, val)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeStruct(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	fields := structFields(v.Type())
+	for i := 0; i < n; i++ {
+		name, err := d.ReadString()
+		if err != nil {
+			return more(err)
+		}
+		f := findField(fields, name)
+		if f == nil {
+			if err := d.Skip(); err != nil {
+				return more(err)
+			}
+			continue
+		}
+		if err := d.decodeValue(v.Field(f.index)); err != nil {
+			return more(err)
+		}
+	}
+	return nil
+}
+
+func findField(fields []field, name string) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
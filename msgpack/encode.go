@@ -0,0 +1,340 @@
+package msgpack
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+var be = bio.BigEndian
+
+// Encoder writes MessagePack values.
+type Encoder struct {
+	w     *bio.Writer
+	depth int
+}
+
+// NewEncoder returns an Encoder starting at offset 0 of w. Call Flush when done.
+func NewEncoder(w io.WriterAt) *Encoder {
+	return &Encoder{w: bio.NewWriter(w, bio.WithBufferSize(64<<10))}
+}
+
+// Flush writes buffered data.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Offset returns the number of bytes written so far.
+func (e *Encoder) Offset() int64 {
+	return e.w.GetOffset()
+}
+
+// WriteNil writes nil.
+func (e *Encoder) WriteNil() error {
+	e.w.WriteU8(codeNil)
+	return e.w.Err()
+}
+
+// WriteBool writes a bool.
+func (e *Encoder) WriteBool(b bool) error {
+	if b {
+		e.w.WriteU8(codeTrue)
+	} else {
+		e.w.WriteU8(codeFalse)
+	}
+	return e.w.Err()
+}
+
+// WriteInt writes v in the smallest integer format.
+func (e *Encoder) WriteInt(v int64) error {
+	if v >= 0 {
+		return e.WriteUint(uint64(v))
+	}
+	w := e.w
+	switch {
+	case v >= -32:
+		w.WriteI8(int8(v))
+	case v >= math.MinInt8:
+		w.WriteU8(codeInt8)
+		w.WriteI8(int8(v))
+	case v >= math.MinInt16:
+		w.WriteU8(codeInt16)
+		w.WriteI16(int16(v), be)
+	case v >= math.MinInt32:
+		w.WriteU8(codeInt32)
+		w.WriteI32(int32(v), be)
+	default:
+		w.WriteU8(codeInt64)
+		w.WriteI64(v, be)
+	}
+	return w.Err()
+}
+
+// WriteUint writes v in the smallest integer format.
+func (e *Encoder) WriteUint(v uint64) error {
+	w := e.w
+	switch {
+	case v <= 0x7F:
+		w.WriteU8(uint8(v))
+	case v <= math.MaxUint8:
+		w.WriteU8(codeUint8)
+		w.WriteU8(uint8(v))
+	case v <= math.MaxUint16:
+		w.WriteU8(codeUint16)
+		w.WriteU16(uint16(v), be)
+	case v <= math.MaxUint32:
+		w.WriteU8(codeUint32)
+		w.WriteU32(uint32(v), be)
+	default:
+		w.WriteU8(codeUint64)
+		w.WriteU64(v, be)
+	}
+	return w.Err()
+}
+
+// WriteFloat32 writes a float32.
+func (e *Encoder) WriteFloat32(v float32) error {
+	e.w.WriteU8(codeFloat32)
+	e.w.WriteF32(v, be)
+	return e.w.Err()
+}
+
+// WriteFloat64 writes a float64.
+func (e *Encoder) WriteFloat64(v float64) error {
+	e.w.WriteU8(codeFloat64)
+	e.w.WriteF64(v, be)
+	return e.w.Err()
+}
+
+// writeHeader writes a fix, 8-, 16- or 32-bit length header. fix is 0 when the
+// type has no fix format and code8 is 0 when it has no 8-bit format.
+func (e *Encoder) writeHeader(n int, fix, fixMax byte, code8, code16, code32 byte) error {
+	w := e.w
+	switch {
+	case n < 0 || uint64(n) > math.MaxUint32:
+		return ErrRange
+	case fix != 0 && n <= int(fixMax):
+		w.WriteU8(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		w.WriteU8(code8)
+		w.WriteU8(uint8(n))
+	case n <= math.MaxUint16:
+		w.WriteU8(code16)
+		w.WriteU16(uint16(n), be)
+	default:
+		w.WriteU8(code32)
+		w.WriteU32(uint32(n), be)
+	}
+	return w.Err()
+}
+
+// WriteString writes a str.
+func (e *Encoder) WriteString(s string) error {
+	if err := e.writeHeader(len(s), fixStr, 31, codeStr8, codeStr16, codeStr32); err != nil {
+		return err
+	}
+	e.w.WriteRaw([]byte(s))
+	return e.w.Err()
+}
+
+// WriteBytes writes a bin.
+func (e *Encoder) WriteBytes(b []byte) error {
+	if err := e.writeHeader(len(b), 0, 0, codeBin8, codeBin16, codeBin32); err != nil {
+		return err
+	}
+	e.w.WriteRaw(b)
+	return e.w.Err()
+}
+
+// WriteArrayHeader starts an array of n elements, which are written next.
+func (e *Encoder) WriteArrayHeader(n int) error {
+	return e.writeHeader(n, fixArray, 15, 0, codeArray16, codeArray32)
+}
+
+// WriteMapHeader starts a map of n key-value pairs, which are written next.
+func (e *Encoder) WriteMapHeader(n int) error {
+	return e.writeHeader(n, fixMap, 15, 0, codeMap16, codeMap32)
+}
+
+// WriteExt writes an extension value.
+func (e *Encoder) WriteExt(typ int8, data []byte) error {
+	w := e.w
+	switch n := len(data); {
+	case n == 1 || n == 2 || n == 4 || n == 8 || n == 16:
+		code := byte(codeFixExt1)
+		for m := n; m > 1; m >>= 1 {
+			code++
+		}
+		w.WriteU8(code)
+	case n <= math.MaxUint8:
+		w.WriteU8(codeExt8)
+		w.WriteU8(uint8(n))
+	case n <= math.MaxUint16:
+		w.WriteU8(codeExt16)
+		w.WriteU16(uint16(n), be)
+	case uint64(n) <= math.MaxUint32:
+		w.WriteU8(codeExt32)
+		w.WriteU32(uint32(n), be)
+	default:
+		return ErrRange
+	}
+	w.WriteI8(typ)
+	w.WriteRaw(data)
+	return w.Err()
+}
+
+// WriteTime writes a timestamp extension in its smallest form.
+func (e *Encoder) WriteTime(t time.Time) error {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	w := e.w
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		w.WriteU8(codeFixExt1 + 2)
+		w.WriteI8(TimestampType)
+		w.WriteU32(uint32(sec), be)
+	case sec >= 0 && sec < 1<<34:
+		w.WriteU8(codeFixExt1 + 3)
+		w.WriteI8(TimestampType)
+		w.WriteU64(nsec<<34|uint64(sec), be)
+	default:
+		w.WriteU8(codeExt8)
+		w.WriteU8(12)
+		w.WriteI8(TimestampType)
+		w.WriteU32(uint32(nsec), be)
+		w.WriteI64(sec, be)
+	}
+	return w.Err()
+}
+
+// Encode writes v. Structs are written as maps keyed by field name; map keys
+// of string and integer kinds are sorted for deterministic output.
+func (e *Encoder) Encode(v interface{}) error {
+	return e.encodeValue(reflect.ValueOf(v))
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	if e.depth >= maxDepth {
+		return ErrDepth
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	if !v.IsValid() {
+		return e.WriteNil()
+	}
+	switch v.Type() {
+	case timeType:
+		return e.WriteTime(v.Interface().(time.Time))
+	case extType:
+		x := v.Interface().(Ext)
+		return e.WriteExt(x.Type, x.Data)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		return e.encodeValue(v.Elem())
+	case reflect.Bool:
+		return e.WriteBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.WriteInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.WriteUint(v.Uint())
+	case reflect.Float32:
+		return e.WriteFloat32(float32(v.Float()))
+	case reflect.Float64:
+		return e.WriteFloat64(v.Float())
+	case reflect.String:
+		return e.WriteString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.WriteBytes(v.Bytes())
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return e.WriteBytes(b)
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return e.WriteNil()
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	}
+	return ErrType
+}
+
+func (e *Encoder) encodeArray(v reflect.Value) error {
+	if err := e.WriteArrayHeader(v.Len()); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encodeValue(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(v reflect.Value) error {
+	if err := e.WriteMapHeader(v.Len()); err != nil {
+		return err
+	}
+	keys := v.MapKeys()
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	}
+	for _, k := range keys {
+		if err := e.encodeValue(k); err != nil {
+			return err
+		}
+		if err := e.encodeValue(v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	fields := structFields(v.Type())
+	n := 0
+	for _, f := range fields {
+		if !f.omitEmpty || !isEmpty(v.Field(f.index)) {
+			n++
+		}
+	}
+	if err := e.WriteMapHeader(n); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		if err := e.WriteString(f.name); err != nil {
+			return err
+		}
+		if err := e.encodeValue(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
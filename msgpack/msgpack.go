@@ -0,0 +1,137 @@
+// Package msgpack encodes and decodes MessagePack. Values map to and from Go
+// values, including tagged structs, and the Encoder and Decoder also expose
+// the individual format types so large arrays can be streamed element by
+// element.
+package msgpack
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the msgpack package.
+var (
+	ErrFormat  = errors.New("msgpack: invalid format")
+	ErrType    = errors.New("msgpack: type mismatch")
+	ErrRange   = errors.New("msgpack: value out of range")
+	ErrPointer = errors.New("msgpack: Decode requires a non-nil pointer")
+	ErrDepth   = errors.New("msgpack: nesting too deep")
+)
+
+// Format codes.
+const (
+	codeNil      = 0xC0
+	codeFalse    = 0xC2
+	codeTrue     = 0xC3
+	codeBin8     = 0xC4
+	codeBin16    = 0xC5
+	codeBin32    = 0xC6
+	codeExt8     = 0xC7
+	codeExt16    = 0xC8
+	codeExt32    = 0xC9
+	codeFloat32  = 0xCA
+	codeFloat64  = 0xCB
+	codeUint8    = 0xCC
+	codeUint16   = 0xCD
+	codeUint32   = 0xCE
+	codeUint64   = 0xCF
+	codeInt8     = 0xD0
+	codeInt16    = 0xD1
+	codeInt32    = 0xD2
+	codeInt64    = 0xD3
+	codeFixExt1  = 0xD4
+	codeFixExt16 = 0xD8
+	codeStr8     = 0xD9
+	codeStr16    = 0xDA
+	codeStr32    = 0xDB
+	codeArray16  = 0xDC
+	codeArray32  = 0xDD
+	codeMap16    = 0xDE
+	codeMap32    = 0xDF
+
+	fixMap   = 0x80
+	fixArray = 0x90
+	fixStr   = 0xA0
+)
+
+// TimestampType is the extension type of timestamps.
+const TimestampType = -1
+
+// maxDepth limits the nesting of decoded and encoded values.
+const maxDepth = 10000
+
+// Ext is an extension value other than a timestamp.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	extType   = reflect.TypeOf(Ext{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// field describes a struct field. Names come from the msgpack tag when
+// present, as in `msgpack:"name,omitempty"`; a "-" tag skips the field.
+type field struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func structFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		tag := sf.Tag.Get("msgpack")
+		if tag == "-" {
+			continue
+		}
+		f := field{name: sf.Name, index: i}
+		parts := strings.Split(tag, ",")
+		if parts[0] != "" {
+			f.name = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package msgpack
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X, Y int
+}
+
+type record struct {
+	Name    string            `msgpack:"name"`
+	Age     uint8             `msgpack:"age,omitempty"`
+	Score   float64           `msgpack:"score"`
+	Tags    []string          `msgpack:"tags"`
+	Attrs   map[string]int    `msgpack:"attrs"`
+	Raw     []byte            `msgpack:"raw"`
+	When    time.Time         `msgpack:"when"`
+	Origin  *point            `msgpack:"origin"`
+	Any     interface{}       `msgpack:"any"`
+	Skipped string            `msgpack:"-"`
+	Grid    [2]int8           `msgpack:"grid"`
+	Ext     Ext               `msgpack:"ext"`
+	Extra   map[uint16]string `msgpack:"extra,omitempty"`
+}
+
+// discard is an io.WriterAt that drops all writes.
+type discard struct{}
+
+func (discard) WriteAt(p []byte, off int64) (int, error) { return len(p), nil }
+
+func encodeBytes(t *testing.T, fn func(e *Encoder) error) []byte {
+	testFileName := "test.msgpack"
+	defer os.Remove(testFileName)
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEncoder(fw)
+	if err := fn(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	fw.Close()
+	b, err := ioutil.ReadFile(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want []byte
+	}{
+		{nil, []byte{0xC0}},
+		{true, []byte{0xC3}},
+		{5, []byte{0x05}},
+		{-32, []byte{0xE0}},
+		{-33, []byte{0xD0, 0xDF}},
+		{200, []byte{0xCC, 0xC8}},
+		{uint16(300), []byte{0xCD, 0x01, 0x2C}},
+		{-40000, []byte{0xD2, 0xFF, 0xFF, 0x63, 0xC0}},
+		{uint64(math.MaxUint64), []byte{0xCF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{float32(1.5), []byte{0xCA, 0x3F, 0xC0, 0x00, 0x00}},
+		{"abc", []byte{0xA3, 'a', 'b', 'c'}},
+		{[]byte{1, 2}, []byte{0xC4, 0x02, 0x01, 0x02}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xA1, 'a', 0x01, 0xA1, 'b', 0x02}},
+		{point{1, 2}, []byte{0x82, 0xA1, 'X', 0x01, 0xA1, 'Y', 0x02}},
+		{Ext{Type: 5, Data: []byte{9}}, []byte{0xD4, 0x05, 0x09}},
+		{time.Unix(1, 0), []byte{0xD6, 0xFF, 0x00, 0x00, 0x00, 0x01}},
+	}
+	for _, tt := range tests {
+		got := encodeBytes(t, func(e *Encoder) error { return e.Encode(tt.v) })
+		if !bytes.Equal(got, tt.want) {
+			t.Fatalf("Invalid encoding of %v: % x", tt.v, got)
+		}
+	}
+
+	long := string(bytes.Repeat([]byte{'x'}, 300))
+	got := encodeBytes(t, func(e *Encoder) error { return e.WriteString(long) })
+	if !bytes.Equal(got[:3], []byte{0xDA, 0x01, 0x2C}) || len(got) != 303 {
+		t.Fatalf("Invalid str16 header % x", got[:3])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	when := time.Date(2020, 9, 7, 12, 0, 0, 123456789, time.UTC)
+	src := record{
+		Name:    "binaryio",
+		Score:   -0.25,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"x": -1, "y": 1 << 40},
+		Raw:     []byte{0, 1, 2},
+		When:    when,
+		Origin:  &point{3, -4},
+		Any:     []interface{}{int64(1), "two", nil},
+		Skipped: "hidden",
+		Grid:    [2]int8{-1, 1},
+		Ext:     Ext{Type: 42, Data: []byte("payload")},
+	}
+	b := encodeBytes(t, func(e *Encoder) error { return e.Encode(&src) })
+
+	var dst record
+	d := NewDecoder(bytes.NewReader(b))
+	if err := d.Decode(&dst); err != nil {
+		t.Fatal(err)
+	}
+	want := src
+	want.Skipped = ""
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Invalid round trip\n%+v\n%+v", dst, want)
+	}
+	if err := d.Decode(&dst); err != io.EOF {
+		t.Fatalf("Invalid end of stream %v", err)
+	}
+
+	// generic decoding of the same bytes
+	v, err := NewDecoder(bytes.NewReader(b)).DecodeInterface()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]interface{})
+	if _, ok := m["age"]; ok {
+		t.Fatalf("omitempty field encoded")
+	}
+	if m["name"] != "binaryio" || m["score"] != -0.25 || !m["when"].(time.Time).Equal(when) {
+		t.Fatalf("Invalid generic decode %v", m)
+	}
+	if x, ok := m["ext"].(Ext); !ok || x.Type != 42 {
+		t.Fatalf("Invalid generic ext %v", m["ext"])
+	}
+
+	// all three timestamp forms
+	for _, tm := range []time.Time{
+		time.Unix(1<<32-1, 0),
+		time.Unix(1<<34-1, 999999999),
+		time.Unix(-1, 5),
+	} {
+		b := encodeBytes(t, func(e *Encoder) error { return e.WriteTime(tm) })
+		got, err := NewDecoder(bytes.NewReader(b)).ReadTime()
+		if err != nil || !got.Equal(tm) {
+			t.Fatalf("Invalid timestamp %v %v (% x)", got, err, b)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	const n = 100000
+	b := encodeBytes(t, func(e *Encoder) error {
+		if err := e.WriteArrayHeader(n); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := e.Encode(point{i, -i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	d := NewDecoder(bytes.NewReader(b))
+	count, err := d.ReadArrayHeader()
+	if err != nil || count != n {
+		t.Fatalf("Invalid array header %d %v", count, err)
+	}
+	for i := 0; i < count; i++ {
+		var p point
+		if err := d.Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.X != i || p.Y != -i {
+			t.Fatalf("Invalid element %d %+v", i, p)
+		}
+	}
+	if d.Offset() != int64(len(b)) {
+		t.Fatalf("Invalid offset %d", d.Offset())
+	}
+
+	d = NewDecoder(bytes.NewReader(b))
+	if err := d.Skip(); err != nil || d.Offset() != int64(len(b)) {
+		t.Fatalf("Invalid skip %d %v", d.Offset(), err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var s string
+	if err := NewDecoder(bytes.NewReader([]byte{0xA3, 'a'})).Decode(&s); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid truncation error %v", err)
+	}
+	if err := NewDecoder(bytes.NewReader([]byte{0x01})).Decode(&s); err != ErrType {
+		t.Fatalf("Invalid type error %v", err)
+	}
+	var i8 int8
+	if err := NewDecoder(bytes.NewReader([]byte{0xCC, 0xC8})).Decode(&i8); err != ErrRange {
+		t.Fatalf("Invalid range error %v", err)
+	}
+	var u uint
+	if err := NewDecoder(bytes.NewReader([]byte{0xFF})).Decode(&u); err != ErrRange {
+		t.Fatalf("Invalid negative error %v", err)
+	}
+	if err := NewDecoder(bytes.NewReader([]byte{0x01})).Decode(s); err != ErrPointer {
+		t.Fatalf("Invalid pointer error %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader([]byte{0xC1})).DecodeInterface(); err != ErrFormat {
+		t.Fatalf("Invalid format error %v", err)
+	}
+	deep := append(bytes.Repeat([]byte{0x91}, maxDepth+1), 0xC0)
+	if _, err := NewDecoder(bytes.NewReader(deep)).DecodeInterface(); err != ErrDepth {
+		t.Fatalf("Invalid depth error %v", err)
+	}
+	loop := []interface{}{nil}
+	loop[0] = loop
+	if err := NewEncoder(discard{}).Encode(loop); err != ErrDepth {
+		t.Fatalf("Invalid encode depth error %v", err)
+	}
+	// unhashable key
+	var m map[interface{}]interface{}
+	if err := NewDecoder(bytes.NewReader([]byte{0x81, 0x91, 0x01, 0x02})).Decode(&m); err != ErrType {
+		t.Fatalf("Invalid key type error %v", err)
+	}
+	// truncated containers
+	var a []int
+	if err := NewDecoder(bytes.NewReader([]byte{0x92, 0x01})).Decode(&a); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid array truncation error %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader([]byte{0x81, 0x01})).DecodeInterface(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid map truncation error %v", err)
+	}
+	// a 2 GiB bin header without the data
+	if _, err := NewDecoder(bytes.NewReader([]byte{0xC6, 0x7F, 0xFF, 0xFF, 0xFF})).ReadBytes(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid bin truncation error %v", err)
+	}
+	if err := NewEncoder(discard{}).Encode(make(chan int)); err != ErrType {
+		t.Fatalf("Invalid encode type error %v", err)
+	}
+}
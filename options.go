@@ -0,0 +1,32 @@
+package binaryio
+
+import (
+	"os"
+)
+
+// Option configures a Reader or Writer.
+type Option func(*options)
+
+type options struct {
+	bufSize int
+}
+
+// WithBufferSize enables buffering with a window of n bytes.
+func WithBufferSize(n int) Option {
+	return func(o *options) {
+		o.bufSize = n
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithPageBuffer enables buffering with a window of one memory page.
+func WithPageBuffer() Option {
+	return WithBufferSize(os.Getpagesize())
+}
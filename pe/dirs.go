@@ -0,0 +1,296 @@
+package pe
+
+import (
+	"io"
+	"unicode/utf16"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// ImportFunc is an imported function, referenced by name or by ordinal.
+type ImportFunc struct {
+	Name      string
+	Hint      uint16
+	Ordinal   uint16
+	ByOrdinal bool
+}
+
+// Import lists the functions imported from one DLL.
+type Import struct {
+	DLL       string
+	Functions []ImportFunc
+}
+
+// Imports parses the import directory.
+func (f *File) Imports() ([]*Import, error) {
+	dir, err := f.directory(DirImport)
+	if err != nil {
+		return nil, err
+	}
+	plus := f.OptionalHeader.Magic == MagicPE32Plus
+	br := f.reader()
+
+	var imports []*Import
+	for rva := dir.VirtualAddress; ; rva += 20 {
+		if err := f.seek(br, rva); err != nil {
+			return nil, err
+		}
+		lookup := br.ReadU32(le)
+		br.ReadU32(le) // TimeDateStamp
+		br.ReadU32(le) // ForwarderChain
+		name := br.ReadU32(le)
+		first := br.ReadU32(le)
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		if lookup == 0 && name == 0 && first == 0 {
+			return imports, nil
+		}
+		if len(imports) >= 1<<16 {
+			return nil, ErrFormat
+		}
+
+		imp := &Import{}
+		if imp.DLL, err = f.stringAt(br, name); err != nil {
+			return nil, err
+		}
+		if lookup == 0 {
+			lookup = first
+		}
+		if imp.Functions, err = f.thunks(br, lookup, plus); err != nil {
+			return nil, err
+		}
+		imports = append(imports, imp)
+	}
+}
+
+// thunks reads a zero-terminated import lookup table.
+func (f *File) thunks(br *bio.Reader, rva uint32, plus bool) ([]ImportFunc, error) {
+	var funcs []ImportFunc
+	for {
+		if err := f.seek(br, rva); err != nil {
+			return nil, err
+		}
+		var v, ordinalFlag uint64
+		if plus {
+			v, ordinalFlag = br.ReadU64(le), 1<<63
+			rva += 8
+		} else {
+			v, ordinalFlag = uint64(br.ReadU32(le)), 1<<31
+			rva += 4
+		}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		if v == 0 {
+			return funcs, nil
+		}
+		if len(funcs) >= 1<<16 {
+			return nil, ErrFormat
+		}
+		if v&ordinalFlag != 0 {
+			funcs = append(funcs, ImportFunc{Ordinal: uint16(v), ByOrdinal: true})
+			continue
+		}
+		if err := f.seek(br, uint32(v)); err != nil {
+			return nil, err
+		}
+		fn := ImportFunc{Hint: br.ReadU16(le)}
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		name, err := cstring(br, br.GetOffset())
+		if err != nil {
+			return nil, err
+		}
+		fn.Name = name
+		funcs = append(funcs, fn)
+	}
+}
+
+// Export is an exported function. Forwarder is set instead of RVA pointing
+// at code when the export is forwarded to another DLL.
+type Export struct {
+	Name      string
+	Ordinal   uint32
+	RVA       uint32
+	Forwarder string
+}
+
+// Exports parses the export directory and returns the DLL name and its exports
+// in ordinal order. Exports without a name have an empty Name.
+func (f *File) Exports() (string, []*Export, error) {
+	dir, err := f.directory(DirExport)
+	if err != nil {
+		return "", nil, err
+	}
+	br := f.reader()
+	if err := f.seek(br, dir.VirtualAddress+12); err != nil {
+		return "", nil, err
+	}
+	nameRVA := br.ReadU32(le)
+	base := br.ReadU32(le)
+	numFuncs := br.ReadU32(le)
+	numNames := br.ReadU32(le)
+	funcsRVA := br.ReadU32(le)
+	namesRVA := br.ReadU32(le)
+	ordsRVA := br.ReadU32(le)
+	if br.Err() != nil {
+		return "", nil, br.Err()
+	}
+	if numFuncs > 1<<16 || numNames > numFuncs {
+		return "", nil, ErrFormat
+	}
+	dll, err := f.stringAt(br, nameRVA)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exports := make([]*Export, numFuncs)
+	if err := f.seek(br, funcsRVA); err != nil {
+		return "", nil, err
+	}
+	for i := range exports {
+		exports[i] = &Export{Ordinal: base + uint32(i), RVA: br.ReadU32(le)}
+	}
+	names := make([]uint32, numNames)
+	ords := make([]uint16, numNames)
+	if numNames > 0 {
+		if err := f.seek(br, namesRVA); err != nil {
+			return "", nil, err
+		}
+		br.ReadU32s(names, le)
+		if err := f.seek(br, ordsRVA); err != nil {
+			return "", nil, err
+		}
+		br.ReadU16s(ords, le)
+	}
+	if br.Err() != nil {
+		return "", nil, br.Err()
+	}
+
+	for i, rva := range names {
+		if uint32(ords[i]) >= numFuncs {
+			return "", nil, ErrFormat
+		}
+		if exports[ords[i]].Name, err = f.stringAt(br, rva); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, e := range exports {
+		if e.RVA >= dir.VirtualAddress && e.RVA-dir.VirtualAddress < dir.Size {
+			if e.Forwarder, err = f.stringAt(br, e.RVA); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return dll, exports, nil
+}
+
+// ResourceDir is a node of the resource tree.
+type ResourceDir struct {
+	Characteristics uint32
+	TimeDateStamp   uint32
+	MajorVersion    uint16
+	MinorVersion    uint16
+	Entries         []*ResourceEntry
+}
+
+// ResourceEntry is a named or numbered child of a ResourceDir. Exactly one of
+// Dir and Data is set.
+type ResourceEntry struct {
+	Name string
+	ID   uint32
+	Dir  *ResourceDir
+	Data *ResourceData
+}
+
+// ResourceData is a leaf of the resource tree.
+type ResourceData struct {
+	RVA      uint32
+	Size     uint32
+	CodePage uint32
+	f        *File
+}
+
+// Open returns a reader over the resource bytes.
+func (d *ResourceData) Open() (*io.SectionReader, error) {
+	off, err := d.f.Offset(d.RVA)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(d.f.src, off, int64(d.Size)), nil
+}
+
+// Resources parses the resource directory tree.
+func (f *File) Resources() (*ResourceDir, error) {
+	dir, err := f.directory(DirResource)
+	if err != nil {
+		return nil, err
+	}
+	base, err := f.Offset(dir.VirtualAddress)
+	if err != nil {
+		return nil, err
+	}
+	return f.readResourceDir(f.reader(), base, 0, make(map[uint32]bool))
+}
+
+func (f *File) readResourceDir(br *bio.Reader, base int64, off uint32, visited map[uint32]bool) (*ResourceDir, error) {
+	if visited[off] {
+		return nil, ErrFormat
+	}
+	visited[off] = true
+
+	br.SetOffset(base + int64(off))
+	d := &ResourceDir{}
+	d.Characteristics = br.ReadU32(le)
+	d.TimeDateStamp = br.ReadU32(le)
+	d.MajorVersion = br.ReadU16(le)
+	d.MinorVersion = br.ReadU16(le)
+	n := int(br.ReadU16(le)) + int(br.ReadU16(le))
+	raw := make([]uint32, 2*n)
+	br.ReadU32s(raw, le)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	for i := 0; i < n; i++ {
+		name, target := raw[2*i], raw[2*i+1]
+		e := &ResourceEntry{}
+		if name&(1<<31) != 0 {
+			s, err := resourceName(br, base+int64(name&^(1<<31)))
+			if err != nil {
+				return nil, err
+			}
+			e.Name = s
+		} else {
+			e.ID = name
+		}
+		if target&(1<<31) != 0 {
+			sub, err := f.readResourceDir(br, base, target&^(1<<31), visited)
+			if err != nil {
+				return nil, err
+			}
+			e.Dir = sub
+		} else {
+			br.SetOffset(base + int64(target))
+			e.Data = &ResourceData{RVA: br.ReadU32(le), Size: br.ReadU32(le), CodePage: br.ReadU32(le), f: f}
+			if br.Err() != nil {
+				return nil, br.Err()
+			}
+		}
+		d.Entries = append(d.Entries, e)
+	}
+	return d, nil
+}
+
+// resourceName reads a length-prefixed UTF-16LE resource name.
+func resourceName(br *bio.Reader, off int64) (string, error) {
+	br.SetOffset(off)
+	u := make([]uint16, br.ReadU16(le))
+	br.ReadU16s(u, le)
+	if br.Err() != nil {
+		return "", br.Err()
+	}
+	return string(utf16.Decode(u)), nil
+}
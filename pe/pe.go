@@ -0,0 +1,393 @@
+// Package pe parses PE/COFF executables: the DOS header and stub, COFF and
+// optional headers, sections, imports, exports and resources.
+package pe
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the pe package.
+var (
+	ErrFormat   = errors.New("pe: invalid format")
+	ErrRVA      = errors.New("pe: RVA outside of sections")
+	ErrNotFound = errors.New("pe: data directory not present")
+)
+
+// Optional header magic values.
+const (
+	MagicPE32     = 0x10B
+	MagicPE32Plus = 0x20B
+)
+
+// Data directory indexes.
+const (
+	DirExport      = 0
+	DirImport      = 1
+	DirResource    = 2
+	DirException   = 3
+	DirSecurity    = 4
+	DirBaseReloc   = 5
+	DirDebug       = 6
+	DirTLS         = 9
+	DirIAT         = 12
+	DirCLRRuntime  = 14
+	numDirectories = 16
+)
+
+var le = bio.LittleEndian
+
+// DOSHeader is the MS-DOS header at the start of the file. Lfanew is the
+// offset of the PE signature.
+type DOSHeader struct {
+	Magic    uint16
+	Cblp     uint16
+	Cp       uint16
+	Crlc     uint16
+	Cparhdr  uint16
+	MinAlloc uint16
+	MaxAlloc uint16
+	SS       uint16
+	SP       uint16
+	Csum     uint16
+	IP       uint16
+	CS       uint16
+	Lfarlc   uint16
+	Ovno     uint16
+	Res      [4]uint16
+	OEMID    uint16
+	OEMInfo  uint16
+	Res2     [10]uint16
+	Lfanew   uint32
+}
+
+// FileHeader is the COFF file header.
+type FileHeader struct {
+	Machine              uint16
+	NumberOfSections     uint16
+	TimeDateStamp        uint32
+	PointerToSymbolTable uint32
+	NumberOfSymbols      uint32
+	SizeOfOptionalHeader uint16
+	Characteristics      uint16
+}
+
+// DataDirectory locates a table by RVA.
+type DataDirectory struct {
+	VirtualAddress uint32
+	Size           uint32
+}
+
+// OptionalHeader holds both PE32 and PE32+ optional headers. Fields that are
+// 64 bits wide in PE32+ are widened; BaseOfData is only set for PE32.
+type OptionalHeader struct {
+	Magic                       uint16
+	MajorLinkerVersion          uint8
+	MinorLinkerVersion          uint8
+	SizeOfCode                  uint32
+	SizeOfInitializedData       uint32
+	SizeOfUninitializedData     uint32
+	AddressOfEntryPoint         uint32
+	BaseOfCode                  uint32
+	BaseOfData                  uint32
+	ImageBase                   uint64
+	SectionAlignment            uint32
+	FileAlignment               uint32
+	MajorOperatingSystemVersion uint16
+	MinorOperatingSystemVersion uint16
+	MajorImageVersion           uint16
+	MinorImageVersion           uint16
+	MajorSubsystemVersion       uint16
+	MinorSubsystemVersion       uint16
+	Win32VersionValue           uint32
+	SizeOfImage                 uint32
+	SizeOfHeaders               uint32
+	CheckSum                    uint32
+	Subsystem                   uint16
+	DllCharacteristics          uint16
+	SizeOfStackReserve          uint64
+	SizeOfStackCommit           uint64
+	SizeOfHeapReserve           uint64
+	SizeOfHeapCommit            uint64
+	LoaderFlags                 uint32
+	NumberOfRvaAndSizes         uint32
+	DataDirectory               []DataDirectory
+}
+
+// Section is a section table entry. Long names stored in the COFF string table
+// are resolved.
+type Section struct {
+	Name                 string
+	VirtualSize          uint32
+	VirtualAddress       uint32
+	SizeOfRawData        uint32
+	PointerToRawData     uint32
+	PointerToRelocations uint32
+	PointerToLinenumbers uint32
+	NumberOfRelocations  uint16
+	NumberOfLinenumbers  uint16
+	Characteristics      uint32
+	src                  io.ReaderAt
+}
+
+// Data returns a reader over the raw data of the section.
+func (s *Section) Data() *io.SectionReader {
+	return io.NewSectionReader(s.src, int64(s.PointerToRawData), int64(s.SizeOfRawData))
+}
+
+// File is a parsed PE file.
+type File struct {
+	DOSHeader
+	FileHeader
+	OptionalHeader *OptionalHeader
+	Sections       []*Section
+	src            io.ReaderAt
+}
+
+// Decode parses the headers and section table of r.
+func Decode(r io.ReaderAt) (*File, error) {
+	f := &File{src: r}
+	br := f.reader()
+	d := &f.DOSHeader
+	if br.ReadS16(bio.BigEndian) != "MZ" {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	d.Magic = 0x5A4D
+	fields := []*uint16{&d.Cblp, &d.Cp, &d.Crlc, &d.Cparhdr, &d.MinAlloc, &d.MaxAlloc, &d.SS, &d.SP, &d.Csum, &d.IP, &d.CS, &d.Lfarlc, &d.Ovno}
+	for _, p := range fields {
+		*p = br.ReadU16(le)
+	}
+	br.ReadU16s(d.Res[:], le)
+	d.OEMID = br.ReadU16(le)
+	d.OEMInfo = br.ReadU16(le)
+	br.ReadU16s(d.Res2[:], le)
+	d.Lfanew = br.ReadU32(le)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	br.SetOffset(int64(d.Lfanew))
+	if br.ReadS32(bio.BigEndian) != "PE\x00\x00" {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	h := &f.FileHeader
+	h.Machine = br.ReadU16(le)
+	h.NumberOfSections = br.ReadU16(le)
+	h.TimeDateStamp = br.ReadU32(le)
+	h.PointerToSymbolTable = br.ReadU32(le)
+	h.NumberOfSymbols = br.ReadU32(le)
+	h.SizeOfOptionalHeader = br.ReadU16(le)
+	h.Characteristics = br.ReadU16(le)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	optStart := br.GetOffset()
+	if h.SizeOfOptionalHeader > 0 {
+		opt, err := f.readOptionalHeader(br)
+		if err != nil {
+			return nil, err
+		}
+		f.OptionalHeader = opt
+	}
+	br.SetOffset(optStart + int64(h.SizeOfOptionalHeader))
+	if err := f.readSections(br); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) readOptionalHeader(br *bio.Reader) (*OptionalHeader, error) {
+	o := &OptionalHeader{}
+	o.Magic = br.ReadU16(le)
+	var plus bool
+	switch o.Magic {
+	case MagicPE32:
+	case MagicPE32Plus:
+		plus = true
+	default:
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	word := func() uint64 {
+		if plus {
+			return br.ReadU64(le)
+		}
+		return uint64(br.ReadU32(le))
+	}
+	o.MajorLinkerVersion = br.ReadU8()
+	o.MinorLinkerVersion = br.ReadU8()
+	o.SizeOfCode = br.ReadU32(le)
+	o.SizeOfInitializedData = br.ReadU32(le)
+	o.SizeOfUninitializedData = br.ReadU32(le)
+	o.AddressOfEntryPoint = br.ReadU32(le)
+	o.BaseOfCode = br.ReadU32(le)
+	if !plus {
+		o.BaseOfData = br.ReadU32(le)
+	}
+	o.ImageBase = word()
+	o.SectionAlignment = br.ReadU32(le)
+	o.FileAlignment = br.ReadU32(le)
+	o.MajorOperatingSystemVersion = br.ReadU16(le)
+	o.MinorOperatingSystemVersion = br.ReadU16(le)
+	o.MajorImageVersion = br.ReadU16(le)
+	o.MinorImageVersion = br.ReadU16(le)
+	o.MajorSubsystemVersion = br.ReadU16(le)
+	o.MinorSubsystemVersion = br.ReadU16(le)
+	o.Win32VersionValue = br.ReadU32(le)
+	o.SizeOfImage = br.ReadU32(le)
+	o.SizeOfHeaders = br.ReadU32(le)
+	o.CheckSum = br.ReadU32(le)
+	o.Subsystem = br.ReadU16(le)
+	o.DllCharacteristics = br.ReadU16(le)
+	o.SizeOfStackReserve = word()
+	o.SizeOfStackCommit = word()
+	o.SizeOfHeapReserve = word()
+	o.SizeOfHeapCommit = word()
+	o.LoaderFlags = br.ReadU32(le)
+	o.NumberOfRvaAndSizes = br.ReadU32(le)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if o.NumberOfRvaAndSizes > numDirectories {
+		return nil, ErrFormat
+	}
+	for i := uint32(0); i < o.NumberOfRvaAndSizes; i++ {
+		o.DataDirectory = append(o.DataDirectory, DataDirectory{br.ReadU32(le), br.ReadU32(le)})
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	return o, nil
+}
+
+func (f *File) readSections(br *bio.Reader) error {
+	for i := 0; i < int(f.NumberOfSections); i++ {
+		s := &Section{src: f.src}
+		s.Name = strings.TrimRight(br.ReadS64(bio.BigEndian), "\x00")
+		s.VirtualSize = br.ReadU32(le)
+		s.VirtualAddress = br.ReadU32(le)
+		s.SizeOfRawData = br.ReadU32(le)
+		s.PointerToRawData = br.ReadU32(le)
+		s.PointerToRelocations = br.ReadU32(le)
+		s.PointerToLinenumbers = br.ReadU32(le)
+		s.NumberOfRelocations = br.ReadU16(le)
+		s.NumberOfLinenumbers = br.ReadU16(le)
+		s.Characteristics = br.ReadU32(le)
+		if br.Err() != nil {
+			return br.Err()
+		}
+		f.Sections = append(f.Sections, s)
+	}
+
+	// Names of the form "/123" are offsets into the COFF string table, which
+	// follows the 18-byte symbol table entries.
+	strtab := int64(f.PointerToSymbolTable) + int64(f.NumberOfSymbols)*18
+	for _, s := range f.Sections {
+		if len(s.Name) < 2 || s.Name[0] != '/' || f.PointerToSymbolTable == 0 {
+			continue
+		}
+		off, err := strconv.ParseUint(s.Name[1:], 10, 32)
+		if err != nil {
+			return ErrFormat
+		}
+		name, err := cstring(br, strtab+int64(off))
+		if err != nil {
+			return err
+		}
+		s.Name = name
+	}
+	return nil
+}
+
+// reader returns a new Reader over the file. Each lookup uses its own, so
+// concurrent lookups do not share an offset or a sticky error.
+func (f *File) reader() *bio.Reader {
+	return bio.NewReader(f.src, bio.WithBufferSize(4096))
+}
+
+// cstring reads a NUL-terminated string at a file offset.
+func cstring(br *bio.Reader, off int64) (string, error) {
+	br.SetOffset(off)
+	var b []byte
+	for len(b) < 4096 {
+		c := br.ReadU8()
+		if br.Err() != nil {
+			return "", br.Err()
+		}
+		if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+	return "", ErrFormat
+}
+
+// Section returns the first section with the given name, or nil.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Offset translates an RVA to a file offset.
+func (f *File) Offset(rva uint32) (int64, error) {
+	for _, s := range f.Sections {
+		size := s.VirtualSize
+		if size == 0 {
+			size = s.SizeOfRawData
+		}
+		if rva >= s.VirtualAddress && rva-s.VirtualAddress < size {
+			if rva-s.VirtualAddress >= s.SizeOfRawData {
+				return 0, ErrRVA
+			}
+			return int64(s.PointerToRawData) + int64(rva-s.VirtualAddress), nil
+		}
+	}
+	return 0, ErrRVA
+}
+
+// stringAt reads a NUL-terminated string at an RVA.
+func (f *File) stringAt(br *bio.Reader, rva uint32) (string, error) {
+	off, err := f.Offset(rva)
+	if err != nil {
+		return "", err
+	}
+	return cstring(br, off)
+}
+
+// seek positions br at an RVA.
+func (f *File) seek(br *bio.Reader, rva uint32) error {
+	off, err := f.Offset(rva)
+	if err != nil {
+		return err
+	}
+	br.SetOffset(off)
+	return nil
+}
+
+func (f *File) directory(i int) (DataDirectory, error) {
+	if f.OptionalHeader == nil || i >= len(f.OptionalHeader.DataDirectory) {
+		return DataDirectory{}, ErrNotFound
+	}
+	d := f.OptionalHeader.DataDirectory[i]
+	if d.VirtualAddress == 0 {
+		return DataDirectory{}, ErrNotFound
+	}
+	return d, nil
+}
@@ -0,0 +1,258 @@
+package pe
+
+import (
+	stdpe "debug/pe"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+const (
+	sectionRVA = 0x1000
+	sectionRaw = 0x200
+	stringsOff = 0x600
+)
+
+// writePE writes an image with one ".rdata.long" section holding import,
+// export and resource directories.
+func writePE(t *testing.T, fw *os.File, plus bool) {
+	w := bio.NewWriter(fw)
+	at := func(rva uint32) { w.SetOffset(int64(rva) - sectionRVA + sectionRaw) }
+	cstr := func(rva uint32, s string) { at(rva); w.WriteRaw([]byte(s + "\x00")) }
+	word := func(v uint64) {
+		if plus {
+			w.WriteU64(v, le)
+		} else {
+			w.WriteU32(uint32(v), le)
+		}
+	}
+
+	// DOS header and stub.
+	w.WriteS16("MZ", bio.BigEndian)
+	w.WriteU16(0x90, le)
+	w.SetOffset(0x3C)
+	w.WriteU32(0x80, le)
+	w.WriteRaw([]byte("This program cannot be run in DOS mode."))
+
+	// PE signature and COFF header.
+	optSize := 96 + 16*8
+	if plus {
+		optSize = 112 + 16*8
+	}
+	w.SetOffset(0x80)
+	w.WriteS32("PE\x00\x00", bio.BigEndian)
+	w.WriteU16(0x8664, le)
+	w.WriteU16(1, le)
+	w.WriteU32(0x5F000000, le)
+	w.WriteU32(stringsOff, le)
+	w.WriteU32(0, le)
+	w.WriteU16(uint16(optSize), le)
+	w.WriteU16(0x2022, le)
+
+	// Optional header.
+	if plus {
+		w.WriteU16(MagicPE32Plus, le)
+	} else {
+		w.WriteU16(MagicPE32, le)
+	}
+	w.WriteU8(14)
+	w.WriteU8(1)
+	w.WriteU32s([]uint32{0x200, 0x400, 0, 0x1010, 0x1000}, le)
+	if !plus {
+		w.WriteU32(0x2000, le)
+	}
+	word(0x180000000)
+	w.WriteU32s([]uint32{0x1000, 0x200}, le)
+	w.WriteU16s([]uint16{6, 0, 1, 2, 6, 0}, le)
+	w.WriteU32s([]uint32{0, 0x2000, 0x200, 0xABCD}, le)
+	w.WriteU16(3, le)
+	w.WriteU16(0x8160, le)
+	word(0x100000)
+	word(0x1000)
+	word(0x100000)
+	word(0x1000)
+	w.WriteU32(0, le)
+	w.WriteU32(16, le)
+	dirs := make([]uint32, 32)
+	copy(dirs, []uint32{0x1100, 0xB0, 0x1000, 40, 0x1200, 0x100})
+	w.WriteU32s(dirs, le)
+
+	// Section table.
+	w.WriteS64("/4\x00\x00\x00\x00\x00\x00", bio.BigEndian)
+	w.WriteU32s([]uint32{0x400, sectionRVA, 0x400, sectionRaw, 0, 0}, le)
+	w.WriteU16s([]uint16{0, 0}, le)
+	w.WriteU32(0x40000040, le)
+
+	// Imports: KERNEL32.dll!ExitProcess and ordinal 5.
+	at(0x1000)
+	w.WriteU32s([]uint32{0x1040, 0, 0, 0x10C0, 0x1080}, le)
+	for _, rva := range []uint32{0x1040, 0x1080} {
+		at(rva)
+		word(0x10D0)
+		if plus {
+			word(1<<63 | 5)
+		} else {
+			word(1<<31 | 5)
+		}
+		word(0)
+	}
+	cstr(0x10C0, "KERNEL32.dll")
+	at(0x10D0)
+	w.WriteU16(7, le)
+	w.WriteRaw([]byte("ExitProcess\x00"))
+
+	// Exports: Alpha at 0x1010 and Beta forwarded to NTDLL.
+	at(0x1100)
+	w.WriteU32s([]uint32{0, 0, 0, 0x1180, 1, 2, 2, 0x1130, 0x1140, 0x1150}, le)
+	at(0x1130)
+	w.WriteU32s([]uint32{0x1010, 0x11A0}, le)
+	at(0x1140)
+	w.WriteU32s([]uint32{0x1190, 0x1198}, le)
+	at(0x1150)
+	w.WriteU16s([]uint16{0, 1}, le)
+	cstr(0x1180, "test.dll")
+	cstr(0x1190, "Alpha")
+	cstr(0x1198, "Beta")
+	cstr(0x11A0, "NTDLL.RtlFoo")
+
+	// Resources: root -> "MYTYPE" -> 1 -> "DATA", root -> 16 -> "V1".
+	at(0x1200)
+	w.WriteU32s([]uint32{0, 0}, le)
+	w.WriteU16s([]uint16{4, 0, 1, 1}, le)
+	w.WriteU32s([]uint32{1<<31 | 0x60, 1<<31 | 0x20, 16, 0x50}, le)
+	at(0x1220)
+	w.WriteU32s([]uint32{0, 0}, le)
+	w.WriteU16s([]uint16{0, 0, 0, 1}, le)
+	w.WriteU32s([]uint32{1, 0x40}, le)
+	at(0x1240)
+	w.WriteU32s([]uint32{0x1300, 4, 1252, 0}, le)
+	at(0x1250)
+	w.WriteU32s([]uint32{0x1304, 2, 0, 0}, le)
+	at(0x1260)
+	w.WriteU16(6, le)
+	w.WriteU16s(utf16.Encode([]rune("MYTYPE")), le)
+	at(0x1300)
+	w.WriteRaw([]byte("DATAV1"))
+
+	// COFF string table holding the long section name.
+	w.SetOffset(stringsOff)
+	w.WriteU32(4+12, le)
+	w.WriteRaw([]byte(".rdata.long\x00"))
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+}
+
+func TestFile(t *testing.T) {
+	testFileName := "test.exe"
+	defer os.Remove(testFileName)
+
+	for _, plus := range []bool{false, true} {
+		fw, err := os.Create(testFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePE(t, fw, plus)
+
+		f, err := Decode(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		std, err := stdpe.NewFile(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Lfanew != 0x80 || f.Machine != std.Machine || f.Characteristics != std.Characteristics {
+			t.Fatalf("Invalid headers %+v %+v", f.DOSHeader, f.FileHeader)
+		}
+		o := f.OptionalHeader
+		switch oh := std.OptionalHeader.(type) {
+		case *stdpe.OptionalHeader32:
+			if plus || o.BaseOfData != oh.BaseOfData || o.ImageBase != uint64(oh.ImageBase) || o.CheckSum != oh.CheckSum {
+				t.Fatalf("Invalid PE32 optional header %+v", o)
+			}
+		case *stdpe.OptionalHeader64:
+			if !plus || o.ImageBase != oh.ImageBase || o.SizeOfHeapReserve != oh.SizeOfHeapReserve || o.DllCharacteristics != oh.DllCharacteristics {
+				t.Fatalf("Invalid PE32+ optional header %+v", o)
+			}
+		}
+		if len(f.Sections) != 1 || f.Sections[0].Name != std.Sections[0].Name || f.Section(".rdata.long") == nil {
+			t.Fatalf("Invalid sections %+v", f.Sections[0])
+		}
+
+		imports, err := f.Imports()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imports) != 1 || imports[0].DLL != "KERNEL32.dll" ||
+			imports[0].Functions[0] != (ImportFunc{Name: "ExitProcess", Hint: 7}) ||
+			imports[0].Functions[1] != (ImportFunc{Ordinal: 5, ByOrdinal: true}) {
+			t.Fatalf("Invalid imports %+v", imports[0])
+		}
+		syms, err := std.ImportedSymbols()
+		if err != nil || len(syms) == 0 || syms[0] != "ExitProcess:KERNEL32.dll" {
+			t.Fatalf("Invalid debug/pe imports %v %v", syms, err)
+		}
+
+		dll, exports, err := f.Exports()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dll != "test.dll" || len(exports) != 2 ||
+			*exports[0] != (Export{Name: "Alpha", Ordinal: 1, RVA: 0x1010}) ||
+			*exports[1] != (Export{Name: "Beta", Ordinal: 2, RVA: 0x11A0, Forwarder: "NTDLL.RtlFoo"}) {
+			t.Fatalf("Invalid exports %s %+v %+v", dll, exports[0], exports[1])
+		}
+
+		root, err := f.Resources()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(root.Entries) != 2 || root.Entries[0].Name != "MYTYPE" || root.Entries[1].ID != 16 {
+			t.Fatalf("Invalid resource root %+v", root)
+		}
+		leaf := root.Entries[0].Dir.Entries[0]
+		if leaf.ID != 1 || leaf.Data == nil || leaf.Data.CodePage != 1252 {
+			t.Fatalf("Invalid resource leaf %+v", leaf)
+		}
+		for e, want := range map[*ResourceEntry]string{leaf: "DATA", root.Entries[1]: "V1"} {
+			r, err := e.Data.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := ioutil.ReadAll(r)
+			if string(b) != want {
+				t.Fatalf("Invalid resource data %q", b)
+			}
+		}
+
+		// lookups share the File but not a reader
+		errs := make(chan error)
+		for i := 0; i < 8; i++ {
+			go func() {
+				_, err := f.Imports()
+				if err == nil {
+					_, _, err = f.Exports()
+				}
+				if err == nil {
+					_, err = f.Resources()
+				}
+				errs <- err
+			}()
+		}
+		for i := 0; i < 8; i++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("Concurrent lookup failed %v", err)
+			}
+		}
+		fw.Close()
+	}
+
+	if _, err := Decode(strings.NewReader("\x7fELF")); err != ErrFormat {
+		t.Fatalf("Invalid format error %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// ErrLatin1 is returned when tEXt or zTXt content is not representable in Latin-1.
+var ErrLatin1 = errors.New("png: text is not Latin-1")
+
+// IHDR is the image header.
+type IHDR struct {
+	Width             uint32
+	Height            uint32
+	BitDepth          uint8
+	ColorType         uint8
+	CompressionMethod uint8
+	FilterMethod      uint8
+	InterlaceMethod   uint8
+}
+
+// ParseIHDR decodes an IHDR chunk.
+func ParseIHDR(c *Chunk) (*IHDR, error) {
+	if c.Type != "IHDR" || c.Length != 13 {
+		return nil, ErrFormat
+	}
+	br := bio.NewReader(c.src)
+	br.SetOffset(c.Offset)
+	h := &IHDR{}
+	h.Width = br.ReadU32(bio.BigEndian)
+	h.Height = br.ReadU32(bio.BigEndian)
+	h.BitDepth = br.ReadU8()
+	h.ColorType = br.ReadU8()
+	h.CompressionMethod = br.ReadU8()
+	h.FilterMethod = br.ReadU8()
+	h.InterlaceMethod = br.ReadU8()
+	return h, br.Err()
+}
+
+// Bytes encodes h as IHDR chunk data.
+func (h *IHDR) Bytes() []byte {
+	return []byte{
+		byte(h.Width >> 24), byte(h.Width >> 16), byte(h.Width >> 8), byte(h.Width),
+		byte(h.Height >> 24), byte(h.Height >> 16), byte(h.Height >> 8), byte(h.Height),
+		h.BitDepth, h.ColorType, h.CompressionMethod, h.FilterMethod, h.InterlaceMethod,
+	}
+}
+
+// Phys is the physical pixel dimensions chunk. Unit 1 means meters.
+type Phys struct {
+	PixelsPerUnitX uint32
+	PixelsPerUnitY uint32
+	Unit           uint8
+}
+
+// ParsePhys decodes a pHYs chunk.
+func ParsePhys(c *Chunk) (*Phys, error) {
+	if c.Type != "pHYs" || c.Length != 9 {
+		return nil, ErrFormat
+	}
+	br := bio.NewReader(c.src)
+	br.SetOffset(c.Offset)
+	p := &Phys{}
+	p.PixelsPerUnitX = br.ReadU32(bio.BigEndian)
+	p.PixelsPerUnitY = br.ReadU32(bio.BigEndian)
+	p.Unit = br.ReadU8()
+	return p, br.Err()
+}
+
+// Text is a tEXt, zTXt or iTXt entry. Text is always UTF-8.
+type Text struct {
+	Keyword           string
+	Text              string
+	Compressed        bool
+	International     bool   // iTXt
+	LanguageTag       string // iTXt only
+	TranslatedKeyword string // iTXt only
+}
+
+func latin1ToUTF8(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func utf8ToLatin1(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, ErrLatin1
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
+
+// maxText is the largest decompressed zTXt or iTXt text accepted by ParseText.
+const maxText = 16 << 20
+
+func inflate(b []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	text, err := ioutil.ReadAll(io.LimitReader(zr, maxText+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > maxText {
+		return nil, ErrTooLarge
+	}
+	return text, nil
+}
+
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// cut splits b at the first NUL.
+func cut(b []byte) ([]byte, []byte, bool) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil, nil, false
+	}
+	return b[:i], b[i+1:], true
+}
+
+// ParseText decodes a tEXt, zTXt or iTXt chunk.
+func ParseText(c *Chunk) (*Text, error) {
+	data, err := c.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	key, rest, ok := cut(data)
+	if !ok || len(key) == 0 || len(key) > 79 {
+		return nil, ErrFormat
+	}
+	t := &Text{Keyword: latin1ToUTF8(key)}
+
+	switch c.Type {
+	case "tEXt":
+		t.Text = latin1ToUTF8(rest)
+	case "zTXt":
+		if len(rest) < 1 || rest[0] != 0 {
+			return nil, ErrFormat
+		}
+		text, err := inflate(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		t.Compressed = true
+		t.Text = latin1ToUTF8(text)
+	case "iTXt":
+		if len(rest) < 2 {
+			return nil, ErrFormat
+		}
+		if rest[0] > 1 || rest[1] != 0 {
+			return nil, ErrFormat // compression flag and method
+		}
+		t.International = true
+		t.Compressed = rest[0] == 1
+		lang, rest, ok := cut(rest[2:])
+		if !ok {
+			return nil, ErrFormat
+		}
+		tkey, text, ok := cut(rest)
+		if !ok {
+			return nil, ErrFormat
+		}
+		if t.Compressed {
+			if text, err = inflate(text); err != nil {
+				return nil, err
+			}
+		}
+		if !utf8.Valid(text) || !utf8.Valid(tkey) {
+			return nil, ErrFormat
+		}
+		t.LanguageTag = string(lang)
+		t.TranslatedKeyword = string(tkey)
+		t.Text = string(text)
+	default:
+		return nil, ErrFormat
+	}
+	return t, nil
+}
+
+// Chunk encodes t as a tEXt, zTXt or iTXt chunk, depending on International and Compressed.
+func (t *Text) Chunk() (typ string, data []byte, err error) {
+	key, err := utf8ToLatin1(t.Keyword)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(key) == 0 || len(key) > 79 {
+		return "", nil, ErrFormat
+	}
+	data = append(key, 0)
+
+	if t.International {
+		text := []byte(t.Text)
+		flag := byte(0)
+		if t.Compressed {
+			text = deflate(text)
+			flag = 1
+		}
+		data = append(data, flag, 0)
+		data = append(append(data, t.LanguageTag...), 0)
+		data = append(append(data, t.TranslatedKeyword...), 0)
+		return "iTXt", append(data, text...), nil
+	}
+
+	text, err := utf8ToLatin1(t.Text)
+	if err != nil {
+		return "", nil, err
+	}
+	if t.Compressed {
+		data = append(data, 0)
+		return "zTXt", append(data, deflate(text)...), nil
+	}
+	return "tEXt", append(data, text...), nil
+}
+
+// WriteText writes t as a tEXt, zTXt or iTXt chunk.
+func (pw *Writer) WriteText(t *Text) error {
+	typ, data, err := t.Chunk()
+	if err != nil {
+		return err
+	}
+	return pw.WriteChunk(typ, data)
+}
+
+// WritePhys writes a pHYs chunk.
+func (pw *Writer) WritePhys(p *Phys) error {
+	return pw.WriteChunk("pHYs", []byte{
+		byte(p.PixelsPerUnitX >> 24), byte(p.PixelsPerUnitX >> 16), byte(p.PixelsPerUnitX >> 8), byte(p.PixelsPerUnitX),
+		byte(p.PixelsPerUnitY >> 24), byte(p.PixelsPerUnitY >> 16), byte(p.PixelsPerUnitY >> 8), byte(p.PixelsPerUnitY),
+		p.Unit,
+	})
+}
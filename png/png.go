@@ -0,0 +1,221 @@
+// Package png reads and writes PNG files at the chunk level, so metadata can be
+// inspected and edited without decoding pixel data.
+package png
+
+import (
+	"errors"
+	"io"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the png package.
+var (
+	ErrSignature = errors.New("png: invalid signature")
+	ErrCRC       = errors.New("png: chunk CRC mismatch")
+	ErrFormat    = errors.New("png: invalid chunk")
+	ErrTooLarge  = errors.New("png: data too large")
+)
+
+// Signature is the 8-byte PNG file signature.
+const Signature = "\x89PNG\r\n\x1a\n"
+
+// Chunk is a single chunk. Offset is the position of its data in the source.
+type Chunk struct {
+	Type   string
+	Length uint32
+	Offset int64
+	CRC    uint32
+	src    io.ReaderAt
+}
+
+// Critical reports whether the chunk is critical (IHDR, PLTE, IDAT, IEND).
+func (c *Chunk) Critical() bool {
+	return c.Type[0]&0x20 == 0
+}
+
+// Data returns a reader over the chunk data.
+func (c *Chunk) Data() *io.SectionReader {
+	return io.NewSectionReader(c.src, c.Offset, int64(c.Length))
+}
+
+// Bytes reads the whole chunk data.
+func (c *Chunk) Bytes() ([]byte, error) {
+	r := bio.NewReader(c.src)
+	r.SetOffset(c.Offset)
+	b := r.ReadRaw(uint64(c.Length))
+	return b, r.Err()
+}
+
+// Reader iterates over the chunks of a PNG file.
+type Reader struct {
+	src  io.ReaderAt
+	br   *bio.Reader
+	buf  []byte
+	done bool
+}
+
+// NewReader validates the signature of r.
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	br := bio.NewReader(r)
+	sig := br.ReadRaw(8)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if string(sig) != Signature {
+		return nil, ErrSignature
+	}
+	return &Reader{src: r, br: br}, nil
+}
+
+// unexpected maps io.EOF to io.ErrUnexpectedEOF, as the data ends before IEND.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Next returns the next chunk after verifying its CRC, or io.EOF after IEND.
+// Data ending before IEND reports io.ErrUnexpectedEOF.
+func (r *Reader) Next() (*Chunk, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	br := r.br
+	c := &Chunk{src: r.src}
+	c.Length = br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, unexpected(br.Err())
+	}
+	if c.Length > 1<<31-1 {
+		return nil, ErrFormat
+	}
+
+	br.StartHash(bio.NewCRC32IEEE())
+	c.Type = br.ReadS32(bio.BigEndian)
+	c.Offset = br.GetOffset()
+	if r.buf == nil {
+		r.buf = make([]byte, 64<<10)
+	}
+	for n := int(c.Length); n > 0 && br.Err() == nil; {
+		k := n
+		if k > len(r.buf) {
+			k = len(r.buf)
+		}
+		br.ReadU8s(r.buf[:k])
+		n -= k
+	}
+	crc := br.SumHash32()
+	c.CRC = br.ReadU32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, unexpected(br.Err())
+	}
+	if crc != c.CRC {
+		return nil, ErrCRC
+	}
+	if c.Type == "IEND" {
+		r.done = true
+	}
+	return c, nil
+}
+
+// Writer writes a PNG file chunk by chunk.
+type Writer struct {
+	w *bio.Writer
+}
+
+// NewWriter writes the PNG signature to w.
+func NewWriter(w io.WriterAt) (*Writer, error) {
+	bw := bio.NewWriter(w)
+	bw.WriteRaw([]byte(Signature))
+	return &Writer{w: bw}, bw.Err()
+}
+
+// WriteChunk writes a chunk, computing its CRC.
+func (pw *Writer) WriteChunk(typ string, data []byte) error {
+	if len(typ) != 4 {
+		return ErrFormat
+	}
+	if uint64(len(data)) > 1<<31-1 {
+		return ErrTooLarge
+	}
+	bw := pw.w
+	bw.WriteU32(uint32(len(data)), bio.BigEndian)
+	bw.StartHash(bio.NewCRC32IEEE())
+	bw.WriteS32(typ, bio.BigEndian)
+	bw.WriteRaw(data)
+	bw.WriteU32(bw.SumHash32(), bio.BigEndian)
+	return bw.Err()
+}
+
+// rawWriter adapts a bio.Writer to io.Writer.
+type rawWriter struct {
+	w *bio.Writer
+}
+
+func (rw rawWriter) Write(p []byte) (int, error) {
+	n := rw.w.WriteRaw(p)
+	return n, rw.w.Err()
+}
+
+// CopyChunk copies c, including its CRC, from its source. The data is streamed
+// rather than read into memory.
+func (pw *Writer) CopyChunk(c *Chunk) error {
+	bw := pw.w
+	bw.WriteU32(c.Length, bio.BigEndian)
+	bw.WriteS32(c.Type, bio.BigEndian)
+	if _, err := io.CopyN(rawWriter{bw}, c.Data(), int64(c.Length)); err != nil {
+		return err
+	}
+	bw.WriteU32(c.CRC, bio.BigEndian)
+	return bw.Err()
+}
+
+// EditFunc decides what happens to a chunk during Rewrite. It may write new
+// chunks through w, which are placed before c, and returns whether c is kept.
+// Critical chunks are kept regardless of the result.
+type EditFunc func(c *Chunk, w *Writer) (keep bool, err error)
+
+// Rewrite copies the PNG file in src to dst, letting fn drop ancillary chunks or
+// insert new ones. Critical chunks, including pixel data, are copied unchanged.
+func Rewrite(src io.ReaderAt, dst io.WriterAt, fn EditFunc) error {
+	r, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+	w, err := NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	for {
+		c, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keep, err := fn(c, w)
+		if err != nil {
+			return err
+		}
+		if keep || c.Critical() {
+			if err := w.CopyChunk(c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StripAncillary copies src to dst without ancillary chunks, except the listed types.
+func StripAncillary(src io.ReaderAt, dst io.WriterAt, keep ...string) error {
+	return Rewrite(src, dst, func(c *Chunk, w *Writer) (bool, error) {
+		for _, t := range keep {
+			if c.Type == t {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,211 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"io"
+	"os"
+	"testing"
+)
+
+func encodeImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := stdpng.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func chunkTypes(t *testing.T, src io.ReaderAt) []*Chunk {
+	r, err := NewReader(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []*Chunk
+	for {
+		c, err := r.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+func TestReader(t *testing.T) {
+	src := encodeImage(t)
+
+	chunks := chunkTypes(t, bytes.NewReader(src))
+	if chunks[0].Type != "IHDR" || chunks[len(chunks)-1].Type != "IEND" {
+		t.Fatalf("Invalid chunks %v", chunks)
+	}
+	h, err := ParseIHDR(chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Width != 4 || h.Height != 3 || h.BitDepth != 8 {
+		t.Fatalf("Invalid IHDR %+v", h)
+	}
+	if !bytes.Equal(h.Bytes(), src[16:29]) {
+		t.Fatalf("Invalid IHDR encoding")
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("GIF89a\x00\x00"))); err != ErrSignature {
+		t.Fatalf("Invalid signature error %v", err)
+	}
+
+	bad := append([]byte(nil), src...)
+	bad[20] ^= 0xFF
+	r, _ := NewReader(bytes.NewReader(bad))
+	if _, err := r.Next(); err != ErrCRC {
+		t.Fatalf("Invalid CRC error %v", err)
+	}
+
+	// missing IEND
+	r, _ = NewReader(bytes.NewReader(src[:len(src)-12]))
+	for err = nil; err == nil; _, err = r.Next() {
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid missing IEND error %v", err)
+	}
+	r, _ = NewReader(bytes.NewReader(src[:len(src)-2]))
+	for err = nil; err == nil; _, err = r.Next() {
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Invalid truncated IEND error %v", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	testFileName := "test.png"
+	strippedFileName := "stripped.png"
+	defer os.Remove(testFileName)
+	defer os.Remove(strippedFileName)
+
+	src := encodeImage(t)
+	texts := []*Text{
+		{Keyword: "Title", Text: "Café"},
+		{Keyword: "Comment", Text: "compressed", Compressed: true},
+		{Keyword: "Author", Text: "日本語", International: true, LanguageTag: "ja", TranslatedKeyword: "著者", Compressed: true},
+	}
+
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	err = Rewrite(bytes.NewReader(src), fw, func(c *Chunk, w *Writer) (bool, error) {
+		if c.Type != "IDAT" {
+			return true, nil
+		}
+		for _, text := range texts {
+			if err := w.WriteText(text); err != nil {
+				return false, err
+			}
+		}
+		return true, w.WritePhys(&Phys{2835, 2835, 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*Text
+	for _, c := range chunkTypes(t, fw) {
+		switch c.Type {
+		case "tEXt", "zTXt", "iTXt":
+			text, err := ParseText(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, text)
+		case "pHYs":
+			p, err := ParsePhys(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *p != (Phys{2835, 2835, 1}) {
+				t.Fatalf("Invalid pHYs %+v", p)
+			}
+		}
+	}
+	if len(got) != len(texts) {
+		t.Fatalf("Invalid text count %d", len(got))
+	}
+	for i := range texts {
+		if *got[i] != *texts[i] {
+			t.Fatalf("Invalid text %+v", got[i])
+		}
+	}
+	if _, err := stdpng.Decode(io.NewSectionReader(fw, 0, 1<<20)); err != nil {
+		t.Fatalf("Rewritten file must decode: %v", err)
+	}
+
+	fs, err := os.Create(strippedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := StripAncillary(fw, fs, "pHYs"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunkTypes(t, fs) {
+		if !c.Critical() && c.Type != "pHYs" {
+			t.Fatalf("Ancillary chunk %s not stripped", c.Type)
+		}
+	}
+
+	if _, _, err := (&Text{Keyword: "k", Text: "日本"}).Chunk(); err != ErrLatin1 {
+		t.Fatalf("Invalid Latin-1 error %v", err)
+	}
+}
+
+func TestTextLimits(t *testing.T) {
+	testFileName := "test.png"
+	defer os.Remove(testFileName)
+
+	bomb := make([]byte, maxText+1)
+	_, ztxt, err := (&Text{Keyword: "Comment", Text: string(bomb), Compressed: true}).Chunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := []struct {
+		typ  string
+		data []byte
+		want error
+	}{
+		{"zTXt", ztxt, ErrTooLarge},
+		{"iTXt", []byte("Title\x00\x02\x00\x00\x00text"), ErrFormat}, // compression flag 2
+		{"iTXt", []byte("Title\x00\x01\x01\x00\x00text"), ErrFormat}, // compression method 1
+	}
+	for _, tt := range chunks {
+		fw, err := os.Create(testFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWriter(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteChunk(tt.typ, tt.data); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseText(c); err != tt.want {
+			t.Fatalf("Invalid %s error %v", tt.typ, err)
+		}
+		fw.Close()
+	}
+}
@@ -0,0 +1,273 @@
+// Package protowire reads and writes the Protocol Buffers wire format without
+// generated code: field tags, the six wire types, unknown field skipping and
+// length-delimited messages with back-patched lengths.
+package protowire
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the protowire package.
+var (
+	ErrFormat      = errors.New("protowire: invalid wire format")
+	ErrFieldNumber = errors.New("protowire: invalid field number")
+	ErrGroup       = errors.New("protowire: mismatched group")
+	ErrDepth       = errors.New("protowire: nesting too deep")
+)
+
+// Number is a field number.
+type Number int32
+
+// Valid field numbers.
+const (
+	MinNumber Number = 1
+	MaxNumber Number = 1<<29 - 1
+)
+
+// Type is a wire type.
+type Type int8
+
+// Wire types.
+const (
+	VarintType     Type = 0
+	Fixed64Type    Type = 1
+	BytesType      Type = 2
+	StartGroupType Type = 3
+	EndGroupType   Type = 4
+	Fixed32Type    Type = 5
+)
+
+// maxDepth limits group nesting while skipping.
+const maxDepth = 10000
+
+// EncodeZigZag maps signed to unsigned integers for sint32 and sint64 fields.
+func EncodeZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// DecodeZigZag reverses EncodeZigZag.
+func DecodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// Field is a decoded field. Value holds varint, fixed32 and fixed64 values.
+// DataOffset and Length locate the payload of length-delimited fields and the
+// content of groups. Offset and End span the whole field, tag included.
+type Field struct {
+	Number     Number
+	Type       Type
+	Value      uint64
+	Offset     int64
+	DataOffset int64
+	Length     int64
+	End        int64
+	src        io.ReaderAt
+}
+
+// Int32 returns an int32 or enum value.
+func (f *Field) Int32() int32 { return int32(f.Value) }
+
+// Int64 returns an int64 value.
+func (f *Field) Int64() int64 { return int64(f.Value) }
+
+// Sint64 returns a zigzag-encoded sint32 or sint64 value.
+func (f *Field) Sint64() int64 { return DecodeZigZag(f.Value) }
+
+// Bool returns a bool value.
+func (f *Field) Bool() bool { return f.Value != 0 }
+
+// Float32 returns a float value.
+func (f *Field) Float32() float32 { return math.Float32frombits(uint32(f.Value)) }
+
+// Float64 returns a double value.
+func (f *Field) Float64() float64 { return math.Float64frombits(f.Value) }
+
+// Data returns a reader over the payload of a length-delimited field or the
+// content of a group.
+func (f *Field) Data() *io.SectionReader {
+	return io.NewSectionReader(f.src, f.DataOffset, f.Length)
+}
+
+// Bytes reads the payload of a length-delimited field.
+func (f *Field) Bytes() ([]byte, error) {
+	br := bio.NewReader(f.src)
+	br.SetOffset(f.DataOffset)
+	if f.Length == 0 {
+		return []byte{}, nil
+	}
+	b := br.ReadRaw(uint64(f.Length))
+	return b, br.Err()
+}
+
+// Message returns a Reader over the payload of an embedded message or the
+// content of a group.
+func (f *Field) Message() *Reader {
+	return newReader(f.src, f.DataOffset, f.DataOffset+f.Length)
+}
+
+// Reader iterates over the fields of a message.
+type Reader struct {
+	src io.ReaderAt
+	br  *bio.Reader
+	end int64
+}
+
+// NewReader returns a Reader over a message of the given size.
+func NewReader(r io.ReaderAt, size int64) *Reader {
+	return newReader(r, 0, size)
+}
+
+func newReader(r io.ReaderAt, start, end int64) *Reader {
+	br := bio.NewReader(r, bio.WithBufferSize(4096))
+	br.SetOffset(start)
+	return &Reader{src: r, br: br, end: end}
+}
+
+// readUvarint reads a varint that must end within the message.
+func (r *Reader) readUvarint() (uint64, error) {
+	v := r.br.ReadUvarint()
+	if err := r.br.Err(); err != nil {
+		if err == bio.ErrOverflow {
+			return 0, ErrFormat
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	if r.br.GetOffset() > r.end {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return v, nil
+}
+
+// readTag reads a field tag and validates its number and type.
+func (r *Reader) readTag() (Number, Type, error) {
+	v, err := r.readUvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num, typ := Number(v>>3), Type(v&7)
+	if v>>3 > uint64(MaxNumber) || num < MinNumber {
+		return 0, 0, ErrFieldNumber
+	}
+	if typ > Fixed32Type {
+		return 0, 0, ErrFormat
+	}
+	return num, typ, nil
+}
+
+// need checks that n more bytes remain in the message.
+func (r *Reader) need(n int64) error {
+	if n < 0 || n > r.end-r.br.GetOffset() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// skip advances n bytes within the message.
+func (r *Reader) skip(n int64) error {
+	if err := r.need(n); err != nil {
+		return err
+	}
+	r.br.SetOffset(r.br.GetOffset() + n)
+	return nil
+}
+
+// Next returns the next field, or io.EOF at the end of the message. Values of
+// all wire types are consumed, so unknown fields are skipped by ignoring them.
+// Groups are skipped up to their matching end-group tag.
+func (r *Reader) Next() (*Field, error) {
+	off := r.br.GetOffset()
+	if off >= r.end {
+		return nil, io.EOF
+	}
+	num, typ, err := r.readTag()
+	if err != nil {
+		return nil, err
+	}
+	f := &Field{Number: num, Type: typ, Offset: off, src: r.src}
+	switch typ {
+	case VarintType:
+		f.Value, err = r.readUvarint()
+	case Fixed32Type:
+		if err = r.need(4); err == nil {
+			f.Value = uint64(r.br.ReadU32(bio.LittleEndian))
+		}
+	case Fixed64Type:
+		if err = r.need(8); err == nil {
+			f.Value = r.br.ReadU64(bio.LittleEndian)
+		}
+	case BytesType:
+		var n uint64
+		if n, err = r.readUvarint(); err == nil {
+			f.DataOffset = r.br.GetOffset()
+			if n > uint64(r.end-f.DataOffset) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			f.Length = int64(n)
+			r.br.SetOffset(f.DataOffset + f.Length)
+		}
+	case StartGroupType:
+		f.DataOffset = r.br.GetOffset()
+		var content int64
+		if content, err = r.skipGroup(num, 0); err == nil {
+			f.Length = content - f.DataOffset
+		}
+	case EndGroupType:
+		return nil, ErrGroup
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.br.Err(); err != nil {
+		return nil, err
+	}
+	f.End = r.br.GetOffset()
+	return f, nil
+}
+
+// skipGroup consumes fields up to the end-group tag for num and returns the
+// offset of that tag.
+func (r *Reader) skipGroup(num Number, depth int) (int64, error) {
+	if depth >= maxDepth {
+		return 0, ErrDepth
+	}
+	for {
+		off := r.br.GetOffset()
+		if off >= r.end {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n, typ, err := r.readTag()
+		if err != nil {
+			return 0, err
+		}
+		switch typ {
+		case VarintType:
+			_, err = r.readUvarint()
+		case Fixed32Type:
+			err = r.skip(4)
+		case Fixed64Type:
+			err = r.skip(8)
+		case BytesType:
+			var l uint64
+			if l, err = r.readUvarint(); err == nil {
+				if l > uint64(r.end-r.br.GetOffset()) {
+					return 0, io.ErrUnexpectedEOF
+				}
+				r.br.SetOffset(r.br.GetOffset() + int64(l))
+			}
+		case StartGroupType:
+			_, err = r.skipGroup(n, depth+1)
+		case EndGroupType:
+			if n != num {
+				return 0, ErrGroup
+			}
+			return off, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package protowire
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func writeMessage(t *testing.T, name string, fn func(w *Writer) error) []byte {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	w := NewWriter(fw)
+	if err := fn(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readFields(t *testing.T, r *Reader) []*Field {
+	var fields []*Field
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return fields
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, f)
+	}
+}
+
+func TestWire(t *testing.T) {
+	testFileName := "test.pb"
+	defer os.Remove(testFileName)
+
+	b := writeMessage(t, testFileName, func(w *Writer) error {
+		w.WriteVarint(1, 150)
+		w.WriteString(2, "testing")
+		w.WriteSint(3, -2)
+		w.WriteFixed32(4, 0xDEADBEEF)
+		w.WriteDouble(5, math.Pi)
+		w.BeginMessage(6)
+		w.WriteVarint(1, 1)
+		w.BeginMessage(2)
+		w.WriteString(1, "inner")
+		w.EndMessage()
+		w.EndMessage()
+		w.BeginGroup(7)
+		w.WriteVarint(1, 5)
+		w.BeginGroup(2)
+		w.EndGroup()
+		w.EndGroup()
+		w.WriteVarint(8, uint64(math.MaxUint64)) // int64 -1
+		return w.WriteFloat(MaxNumber, 1.5)
+	})
+	if !bytes.Equal(b[:12], []byte{0x08, 0x96, 0x01, 0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}) {
+		t.Fatalf("Invalid encoding % x", b[:12])
+	}
+
+	fields := readFields(t, NewReader(bytes.NewReader(b), int64(len(b))))
+	if len(fields) != 9 {
+		t.Fatalf("Invalid field count %d", len(fields))
+	}
+	s, _ := fields[1].Bytes()
+	if fields[0].Value != 150 || string(s) != "testing" || fields[2].Sint64() != -2 ||
+		fields[3].Value != 0xDEADBEEF || fields[4].Float64() != math.Pi ||
+		fields[7].Int64() != -1 || fields[8].Number != MaxNumber || fields[8].Float32() != 1.5 {
+		t.Fatalf("Invalid values")
+	}
+
+	msg := fields[5]
+	if msg.Type != BytesType || msg.Length != int64(msg.End-msg.DataOffset) {
+		t.Fatalf("Invalid message field %+v", msg)
+	}
+	inner := readFields(t, msg.Message())
+	if len(inner) != 2 || inner[0].Value != 1 {
+		t.Fatalf("Invalid embedded message")
+	}
+	innermost := readFields(t, inner[1].Message())
+	if s, _ := innermost[0].Bytes(); string(s) != "inner" {
+		t.Fatalf("Invalid nested message %q", s)
+	}
+
+	group := fields[6]
+	if group.Type != StartGroupType {
+		t.Fatalf("Invalid group %+v", group)
+	}
+	gf := readFields(t, group.Message())
+	if len(gf) != 2 || gf[0].Value != 5 || gf[1].Type != StartGroupType || gf[1].Length != 0 {
+		t.Fatalf("Invalid group fields")
+	}
+
+	// Rewrite without field 2, copying everything else unchanged.
+	c := writeMessage(t, testFileName, func(w *Writer) error {
+		for _, f := range fields {
+			if f.Number == 2 {
+				continue
+			}
+			if err := w.CopyField(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	copied := readFields(t, NewReader(bytes.NewReader(c), int64(len(c))))
+	if len(copied) != 8 || copied[1].Number != 3 || len(c) != len(b)-9 {
+		t.Fatalf("Invalid rewrite")
+	}
+}
+
+func TestWireErrors(t *testing.T) {
+	tests := []struct {
+		b   []byte
+		err error
+	}{
+		{[]byte{0x08}, io.ErrUnexpectedEOF},
+		{[]byte{0x12, 0x05, 'a'}, io.ErrUnexpectedEOF},
+		{[]byte{0x0D, 1, 2}, io.ErrUnexpectedEOF},
+		{[]byte{0x00, 0x00}, ErrFieldNumber},
+		{[]byte{0x0E}, ErrFormat},
+		{[]byte{0x0C}, ErrGroup},
+		{[]byte{0x0B, 0x14}, ErrGroup},
+		{[]byte{0x0B, 0x08, 0x01}, io.ErrUnexpectedEOF},
+		{append([]byte{0x08}, bytes.Repeat([]byte{0xFF}, 10)...), ErrFormat},
+	}
+	for _, tt := range tests {
+		_, err := NewReader(bytes.NewReader(tt.b), int64(len(tt.b))).Next()
+		if err != tt.err {
+			t.Fatalf("Invalid error for % x: %v", tt.b, err)
+		}
+	}
+
+	w := NewWriter(nil)
+	if err := w.EndMessage(); err != ErrOpen {
+		t.Fatalf("Invalid unbalanced error %v", err)
+	}
+	if err := w.WriteVarint(0, 1); err != ErrFieldNumber {
+		t.Fatalf("Invalid field number error %v", err)
+	}
+}
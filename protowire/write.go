@@ -0,0 +1,181 @@
+package protowire
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// ErrOpen is returned when messages or groups are left open, or closed out of order.
+var ErrOpen = errors.New("protowire: unbalanced message or group")
+
+// lenSize is the width of the length reserved by BeginMessage. Lengths are
+// written as padded varints, which decoders accept, so up to 2^35-1 bytes fit.
+const lenSize = 5
+
+type frame struct {
+	num   Number
+	group bool
+	pos   int64 // position of the reserved length
+}
+
+// Writer writes message fields.
+type Writer struct {
+	w     *bio.Writer
+	stack []frame
+}
+
+// NewWriter returns a Writer starting at offset 0 of w.
+func NewWriter(w io.WriterAt) *Writer {
+	return &Writer{w: bio.NewWriter(w, bio.WithBufferSize(64<<10))}
+}
+
+// WriteTag writes a field tag.
+func (pw *Writer) WriteTag(num Number, typ Type) error {
+	if num < MinNumber || num > MaxNumber {
+		return ErrFieldNumber
+	}
+	pw.w.WriteUvarint(uint64(num)<<3 | uint64(typ))
+	return pw.w.Err()
+}
+
+// WriteVarint writes a varint field (int32, int64, uint32, uint64, bool, enum).
+// Negative int32 and int64 values are passed as uint64(v).
+func (pw *Writer) WriteVarint(num Number, v uint64) error {
+	if err := pw.WriteTag(num, VarintType); err != nil {
+		return err
+	}
+	pw.w.WriteUvarint(v)
+	return pw.w.Err()
+}
+
+// WriteSint writes a zigzag-encoded sint32 or sint64 field.
+func (pw *Writer) WriteSint(num Number, v int64) error {
+	return pw.WriteVarint(num, EncodeZigZag(v))
+}
+
+// WriteFixed32 writes a fixed32, sfixed32 or float field.
+func (pw *Writer) WriteFixed32(num Number, v uint32) error {
+	if err := pw.WriteTag(num, Fixed32Type); err != nil {
+		return err
+	}
+	pw.w.WriteU32(v, bio.LittleEndian)
+	return pw.w.Err()
+}
+
+// WriteFixed64 writes a fixed64, sfixed64 or double field.
+func (pw *Writer) WriteFixed64(num Number, v uint64) error {
+	if err := pw.WriteTag(num, Fixed64Type); err != nil {
+		return err
+	}
+	pw.w.WriteU64(v, bio.LittleEndian)
+	return pw.w.Err()
+}
+
+// WriteFloat writes a float field.
+func (pw *Writer) WriteFloat(num Number, v float32) error {
+	return pw.WriteFixed32(num, math.Float32bits(v))
+}
+
+// WriteDouble writes a double field.
+func (pw *Writer) WriteDouble(num Number, v float64) error {
+	return pw.WriteFixed64(num, math.Float64bits(v))
+}
+
+// WriteBytes writes a length-delimited field.
+func (pw *Writer) WriteBytes(num Number, b []byte) error {
+	if err := pw.WriteTag(num, BytesType); err != nil {
+		return err
+	}
+	pw.w.WriteUvarint(uint64(len(b)))
+	pw.w.WriteRaw(b)
+	return pw.w.Err()
+}
+
+// WriteString writes a string field.
+func (pw *Writer) WriteString(num Number, s string) error {
+	return pw.WriteBytes(num, []byte(s))
+}
+
+// BeginMessage starts an embedded message field. Its length is reserved and
+// back-patched by EndMessage.
+func (pw *Writer) BeginMessage(num Number) error {
+	if err := pw.WriteTag(num, BytesType); err != nil {
+		return err
+	}
+	pw.stack = append(pw.stack, frame{num: num, pos: pw.w.GetOffset()})
+	pw.w.WriteRaw(make([]byte, lenSize))
+	return pw.w.Err()
+}
+
+// EndMessage ends the innermost message started by BeginMessage.
+func (pw *Writer) EndMessage() error {
+	if len(pw.stack) == 0 || pw.stack[len(pw.stack)-1].group {
+		return ErrOpen
+	}
+	f := pw.stack[len(pw.stack)-1]
+	pw.stack = pw.stack[:len(pw.stack)-1]
+
+	end := pw.w.GetOffset()
+	n := uint64(end - f.pos - lenSize)
+	if n >= 1<<(7*lenSize) {
+		return bio.ErrOverflow
+	}
+	var b [lenSize]byte
+	for i := range b {
+		b[i] = byte(n>>(7*uint(i))) & 0x7F
+		if i < lenSize-1 {
+			b[i] |= 0x80
+		}
+	}
+	pw.w.SetOffset(f.pos)
+	pw.w.WriteRaw(b[:])
+	pw.w.SetOffset(end)
+	return pw.w.Err()
+}
+
+// BeginGroup writes a start-group tag.
+func (pw *Writer) BeginGroup(num Number) error {
+	if err := pw.WriteTag(num, StartGroupType); err != nil {
+		return err
+	}
+	pw.stack = append(pw.stack, frame{num: num, group: true})
+	return nil
+}
+
+// EndGroup writes the end-group tag of the innermost group.
+func (pw *Writer) EndGroup() error {
+	if len(pw.stack) == 0 || !pw.stack[len(pw.stack)-1].group {
+		return ErrOpen
+	}
+	f := pw.stack[len(pw.stack)-1]
+	pw.stack = pw.stack[:len(pw.stack)-1]
+	return pw.WriteTag(f.num, EndGroupType)
+}
+
+// CopyField copies f unchanged, tag included, from its source.
+func (pw *Writer) CopyField(f *Field) error {
+	br := bio.NewReader(f.src)
+	br.SetOffset(f.Offset)
+	b := br.ReadRaw(uint64(f.End - f.Offset))
+	if br.Err() != nil {
+		return br.Err()
+	}
+	pw.w.WriteRaw(b)
+	return pw.w.Err()
+}
+
+// Flush writes buffered data. Open messages and groups are reported as ErrOpen.
+func (pw *Writer) Flush() error {
+	if len(pw.stack) > 0 {
+		return ErrOpen
+	}
+	return pw.w.Flush()
+}
+
+// Size returns the number of bytes written so far.
+func (pw *Writer) Size() int64 {
+	return pw.w.GetOffset()
+}
@@ -1,7 +1,10 @@
 package binaryio
 
 import (
+	"hash"
 	"io"
+	"math"
+	"unsafe"
 )
 
 // Reader ...
@@ -11,27 +14,137 @@ type Reader struct {
 	err    error
 	sbuf64 []byte
 	b64    []byte
+	hash   hash.Hash
+	src    []byte
+	opts   options
+	buf    []byte
+	bufOff int64
+	bufErr error
+}
+
+// bytesSource is implemented by sources whose whole contents are addressable, such as MmapFile.
+type bytesSource interface {
+	Bytes() []byte
 }
 
 // NewReader ...
-func NewReader(r io.ReaderAt) (br *Reader) {
+//
+// With WithBufferSize, a window of the source is cached and small reads are served
+// from memory. The source must not be modified while the Reader is in use.
+func NewReader(r io.ReaderAt, opts ...Option) (br *Reader) {
 	br = &Reader{
-		r,
-		0,
-		nil,
-		make([]byte, 8),
-		make([]byte, 8),
+		r,                  // io.ReaderAt
+		0,                  // offset
+		nil,                // err
+		make([]byte, 8),    // sbuf64
+		make([]byte, 8),    // b64
+		nil,                // hash
+		nil,                // src
+		applyOptions(opts), // opts
+		nil,                // buf
+		0,                  // bufOff
+		nil,                // bufErr
+	}
+	if bs, ok := r.(bytesSource); ok {
+		br.src = bs.Bytes()
+	} else if br.opts.bufSize > 0 {
+		br.buf = make([]byte, 0, br.opts.bufSize)
 	}
 	return br
 }
 
+func (br *Reader) fill() {
+	n, err := br.ReadAt(br.buf[:cap(br.buf)], br.offset)
+	br.buf = br.buf[:n]
+	br.bufOff = br.offset
+	br.bufErr = err
+}
+
+func (br *Reader) readAt(p []byte) error {
+	switch {
+	case br.src != nil:
+		if br.offset < 0 || br.offset >= int64(len(br.src)) {
+			return io.EOF
+		}
+		if copy(p, br.src[br.offset:]) < len(p) {
+			return io.EOF
+		}
+		return nil
+	case br.buf != nil && len(p) <= cap(br.buf):
+		start := br.offset - br.bufOff
+		if start < 0 || start+int64(len(p)) > int64(len(br.buf)) {
+			br.fill()
+			start = 0
+		}
+		if copy(p, br.buf[start:]) < len(p) {
+			if br.bufErr == nil {
+				return io.EOF
+			}
+			return br.bufErr
+		}
+		return nil
+	}
+	n, err := br.ReadAt(p, br.offset)
+	if n == len(p) {
+		// io.ReaderAt may report io.EOF with a full read at the end
+		return nil
+	}
+	return err
+}
+
+func (br *Reader) readInto(p []byte) {
+	br.err = br.readAt(p)
+	br.offset += int64(len(p))
+	if br.hash != nil && br.err == nil {
+		br.hash.Write(p)
+	}
+}
+
 func (br *Reader) readBytes(n uint64) []byte {
 	data := br.b64[:n]
-	_, br.err = br.ReadAt(data, br.offset)
-	br.offset += int64(n)
+	br.readInto(data)
 	return data
 }
 
+func (br *Reader) newCursor() *Reader {
+	return NewReader(br.ReaderAt, func(o *options) { *o = br.opts })
+}
+
+func (br *Reader) readSlice(p unsafe.Pointer, n, size int, e Endian) {
+	for n > 0 && br.err == nil {
+		c := n
+		if c > maxView {
+			c = maxView
+		}
+		b := byteView(p, c)
+		br.readInto(b)
+		if size > 1 && e != hostEndian {
+			swapBytes(b, size)
+		}
+		n -= c
+		if n > 0 {
+			// only step while inside the slice; a past-the-end pointer is invalid
+			p = unsafe.Pointer(uintptr(p) + uintptr(c))
+		}
+	}
+}
+
+// Clone returns an independent Reader over the same source at the current
+// offset. The error state is copied too; use At for a fresh Reader.
+func (br *Reader) Clone() *Reader {
+	c := br.newCursor()
+	c.offset = br.offset
+	c.err = br.err
+	return c
+}
+
+// At returns an independent Reader over the same source starting at offset.
+func (br *Reader) At(offset int64) *Reader {
+	c := br.newCursor()
+	c.offset = offset
+	return c
+}
+
 func (br *Reader) setErr(err error) {
 	br.err = err
 }
@@ -41,12 +154,64 @@ func (br *Reader) Err() error {
 	return br.err
 }
 
-// ReadRaw ...
+// GetOffset ...
+func (br *Reader) GetOffset() int64 {
+	return br.offset
+}
+
+// SetOffset ...
+func (br *Reader) SetOffset(offset int64) {
+	br.offset = offset
+}
+
+// StartHash feeds every byte read from now on into h until SumHash is called.
+func (br *Reader) StartHash(h hash.Hash) {
+	br.hash = h
+}
+
+// SumHash stops hashing and returns the checksum of the bytes read since StartHash.
+func (br *Reader) SumHash() []byte {
+	if br.hash == nil {
+		return nil
+	}
+	h := br.hash
+	br.hash = nil
+	return h.Sum(nil)
+}
+
+// SumHash32 is like SumHash for hashes implementing hash.Hash32.
+func (br *Reader) SumHash32() uint32 {
+	h, ok := br.hash.(hash.Hash32)
+	br.hash = nil
+	if !ok {
+		return 0
+	}
+	return h.Sum32()
+}
+
+// ReadRaw returns a slice holding the next n bytes. The slice is newly allocated,
+// or points directly into the source if it exposes its contents via Bytes (e.g. MmapFile).
+// Slices into an MmapFile must not be used after its Close: the memory is unmapped
+// and accessing it faults. Copy them if they need to outlive the mapping.
 func (br *Reader) ReadRaw(n uint64) []byte {
 	if br.err != nil {
 		return nil
 	}
-	return br.readBytes(n)
+	if br.src != nil {
+		if br.offset < 0 || uint64(br.offset)+n > uint64(len(br.src)) {
+			br.setErr(io.EOF)
+			return nil
+		}
+		data := br.src[br.offset : uint64(br.offset)+n]
+		br.offset += int64(n)
+		if br.hash != nil {
+			br.hash.Write(data)
+		}
+		return data
+	}
+	data := make([]byte, n)
+	br.readInto(data)
+	return data
 }
 
 // ReadI8 ...
@@ -201,6 +366,87 @@ func (br *Reader) ReadU64(e Endian) uint64 {
 	return b
 }
 
+// ReadUN reads an unsigned integer of nbytes (1..8) bytes.
+func (br *Reader) ReadUN(nbytes int, e Endian) uint64 {
+	if br.err != nil {
+		return 0
+	}
+	if nbytes < 1 || nbytes > 8 {
+		br.setErr(ErrInvalidWidth)
+		return 0
+	}
+	data := br.readBytes(uint64(nbytes))
+
+	var b uint64
+	if e == LittleEndian {
+		for i := nbytes - 1; i >= 0; i-- {
+			b = b<<8 | uint64(data[i])
+		}
+	} else {
+		for i := 0; i < nbytes; i++ {
+			b = b<<8 | uint64(data[i])
+		}
+	}
+
+	return b
+}
+
+// ReadIN reads a two's complement integer of nbytes (1..8) bytes and sign-extends it.
+func (br *Reader) ReadIN(nbytes int, e Endian) int64 {
+	if br.err != nil {
+		return 0
+	}
+	v := br.ReadUN(nbytes, e)
+	shift := uint(64 - 8*nbytes)
+	return int64(v<<shift) >> shift
+}
+
+// ReadI40 ...
+func (br *Reader) ReadI40(e Endian) int64 {
+	return br.ReadIN(5, e)
+}
+
+// ReadI48 ...
+func (br *Reader) ReadI48(e Endian) int64 {
+	return br.ReadIN(6, e)
+}
+
+// ReadI56 ...
+func (br *Reader) ReadI56(e Endian) int64 {
+	return br.ReadIN(7, e)
+}
+
+// ReadU40 ...
+func (br *Reader) ReadU40(e Endian) uint64 {
+	return br.ReadUN(5, e)
+}
+
+// ReadU48 ...
+func (br *Reader) ReadU48(e Endian) uint64 {
+	return br.ReadUN(6, e)
+}
+
+// ReadU56 ...
+func (br *Reader) ReadU56(e Endian) uint64 {
+	return br.ReadUN(7, e)
+}
+
+// ReadF32 ...
+func (br *Reader) ReadF32(e Endian) float32 {
+	if br.err != nil {
+		return 0
+	}
+	return math.Float32frombits(br.ReadU32(e))
+}
+
+// ReadF64 ...
+func (br *Reader) ReadF64(e Endian) float64 {
+	if br.err != nil {
+		return 0
+	}
+	return math.Float64frombits(br.ReadU64(e))
+}
+
 // ReadS8 ...
 func (br *Reader) ReadS8() string {
 	if br.err != nil {
@@ -269,3 +515,83 @@ func (br *Reader) ReadS64(e Endian) string {
 
 	return string(br.sbuf64[:8])
 }
+
+// ReadI8s fills dst with consecutive values using a single read.
+func (br *Reader) ReadI8s(dst []int8) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*1, 1, LittleEndian)
+}
+
+// ReadI16s fills dst with consecutive values using a single read.
+func (br *Reader) ReadI16s(dst []int16, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*2, 2, e)
+}
+
+// ReadI32s fills dst with consecutive values using a single read.
+func (br *Reader) ReadI32s(dst []int32, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*4, 4, e)
+}
+
+// ReadI64s fills dst with consecutive values using a single read.
+func (br *Reader) ReadI64s(dst []int64, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*8, 8, e)
+}
+
+// ReadU8s fills dst with consecutive values using a single read.
+func (br *Reader) ReadU8s(dst []uint8) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*1, 1, LittleEndian)
+}
+
+// ReadU16s fills dst with consecutive values using a single read.
+func (br *Reader) ReadU16s(dst []uint16, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*2, 2, e)
+}
+
+// ReadU32s fills dst with consecutive values using a single read.
+func (br *Reader) ReadU32s(dst []uint32, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*4, 4, e)
+}
+
+// ReadU64s fills dst with consecutive values using a single read.
+func (br *Reader) ReadU64s(dst []uint64, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*8, 8, e)
+}
+
+// ReadF32s fills dst with consecutive values using a single read.
+func (br *Reader) ReadF32s(dst []float32, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*4, 4, e)
+}
+
+// ReadF64s fills dst with consecutive values using a single read.
+func (br *Reader) ReadF64s(dst []float64, e Endian) {
+	if br.err != nil || len(dst) == 0 {
+		return
+	}
+	br.readSlice(unsafe.Pointer(&dst[0]), len(dst)*8, 8, e)
+}
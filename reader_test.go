@@ -2,7 +2,10 @@ package binaryio
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"math"
+	"os"
 	"testing"
 )
 
@@ -308,3 +311,187 @@ func TestReader(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderConcurrent(t *testing.T) {
+	const chunks = 64
+	const chunkSize = 256
+
+	src := make([]byte, chunks*chunkSize)
+	for i := range src {
+		src[i] = byte(i / chunkSize)
+	}
+	r := NewReader(bytes.NewReader(src))
+
+	errs := make(chan error, chunks)
+	for i := 0; i < chunks; i++ {
+		go func(i int) {
+			cr := r.At(int64(i * chunkSize))
+			raw := cr.ReadRaw(chunkSize / 2)
+			for j := 0; j < chunkSize/8; j++ {
+				v := cr.ReadU32(BigEndian)
+				if cr.Err() != nil {
+					errs <- cr.Err()
+					return
+				}
+				if v != uint32(i)*0x01010101 {
+					errs <- fmt.Errorf("chunk %d: invalid value %08x", i, v)
+					return
+				}
+			}
+			for _, b := range raw {
+				if b != byte(i) {
+					errs <- fmt.Errorf("chunk %d: invalid raw byte %02x", i, b)
+					return
+				}
+			}
+			errs <- nil
+		}(i)
+	}
+	for i := 0; i < chunks; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Clone keeps the cursor position and the error, but not the buffer.
+	{
+		r := NewReader(bytes.NewReader([]byte{1, 2, 3, 4}))
+		r.ReadU8()
+		c := r.Clone()
+		if c.GetOffset() != 1 {
+			t.Fatalf("Invalid Clone offset %d", c.GetOffset())
+		}
+		a := r.ReadRaw(2)
+		b := c.ReadRaw(2)
+		a[0] = 0xFF
+		if b[0] != 2 || r.GetOffset() != 3 || c.GetOffset() != 3 {
+			t.Fatalf("Invalid Clone state")
+		}
+		r.ReadU32(BigEndian)
+		if r.Clone().Err() == nil || r.At(0).Err() != nil {
+			t.Fatalf("Invalid Clone error state")
+		}
+	}
+}
+
+func TestReaderBuffered(t *testing.T) {
+	src := make([]byte, 100)
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	r := NewReader(bytes.NewReader(src), WithBufferSize(16))
+	for i := 0; i < 25; i++ {
+		v := r.ReadU32(BigEndian)
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		b := byte(i * 4)
+		if v != uint32(b)<<24|uint32(b+1)<<16|uint32(b+2)<<8|uint32(b+3) {
+			t.Fatalf("Invalid buffered ReadU32 %08x", v)
+		}
+	}
+	r.SetOffset(10)
+	if r.ReadU16(LittleEndian) != 0x0B0A {
+		t.Fatalf("Invalid buffered ReadU16 after SetOffset")
+	}
+	if raw := r.ReadRaw(40); r.Err() != nil || raw[0] != 12 || raw[39] != 51 {
+		t.Fatalf("Invalid buffered ReadRaw")
+	}
+	r.SetOffset(98)
+	r.ReadU32(BigEndian)
+	if r.Err() == nil {
+		t.Fatalf("ReadU32 past end must fail")
+	}
+}
+
+// eofReaderAt returns io.EOF with reads that reach the end of the data.
+type eofReaderAt []byte
+
+func (e eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(e).ReadAt(p, off)
+	if err == nil && off+int64(n) == int64(len(e)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestReaderFullReadEOF(t *testing.T) {
+	r := NewReader(eofReaderAt{1, 2, 3, 4})
+	if v := r.ReadU32(BigEndian); r.Err() != nil || v != 0x01020304 {
+		t.Fatalf("Invalid ReadU32 at end %08x %v", v, r.Err())
+	}
+	r.ReadU8()
+	if r.Err() != io.EOF {
+		t.Fatalf("Invalid error past end %v", r.Err())
+	}
+}
+
+func benchmarkReadU32(b *testing.B, opts ...Option) {
+	testFileName := "bench.bin"
+	const size = 1 << 20
+
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fw.Write(make([]byte, size))
+	fw.Close()
+	defer os.Remove(testFileName)
+
+	fr, err := os.Open(testFileName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fr.Close()
+
+	b.SetBytes(4)
+	b.ResetTimer()
+	r := NewReader(fr, opts...)
+	for i := 0; i < b.N; i++ {
+		if r.GetOffset() == size {
+			r.SetOffset(0)
+		}
+		r.ReadU32(LittleEndian)
+	}
+	if r.Err() != nil {
+		b.Fatal(r.Err())
+	}
+}
+
+func BenchmarkReadU32(b *testing.B) {
+	benchmarkReadU32(b)
+}
+
+func BenchmarkReadU32Buffered(b *testing.B) {
+	benchmarkReadU32(b, WithBufferSize(64<<10))
+}
+
+func benchmarkReadSamples(b *testing.B, bulk bool) {
+	src := bytes.NewReader(make([]byte, 8192))
+	samples := make([]uint16, 4096)
+
+	b.SetBytes(int64(len(samples) * 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader(src)
+		if bulk {
+			r.ReadU16s(samples, LittleEndian)
+		} else {
+			for j := range samples {
+				samples[j] = r.ReadU16(LittleEndian)
+			}
+		}
+		if r.Err() != nil {
+			b.Fatal(r.Err())
+		}
+	}
+}
+
+func BenchmarkReadU16Loop(b *testing.B) {
+	benchmarkReadSamples(b, false)
+}
+
+func BenchmarkReadU16s(b *testing.B) {
+	benchmarkReadSamples(b, true)
+}
@@ -0,0 +1,203 @@
+// Package riff reads and writes RIFF files such as WAV, including the RF64 and
+// BW64 variants used for files larger than 4 GiB.
+package riff
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the riff package.
+var (
+	ErrFormat = errors.New("riff: invalid format")
+	ErrDS64   = errors.New("riff: missing ds64 chunk")
+)
+
+const sizeInDS64 = 0xFFFFFFFF
+
+// Chunk is a single chunk. Offset is the position of its data in the source.
+type Chunk struct {
+	ID     string
+	Size   uint64
+	Offset int64
+	src    io.ReaderAt
+}
+
+// Data returns a reader over the chunk data.
+func (c *Chunk) Data() *io.SectionReader {
+	return io.NewSectionReader(c.src, c.Offset, int64(c.Size))
+}
+
+// Bytes reads the whole chunk data.
+func (c *Chunk) Bytes() ([]byte, error) {
+	if c.Size > 0 {
+		// make sure the data exists before allocating for it
+		if n, _ := c.src.ReadAt(make([]byte, 1), c.Offset+int64(c.Size)-1); n != 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	r := bio.NewReader(c.src)
+	r.SetOffset(c.Offset)
+	b := r.ReadRaw(c.Size)
+	return b, r.Err()
+}
+
+// Reader iterates over the chunks of a RIFF form or LIST.
+type Reader struct {
+	// Form is the form type of the file (e.g. "WAVE") or the list type (e.g. "INFO").
+	Form string
+	// ID is "RIFF", "RF64", "BW64" or "LIST".
+	ID string
+
+	src  io.ReaderAt
+	br   *bio.Reader
+	end  int64
+	ds64 *ds64
+}
+
+type ds64 struct {
+	riffSize  uint64
+	dataSize  uint64
+	sampleCnt uint64
+	table     map[string]uint64
+}
+
+// NewReader parses the RIFF header of r.
+func NewReader(r io.ReaderAt) (*Reader, error) {
+	br := bio.NewReader(r)
+	id := br.ReadS32(bio.BigEndian)
+	size := uint64(br.ReadU32(bio.LittleEndian))
+	form := br.ReadS32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	rr := &Reader{Form: form, ID: id, src: r, br: br}
+	switch id {
+	case "RIFF":
+	case "RF64", "BW64":
+		if err := rr.readDS64(); err != nil {
+			return nil, err
+		}
+		if size == sizeInDS64 {
+			size = rr.ds64.riffSize
+		}
+	default:
+		return nil, ErrFormat
+	}
+	if size > math.MaxInt64-8 {
+		return nil, ErrFormat
+	}
+	rr.end = 8 + int64(size)
+	return rr, nil
+}
+
+func (rr *Reader) readDS64() error {
+	br := rr.br
+	if br.ReadS32(bio.BigEndian) != "ds64" {
+		return ErrDS64
+	}
+	size := int64(br.ReadU32(bio.LittleEndian))
+	start := br.GetOffset()
+	d := &ds64{table: make(map[string]uint64)}
+	d.riffSize = br.ReadU64(bio.LittleEndian)
+	d.dataSize = br.ReadU64(bio.LittleEndian)
+	d.sampleCnt = br.ReadU64(bio.LittleEndian)
+	n := br.ReadU32(bio.LittleEndian)
+	for i := uint32(0); i < n && br.Err() == nil; i++ {
+		id := br.ReadS32(bio.BigEndian)
+		d.table[id] = br.ReadU64(bio.LittleEndian)
+	}
+	if br.Err() != nil {
+		return br.Err()
+	}
+	br.SetOffset(start + size + size&1)
+	rr.ds64 = d
+	return nil
+}
+
+// OpenList returns a Reader over the sub-chunks of a LIST chunk.
+func OpenList(c *Chunk) (*Reader, error) {
+	if c.ID != "LIST" || c.Size < 4 {
+		return nil, ErrFormat
+	}
+	br := bio.NewReader(c.src)
+	br.SetOffset(c.Offset)
+	form := br.ReadS32(bio.BigEndian)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	return &Reader{Form: form, ID: c.ID, src: c.src, br: br, end: c.Offset + int64(c.Size)}, nil
+}
+
+// Next returns the next chunk, or io.EOF when there are no more chunks.
+// ds64 chunks are consumed by NewReader and not returned.
+func (rr *Reader) Next() (*Chunk, error) {
+	br := rr.br
+	if br.GetOffset()+8 > rr.end {
+		return nil, io.EOF
+	}
+	id := br.ReadS32(bio.BigEndian)
+	size := uint64(br.ReadU32(bio.LittleEndian))
+	if br.Err() == io.EOF {
+		// the RIFF size promises more chunks
+		return nil, io.ErrUnexpectedEOF
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if size == sizeInDS64 && rr.ds64 != nil {
+		if id == "data" {
+			size = rr.ds64.dataSize
+		} else if s, ok := rr.ds64.table[id]; ok {
+			size = s
+		}
+	}
+
+	c := &Chunk{ID: id, Size: size, Offset: br.GetOffset(), src: rr.src}
+	if size > uint64(rr.end-c.Offset) {
+		return nil, ErrFormat
+	}
+	br.SetOffset(c.Offset + int64(size) + int64(size&1))
+	return c, nil
+}
+
+// SampleCount returns the sample count stored in the ds64 chunk, if any.
+func (rr *Reader) SampleCount() (uint64, bool) {
+	if rr.ds64 == nil {
+		return 0, false
+	}
+	return rr.ds64.sampleCnt, true
+}
+
+// ReadInfo returns the text entries of a LIST/INFO chunk keyed by chunk ID.
+func ReadInfo(c *Chunk) (map[string]string, error) {
+	lr, err := OpenList(c)
+	if err != nil {
+		return nil, err
+	}
+	if lr.Form != "INFO" {
+		return nil, ErrFormat
+	}
+	info := make(map[string]string)
+	for {
+		sc, err := lr.Next()
+		if err == io.EOF {
+			return info, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := sc.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		for len(b) > 0 && b[len(b)-1] == 0 {
+			b = b[:len(b)-1]
+		}
+		info[sc.ID] = string(b)
+	}
+}
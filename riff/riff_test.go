@@ -0,0 +1,195 @@
+package riff
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func writeWAV(t *testing.T, name string, f Format, samples []byte, info map[string]string) *os.File {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ww, err := NewWAVWriter(fw, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ww.Write(samples); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range info {
+		ww.SetInfo(k, v)
+	}
+	if err := ww.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fw
+}
+
+func TestWAV(t *testing.T) {
+	testFileName := "test.wav"
+	defer os.Remove(testFileName)
+
+	f := Format{
+		AudioFormat:   FormatPCM,
+		Channels:      1,
+		SampleRate:    44100,
+		ByteRate:      88200,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+	}
+	samples := []byte{1, 2, 3, 4, 5, 6}
+
+	// -----------------------------
+	// RIFF
+	// -----------------------------
+	{
+		fw := writeWAV(t, testFileName, f, samples, map[string]string{"INAM": "test", "ISFT": "binaryio"})
+		defer fw.Close()
+
+		w, err := ReadWAV(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *w.Format != f {
+			t.Fatalf("Invalid Format %+v", w.Format)
+		}
+		data, err := w.Data.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, samples) {
+			t.Fatalf("Invalid data % x", data)
+		}
+		if w.Info["INAM"] != "test" || w.Info["ISFT"] != "binaryio" {
+			t.Fatalf("Invalid Info %v", w.Info)
+		}
+
+		rr, err := NewReader(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr.ID != "RIFF" || rr.Form != "WAVE" {
+			t.Fatalf("Invalid header %s %s", rr.ID, rr.Form)
+		}
+		var ids []string
+		for {
+			c, err := rr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, c.ID)
+		}
+		if len(ids) != 4 || ids[0] != "JUNK" || ids[1] != "fmt " || ids[2] != "data" || ids[3] != "LIST" {
+			t.Fatalf("Invalid chunks %q", ids)
+		}
+	}
+	// -----------------------------
+	// RF64 with odd sized data
+	// -----------------------------
+	{
+		ext := f
+		ext.AudioFormat = FormatExtensible
+		ext.ValidBitsPerSample = 16
+		ext.ChannelMask = 4
+		ext.SubFormat[0] = 1
+
+		// only the header of the 4 GiB file is kept
+		sink := &headWriterAt{head: make([]byte, 256)}
+		ww, err := NewWAVWriter(sink, ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := make([]byte, 1<<20)
+		for i := 0; i < 1<<12; i++ {
+			if _, err := ww.Write(block); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := ww.Write(samples[:5]); err != nil {
+			t.Fatal(err)
+		}
+		if err := ww.Close(); err != nil {
+			t.Fatal(err)
+		}
+		const dataSize = 1<<32 + 5
+
+		rr, err := NewReader(bytes.NewReader(sink.head))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr.ID != "RF64" {
+			t.Fatalf("Invalid ID %s", rr.ID)
+		}
+		if n, ok := rr.SampleCount(); !ok || n != dataSize/2 {
+			t.Fatalf("Invalid sample count %d", n)
+		}
+		w, err := ReadWAV(bytes.NewReader(sink.head))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *w.Format != ext {
+			t.Fatalf("Invalid extensible Format %+v", w.Format)
+		}
+		if w.Data.Size != dataSize {
+			t.Fatalf("Invalid data size %d", w.Data.Size)
+		}
+		if _, err := w.Data.Bytes(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Invalid missing data error %v", err)
+		}
+	}
+	// -----------------------------
+	// Errors
+	// -----------------------------
+	{
+		if _, err := NewReader(bytes.NewReader([]byte("RIFX\x04\x00\x00\x00WAVE"))); err != ErrFormat {
+			t.Fatalf("Invalid error %v", err)
+		}
+		if _, err := NewReader(bytes.NewReader([]byte("RF64\xff\xff\xff\xffWAVEJUNK\x00\x00\x00\x00"))); err != ErrDS64 {
+			t.Fatalf("Invalid error %v", err)
+		}
+
+		// INAM claiming 1 GiB inside a 32-byte file
+		rr, err := NewReader(bytes.NewReader([]byte("RIFF\x18\x00\x00\x00WAVELIST\x0c\x00\x00\x00INFOINAM\x00\x00\x00\x40")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := rr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadInfo(c); err != ErrFormat {
+			t.Fatalf("Invalid oversized chunk error %v", err)
+		}
+		c.Size = 1 << 30
+		if _, err := c.Bytes(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Invalid missing data error %v", err)
+		}
+
+		// RIFF size past the end of the file
+		rr, err = NewReader(bytes.NewReader([]byte("RIFF\x20\x00\x00\x00WAVE")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rr.Next(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Invalid truncated chunk header error %v", err)
+		}
+	}
+}
+
+// headWriterAt keeps the first len(head) bytes written to it and drops the rest.
+type headWriterAt struct {
+	head []byte
+}
+
+func (h *headWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < int64(len(h.head)) {
+		copy(h.head[off:], p)
+	}
+	return len(p), nil
+}
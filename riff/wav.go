@@ -0,0 +1,237 @@
+package riff
+
+import (
+	"io"
+	"sort"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// WAVE format tags.
+const (
+	FormatPCM        = 0x0001
+	FormatIEEEFloat  = 0x0003
+	FormatExtensible = 0xFFFE
+)
+
+// Format is the contents of a WAVE fmt chunk. The extensible fields are only
+// used when AudioFormat is FormatExtensible.
+type Format struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+
+	ValidBitsPerSample uint16
+	ChannelMask        uint32
+	SubFormat          bio.UUID
+}
+
+// ParseFormat decodes a fmt chunk.
+func ParseFormat(c *Chunk) (*Format, error) {
+	if c.ID != "fmt " || c.Size < 16 {
+		return nil, ErrFormat
+	}
+	br := bio.NewReader(c.src)
+	br.SetOffset(c.Offset)
+	f := &Format{}
+	f.AudioFormat = br.ReadU16(bio.LittleEndian)
+	f.Channels = br.ReadU16(bio.LittleEndian)
+	f.SampleRate = br.ReadU32(bio.LittleEndian)
+	f.ByteRate = br.ReadU32(bio.LittleEndian)
+	f.BlockAlign = br.ReadU16(bio.LittleEndian)
+	f.BitsPerSample = br.ReadU16(bio.LittleEndian)
+	if f.AudioFormat == FormatExtensible && c.Size >= 40 {
+		br.ReadU16(bio.LittleEndian) // cbSize
+		f.ValidBitsPerSample = br.ReadU16(bio.LittleEndian)
+		f.ChannelMask = br.ReadU32(bio.LittleEndian)
+		f.SubFormat = br.ReadGUID()
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	return f, nil
+}
+
+// WAV is a parsed WAVE file.
+type WAV struct {
+	Format *Format
+	Data   *Chunk
+	Info   map[string]string
+}
+
+// ReadWAV parses the fmt, data and LIST/INFO chunks of a WAVE file.
+func ReadWAV(r io.ReaderAt) (*WAV, error) {
+	rr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if rr.Form != "WAVE" {
+		return nil, ErrFormat
+	}
+
+	w := &WAV{}
+	for {
+		c, err := rr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch c.ID {
+		case "fmt ":
+			if w.Format, err = ParseFormat(c); err != nil {
+				return nil, err
+			}
+		case "data":
+			w.Data = c
+		case "LIST":
+			lr, err := OpenList(c)
+			if err != nil {
+				return nil, err
+			}
+			if lr.Form == "INFO" {
+				if w.Info, err = ReadInfo(c); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if w.Format == nil || w.Data == nil {
+		return nil, ErrFormat
+	}
+	return w, nil
+}
+
+// maxSize is the largest size a plain RIFF file can record.
+const maxSize = 0xFFFFFFFF
+
+// junkSize is the size of the JUNK chunk reserved for a ds64 chunk.
+const junkSize = 28
+
+// WAVWriter writes a WAVE file, back-patching the chunk sizes on Close. Space for a
+// ds64 chunk is reserved up front so files over 4 GiB are written as RF64.
+type WAVWriter struct {
+	w          *bio.Writer
+	format     Format
+	dataOffset int64
+	dataSize   uint64
+	info       map[string]string
+}
+
+// NewWAVWriter writes the WAVE header for f and prepares for sample data.
+func NewWAVWriter(w io.WriterAt, f Format) (*WAVWriter, error) {
+	bw := bio.NewWriter(w)
+	bw.WriteS32("RIFF", bio.BigEndian)
+	bw.WriteU32(0, bio.LittleEndian)
+	bw.WriteS32("WAVE", bio.BigEndian)
+
+	bw.WriteS32("JUNK", bio.BigEndian)
+	bw.WriteU32(junkSize, bio.LittleEndian)
+	bw.WriteRaw(make([]byte, junkSize))
+
+	bw.WriteS32("fmt ", bio.BigEndian)
+	if f.AudioFormat == FormatExtensible {
+		bw.WriteU32(40, bio.LittleEndian)
+	} else {
+		bw.WriteU32(16, bio.LittleEndian)
+	}
+	bw.WriteU16(f.AudioFormat, bio.LittleEndian)
+	bw.WriteU16(f.Channels, bio.LittleEndian)
+	bw.WriteU32(f.SampleRate, bio.LittleEndian)
+	bw.WriteU32(f.ByteRate, bio.LittleEndian)
+	bw.WriteU16(f.BlockAlign, bio.LittleEndian)
+	bw.WriteU16(f.BitsPerSample, bio.LittleEndian)
+	if f.AudioFormat == FormatExtensible {
+		bw.WriteU16(22, bio.LittleEndian)
+		bw.WriteU16(f.ValidBitsPerSample, bio.LittleEndian)
+		bw.WriteU32(f.ChannelMask, bio.LittleEndian)
+		bw.WriteGUID(f.SubFormat)
+	}
+
+	bw.WriteS32("data", bio.BigEndian)
+	bw.WriteU32(0, bio.LittleEndian)
+	if bw.Err() != nil {
+		return nil, bw.Err()
+	}
+	return &WAVWriter{w: bw, format: f, dataOffset: bw.GetOffset()}, nil
+}
+
+// Write appends sample data.
+func (ww *WAVWriter) Write(p []byte) (int, error) {
+	n := ww.w.WriteRaw(p)
+	ww.dataSize += uint64(n)
+	return n, ww.w.Err()
+}
+
+// SetInfo sets a LIST/INFO entry such as "INAM" or "ISFT", written on Close.
+func (ww *WAVWriter) SetInfo(id, value string) {
+	if ww.info == nil {
+		ww.info = make(map[string]string)
+	}
+	ww.info[id] = value
+}
+
+// Close pads the data chunk, appends LIST/INFO and back-patches the sizes.
+// It does not close the underlying io.WriterAt.
+func (ww *WAVWriter) Close() error {
+	bw := ww.w
+	if ww.dataSize&1 == 1 {
+		bw.WriteU8(0)
+	}
+
+	if len(ww.info) > 0 {
+		ids := make([]string, 0, len(ww.info))
+		for id := range ww.info {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		bw.WriteS32("LIST", bio.BigEndian)
+		sizeOffset := bw.GetOffset()
+		bw.WriteU32(0, bio.LittleEndian)
+		bw.WriteS32("INFO", bio.BigEndian)
+		for _, id := range ids {
+			v := append([]byte(ww.info[id]), 0)
+			bw.WriteS32(id, bio.BigEndian)
+			bw.WriteU32(uint32(len(v)), bio.LittleEndian)
+			bw.WriteRaw(v)
+			if len(v)&1 == 1 {
+				bw.WriteU8(0)
+			}
+		}
+		end := bw.GetOffset()
+		bw.SetOffset(sizeOffset)
+		bw.WriteU32(uint32(end-sizeOffset-4), bio.LittleEndian)
+		bw.SetOffset(end)
+	}
+
+	riffSize := uint64(bw.GetOffset() - 8)
+	if riffSize > maxSize || ww.dataSize > maxSize {
+		bw.SetOffset(0)
+		bw.WriteS32("RF64", bio.BigEndian)
+		bw.WriteU32(sizeInDS64, bio.LittleEndian)
+		bw.SetOffset(12)
+		bw.WriteS32("ds64", bio.BigEndian)
+		bw.SetOffset(20)
+		bw.WriteU64(riffSize, bio.LittleEndian)
+		bw.WriteU64(ww.dataSize, bio.LittleEndian)
+		var samples uint64
+		if ww.format.BlockAlign > 0 {
+			samples = ww.dataSize / uint64(ww.format.BlockAlign)
+		}
+		bw.WriteU64(samples, bio.LittleEndian)
+		bw.WriteU32(0, bio.LittleEndian)
+		bw.SetOffset(ww.dataOffset - 4)
+		bw.WriteU32(sizeInDS64, bio.LittleEndian)
+	} else {
+		bw.SetOffset(4)
+		bw.WriteU32(uint32(riffSize), bio.LittleEndian)
+		bw.SetOffset(ww.dataOffset - 4)
+		bw.WriteU32(uint32(ww.dataSize), bio.LittleEndian)
+	}
+	return bw.Flush()
+}
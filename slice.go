@@ -0,0 +1,40 @@
+package binaryio
+
+import (
+	"unsafe"
+)
+
+// maxView bounds the byte views taken over typed slices; larger slices are processed in chunks.
+const maxView = 1 << 30
+
+var hostEndian = func() Endian {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return LittleEndian
+	}
+	return BigEndian
+}()
+
+// byteView reinterprets n bytes starting at p as a byte slice. n must not exceed maxView.
+func byteView(p unsafe.Pointer, n int) []byte {
+	return (*[maxView]byte)(p)[:n:n]
+}
+
+// swapBytes reverses the byte order of every size-byte element in b.
+func swapBytes(b []byte, size int) {
+	switch size {
+	case 2:
+		for i := 0; i+1 < len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	case 4:
+		for i := 0; i+3 < len(b); i += 4 {
+			b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+		}
+	case 8:
+		for i := 0; i+7 < len(b); i += 8 {
+			b[i], b[i+1], b[i+2], b[i+3], b[i+4], b[i+5], b[i+6], b[i+7] =
+				b[i+7], b[i+6], b[i+5], b[i+4], b[i+3], b[i+2], b[i+1], b[i]
+		}
+	}
+}
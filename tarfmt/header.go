@@ -0,0 +1,261 @@
+// Package tarfmt reads and writes tar archives at the header level: ustar, GNU
+// and PAX headers, octal and base-256 numbers, checksums, long names and block
+// padding.
+package tarfmt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by the tarfmt package.
+var (
+	ErrHeader        = errors.New("tarfmt: invalid header")
+	ErrChecksum      = errors.New("tarfmt: header checksum mismatch")
+	ErrFieldTooLong  = errors.New("tarfmt: field does not fit the header format")
+	ErrWriteTooLong  = errors.New("tarfmt: write exceeds header size")
+	ErrMissingData   = errors.New("tarfmt: entry data shorter than header size")
+	ErrWriteAfterEnd = errors.New("tarfmt: write after close")
+)
+
+// BlockSize is the size of tar headers and the unit of data padding.
+const BlockSize = 512
+
+// Type flags.
+const (
+	TypeReg      = '0'
+	TypeRegA     = '\x00'
+	TypeLink     = '1'
+	TypeSymlink  = '2'
+	TypeChar     = '3'
+	TypeBlock    = '4'
+	TypeDir      = '5'
+	TypeFifo     = '6'
+	TypeXHeader  = 'x' // PAX records for the next entry
+	TypeXGlobal  = 'g' // PAX records for all following entries
+	TypeLongName = 'L' // GNU long name for the next entry
+	TypeLongLink = 'K' // GNU long link name for the next entry
+)
+
+// Format is the header format of an entry.
+type Format int
+
+// Header formats.
+const (
+	FormatUnknown Format = iota // V7 when reading; chosen automatically when writing
+	FormatUSTAR
+	FormatPAX
+	FormatGNU
+)
+
+// Field positions in a header block.
+const (
+	offName     = 0
+	offMode     = 100
+	offUID      = 108
+	offGID      = 116
+	offSize     = 124
+	offMtime    = 136
+	offChksum   = 148
+	offTypeflag = 156
+	offLinkname = 157
+	offMagic    = 257
+	offUname    = 265
+	offGname    = 297
+	offDevmajor = 329
+	offDevminor = 337
+	offPrefix   = 345
+)
+
+const (
+	magicUSTAR = "ustar\x0000"
+	magicGNU   = "ustar  \x00"
+)
+
+// Header is a tar entry header with PAX records and GNU long names applied.
+// Offset is the position of the header block and DataOffset of the entry data.
+type Header struct {
+	Name       string
+	Mode       int64
+	UID        int
+	GID        int
+	Size       int64
+	ModTime    time.Time
+	Typeflag   byte
+	Linkname   string
+	Uname      string
+	Gname      string
+	Devmajor   int64
+	Devminor   int64
+	Format     Format
+	PAXRecords map[string]string
+	Offset     int64
+	DataOffset int64
+	src        io.ReaderAt
+}
+
+// Checksum returns the unsigned and signed byte sums of a header block with the
+// checksum field taken as spaces. Historic implementations used signed sums.
+func Checksum(block []byte) (unsigned, signed int64) {
+	for i, c := range block[:BlockSize] {
+		if i >= offChksum && i < offChksum+8 {
+			c = ' '
+		}
+		unsigned += int64(c)
+		signed += int64(int8(c))
+	}
+	return unsigned, signed
+}
+
+// parseString returns a NUL-terminated field.
+func parseString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// parseNumeric parses an octal field terminated by NUL or space, or a GNU
+// base-256 field marked by the high bit of the first byte.
+func parseNumeric(b []byte) (int64, error) {
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		// Two's complement big-endian; bit 0x40 of the first byte is the sign.
+		inv := byte(0)
+		if b[0]&0x40 != 0 {
+			inv = 0xFF
+		}
+		var v uint64
+		for i, c := range b {
+			c ^= inv
+			if i == 0 {
+				c &= 0x7F
+			}
+			if v>>56 != 0 {
+				return 0, ErrHeader
+			}
+			v = v<<8 | uint64(c)
+		}
+		if v>>63 != 0 {
+			return 0, ErrHeader
+		}
+		if inv == 0xFF {
+			return ^int64(v), nil
+		}
+		return int64(v), nil
+	}
+	s := strings.Trim(string(b), " \x00")
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s, 8, 64)
+	if err != nil {
+		return 0, ErrHeader
+	}
+	return v, nil
+}
+
+// fitsOctal reports whether v fits n octal digits followed by a NUL.
+func fitsOctal(v int64, n int) bool {
+	return v >= 0 && (n-1 >= 21 || v < 1<<(3*uint(n-1)))
+}
+
+// formatOctal writes v as zero-padded octal followed by a NUL.
+func formatOctal(b []byte, v int64) {
+	s := strconv.FormatInt(v, 8)
+	for i := range b[:len(b)-1] {
+		b[i] = '0'
+	}
+	copy(b[len(b)-1-len(s):], s)
+	b[len(b)-1] = 0
+}
+
+// formatBase256 writes v in GNU base-256 form.
+func formatBase256(b []byte, v int64) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	b[0] |= 0x80
+}
+
+// parsePAX parses "%d %s=%s\n" records.
+func parsePAX(data []byte) (map[string]string, error) {
+	records := make(map[string]string)
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp <= 0 {
+			return nil, ErrHeader
+		}
+		n, err := strconv.Atoi(string(data[:sp]))
+		if err != nil || n <= sp+1 || n > len(data) || data[n-1] != '\n' {
+			return nil, ErrHeader
+		}
+		kv := string(data[sp+1 : n-1])
+		eq := strings.IndexByte(kv, '=')
+		if eq <= 0 {
+			return nil, ErrHeader
+		}
+		records[kv[:eq]] = kv[eq+1:]
+		data = data[n:]
+	}
+	return records, nil
+}
+
+// formatPAXRecord formats one record; the length prefix counts itself.
+func formatPAXRecord(k, v string) string {
+	size := len(k) + len(v) + 3 // space, '=', newline
+	size += len(strconv.Itoa(size))
+	record := strconv.Itoa(size) + " " + k + "=" + v + "\n"
+	if len(record) != size {
+		// Adding the prefix carried it into another digit.
+		size = len(record)
+		record = strconv.Itoa(size) + " " + k + "=" + v + "\n"
+	}
+	return record
+}
+
+// parsePAXTime parses a decimal timestamp with optional fraction.
+func parsePAXTime(s string) (time.Time, error) {
+	secs, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		secs, frac = s[:i], s[i+1:]
+	}
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrHeader
+	}
+	var nsec int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		frac += strings.Repeat("0", 9-len(frac))
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, ErrHeader
+		}
+		if strings.HasPrefix(secs, "-") {
+			nsec = -nsec
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// formatPAXTime formats t as decimal seconds with a fraction when needed.
+func formatPAXTime(t time.Time) string {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if nsec == 0 {
+		return strconv.FormatInt(sec, 10)
+	}
+	sign := ""
+	if sec < 0 {
+		sign = "-"
+		sec = -(sec + 1)
+		nsec = 1e9 - nsec
+	}
+	frac := strings.TrimRight(strconv.FormatInt(nsec+1e9, 10)[1:], "0")
+	return sign + strconv.FormatInt(sec, 10) + "." + frac
+}
@@ -0,0 +1,218 @@
+package tarfmt
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// maxExtSize limits PAX and GNU long name entries read into memory.
+const maxExtSize = 1 << 20
+
+// blockPad returns the padding after n bytes of entry data.
+func blockPad(n int64) int64 {
+	return -n & (BlockSize - 1)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reader iterates over the entries of a tar archive.
+type Reader struct {
+	src    io.ReaderAt
+	br     *bio.Reader
+	global map[string]string
+}
+
+// NewReader returns a Reader starting at offset 0 of r.
+func NewReader(r io.ReaderAt) *Reader {
+	return &Reader{src: r, br: bio.NewReader(r, bio.WithBufferSize(64<<10))}
+}
+
+// Next returns the next file entry, or io.EOF at the end-of-archive marker.
+// PAX and GNU extension entries are consumed and applied to the entry they
+// describe; global PAX records apply to all following entries.
+func (r *Reader) Next() (*Header, error) {
+	var local map[string]string
+	var longName, longLink *string
+	for {
+		h, err := r.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch h.Typeflag {
+		case TypeXHeader, TypeXGlobal, TypeLongName, TypeLongLink:
+			if h.Size > maxExtSize {
+				return nil, ErrHeader
+			}
+			r.br.SetOffset(h.DataOffset)
+			data := r.br.ReadRaw(uint64(h.Size))
+			if r.br.Err() != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			r.br.SetOffset(h.DataOffset + h.Size + blockPad(h.Size))
+			switch h.Typeflag {
+			case TypeXHeader, TypeXGlobal:
+				records, err := parsePAX(data)
+				if err != nil {
+					return nil, err
+				}
+				if h.Typeflag == TypeXGlobal {
+					r.global = merge(r.global, records)
+				} else {
+					local = merge(local, records)
+				}
+			case TypeLongName:
+				s := parseString(data)
+				longName = &s
+			case TypeLongLink:
+				s := parseString(data)
+				longLink = &s
+			}
+			continue
+		}
+
+		if longName != nil {
+			h.Name = *longName
+		}
+		if longLink != nil {
+			h.Linkname = *longLink
+		}
+		if records := merge(merge(nil, r.global), local); len(records) > 0 {
+			if err := h.applyPAX(records); err != nil {
+				return nil, err
+			}
+		}
+		r.br.SetOffset(h.DataOffset + h.Size + blockPad(h.Size))
+		return h, nil
+	}
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string)
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// readHeader reads and verifies one header block.
+func (r *Reader) readHeader() (*Header, error) {
+	br := r.br
+	off := br.GetOffset()
+	block := br.ReadRaw(BlockSize)
+	if br.Err() == io.EOF {
+		// only an archive ending between blocks ends cleanly
+		if n, _ := r.src.ReadAt(make([]byte, 1), off); n == 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, io.EOF
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if isZero(block) {
+		return nil, io.EOF
+	}
+
+	stored, err := parseNumeric(block[offChksum : offChksum+8])
+	if err != nil {
+		return nil, err
+	}
+	if unsigned, signed := Checksum(block); stored != unsigned && stored != signed {
+		return nil, ErrChecksum
+	}
+
+	h := &Header{Offset: off, DataOffset: off + BlockSize, src: r.src}
+	switch magic := string(block[offMagic : offMagic+8]); {
+	case magic == magicGNU:
+		h.Format = FormatGNU
+	case magic[:6] == magicUSTAR[:6]:
+		h.Format = FormatUSTAR
+	}
+	h.Name = parseString(block[offName : offName+100])
+	h.Typeflag = block[offTypeflag]
+	h.Linkname = parseString(block[offLinkname : offLinkname+100])
+	var uid, gid, mtime int64
+	num := func(p *int64, field []byte) {
+		if err == nil {
+			*p, err = parseNumeric(field)
+		}
+	}
+	num(&h.Mode, block[offMode:offUID])
+	num(&uid, block[offUID:offGID])
+	num(&gid, block[offGID:offSize])
+	num(&h.Size, block[offSize:offMtime])
+	num(&mtime, block[offMtime:offChksum])
+	if h.Format != FormatUnknown {
+		h.Uname = parseString(block[offUname : offUname+32])
+		h.Gname = parseString(block[offGname : offGname+32])
+		num(&h.Devmajor, block[offDevmajor:offDevminor])
+		num(&h.Devminor, block[offDevminor:offPrefix])
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.UID, h.GID, h.ModTime = int(uid), int(gid), time.Unix(mtime, 0)
+	if h.Format == FormatUSTAR {
+		if prefix := parseString(block[offPrefix : offPrefix+155]); prefix != "" {
+			h.Name = prefix + "/" + h.Name
+		}
+	}
+	if h.Size < 0 {
+		return nil, ErrHeader
+	}
+	return h, nil
+}
+
+// applyPAX overrides header fields with PAX records.
+func (h *Header) applyPAX(records map[string]string) error {
+	h.PAXRecords = records
+	h.Format = FormatPAX
+	for k, v := range records {
+		var err error
+		switch k {
+		case "path":
+			h.Name = v
+		case "linkpath":
+			h.Linkname = v
+		case "uname":
+			h.Uname = v
+		case "gname":
+			h.Gname = v
+		case "uid":
+			h.UID, err = strconv.Atoi(v)
+		case "gid":
+			h.GID, err = strconv.Atoi(v)
+		case "size":
+			h.Size, err = strconv.ParseInt(v, 10, 64)
+			if err == nil && h.Size < 0 {
+				err = ErrHeader
+			}
+		case "mtime":
+			h.ModTime, err = parsePAXTime(v)
+		}
+		if err != nil {
+			return ErrHeader
+		}
+	}
+	return nil
+}
+
+// Data returns a reader over the entry data of a header returned by Reader.
+func (h *Header) Data() *io.SectionReader {
+	return io.NewSectionReader(h.src, h.DataOffset, h.Size)
+}
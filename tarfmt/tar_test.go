@@ -0,0 +1,202 @@
+package tarfmt
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+func TestArchive(t *testing.T) {
+	mod := time.Unix(1600000000, 0)
+	long := strings.Repeat("dir/", 30) + "file.txt"
+	entries := []struct {
+		h      Header
+		data   string
+		format Format
+	}{
+		{Header{Name: "hello.txt", Mode: 0644, UID: 1000, GID: 1000, ModTime: mod, Typeflag: TypeReg, Uname: "user", Gname: "group"}, "Hello, World!", FormatUSTAR},
+		{Header{Name: strings.Repeat("a", 90) + "/" + strings.Repeat("b", 90), Mode: 0600, ModTime: mod, Typeflag: TypeReg}, "prefix", FormatUSTAR},
+		{Header{Name: long, Mode: 0644, ModTime: mod, Typeflag: TypeReg, PAXRecords: map[string]string{"comment": "pax"}}, strings.Repeat("x", 600), FormatPAX},
+		{Header{Name: "日本語.txt", Mode: 0644, UID: 1 << 22, ModTime: mod, Typeflag: TypeReg}, "", FormatPAX},
+		{Header{Name: "link", Linkname: long, Mode: 0777, ModTime: mod, Typeflag: TypeSymlink}, "", FormatPAX},
+		{Header{Name: long + ".gnu", Mode: 0644, UID: 1 << 22, ModTime: mod, Typeflag: TypeReg, Format: FormatGNU}, "gnu", FormatGNU},
+	}
+
+	var buf bio.Buffer
+	tw := NewWriter(&buf)
+	for _, e := range entries {
+		h := e.h
+		h.Size = int64(len(e.data))
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(buf)
+	if len(b)%BlockSize != 0 || !isZero(b[len(b)-2*BlockSize:]) {
+		t.Fatalf("Invalid archive padding %d", len(b))
+	}
+
+	// -----------------------------
+	// archive/tar
+	// -----------------------------
+	{
+		tr := tar.NewReader(bytes.NewReader(b))
+		for _, e := range entries {
+			h, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := ioutil.ReadAll(tr)
+			if h.Name != e.h.Name || h.Linkname != e.h.Linkname || h.Uid != e.h.UID || string(data) != e.data || !h.ModTime.Equal(e.h.ModTime) {
+				t.Fatalf("Invalid archive/tar entry %+v", h)
+			}
+		}
+		if _, err := tr.Next(); err != io.EOF {
+			t.Fatalf("Invalid archive/tar end %v", err)
+		}
+	}
+
+	// -----------------------------
+	// Reader
+	// -----------------------------
+	{
+		r := NewReader(bytes.NewReader(b))
+		for i, e := range entries {
+			h, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := ioutil.ReadAll(h.Data())
+			if h.Name != e.h.Name || h.Linkname != e.h.Linkname || h.UID != e.h.UID || h.Mode != e.h.Mode || string(data) != e.data || !h.ModTime.Equal(e.h.ModTime) {
+				t.Fatalf("Invalid entry %d %+v", i, h)
+			}
+			if h.DataOffset%BlockSize != 0 {
+				t.Fatalf("Unaligned data offset %d", h.DataOffset)
+			}
+			if h.Format != e.format {
+				t.Fatalf("Invalid format %d for entry %d", h.Format, i)
+			}
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("Invalid end %v", err)
+		}
+	}
+
+	// -----------------------------
+	// Damaged archives
+	// -----------------------------
+	{
+		bad := append([]byte(nil), b...)
+		bad[0] ^= 1
+		if _, err := NewReader(bytes.NewReader(bad)).Next(); err != ErrChecksum {
+			t.Fatalf("Invalid checksum error %v", err)
+		}
+		// without the end-of-archive marker the archive ends between blocks
+		r := NewReader(bytes.NewReader(b[:2*BlockSize]))
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("Invalid end without marker %v", err)
+		}
+		r = NewReader(bytes.NewReader(b[:2*BlockSize+100]))
+		r.Next()
+		if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Invalid short header error %v", err)
+		}
+	}
+}
+
+func TestArchiveTar(t *testing.T) {
+	// Global PAX records and GNU long names written by archive/tar.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "global"}})
+	long := strings.Repeat("n", 150)
+	tw.WriteHeader(&tar.Header{Name: long, Size: 3, Mode: 0644, Format: tar.FormatGNU, ModTime: time.Unix(1e9, 0)})
+	tw.Write([]byte("abc"))
+	tw.WriteHeader(&tar.Header{Name: "nano", Mode: 0644, ModTime: time.Unix(1e9, 5e8), Format: tar.FormatPAX})
+	tw.Close()
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	h, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(h.Data())
+	if h.Name != long || string(data) != "abc" || h.PAXRecords["comment"] != "global" {
+		t.Fatalf("Invalid entry %+v", h)
+	}
+	h, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "nano" || !h.ModTime.Equal(time.Unix(1e9, 5e8)) {
+		t.Fatalf("Invalid PAX mtime %v", h.ModTime)
+	}
+}
+
+func TestNumeric(t *testing.T) {
+	for _, v := range []int64{0, 1, 0777, 1 << 33, -1, -(1 << 40), 1<<63 - 1, -1 << 63} {
+		b := make([]byte, 12)
+		if fitsOctal(v, 12) {
+			formatOctal(b, v)
+		} else {
+			formatBase256(b, v)
+		}
+		got, err := parseNumeric(b)
+		if err != nil || got != v {
+			t.Fatalf("Invalid numeric %d: %d %v", v, got, err)
+		}
+	}
+	if v, err := parseNumeric([]byte("  755 \x00")); v != 0755 || err != nil {
+		t.Fatalf("Invalid octal %o %v", v, err)
+	}
+	if _, err := parseNumeric([]byte{0x80, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}); err != ErrHeader {
+		t.Fatalf("Invalid overflow error %v", err)
+	}
+	for _, s := range []string{"1600000000", "1600000000.5", "-1.25"} {
+		tm, err := parsePAXTime(s)
+		if err != nil || formatPAXTime(tm) != s {
+			t.Fatalf("Invalid PAX time %s: %s", s, formatPAXTime(tm))
+		}
+	}
+	for _, kv := range [][2]string{{"a", "b"}, {"path", strings.Repeat("p", 92)}} {
+		rec := formatPAXRecord(kv[0], kv[1])
+		m, err := parsePAX([]byte(rec))
+		if err != nil || m[kv[0]] != kv[1] {
+			t.Fatalf("Invalid PAX record %q", rec)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bio.Buffer
+	tw := NewWriter(&buf)
+	tw.WriteHeader(&Header{Name: "a", Size: 2, Typeflag: TypeReg})
+	if n, err := tw.Write([]byte("abc")); n != 2 || err != ErrWriteTooLong {
+		t.Fatalf("Invalid long write %d %v", n, err)
+	}
+	tw.WriteHeader(&Header{Name: "b", Size: 2, Typeflag: TypeReg})
+	if err := tw.Close(); err != ErrMissingData {
+		t.Fatalf("Invalid short write error %v", err)
+	}
+	if err := NewWriter(&buf).WriteHeader(&Header{Name: strings.Repeat("x", 200), Format: FormatUSTAR}); err != ErrFieldTooLong {
+		t.Fatalf("Invalid USTAR error %v", err)
+	}
+	if err := NewWriter(&buf).WriteHeader(&Header{Name: "c", Size: -5, Typeflag: TypeReg}); err != ErrHeader {
+		t.Fatalf("Invalid negative size error %v", err)
+	}
+}
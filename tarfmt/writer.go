@@ -0,0 +1,242 @@
+package tarfmt
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strconv"
+
+	bio "github.com/takurooo/binaryio"
+	"github.com/takurooo/binaryio/internal/ascii"
+)
+
+// Writer writes a tar archive header by header.
+type Writer struct {
+	w         *bio.Writer
+	remaining int64
+	pad       int64
+	closed    bool
+}
+
+// NewWriter returns a Writer starting at offset 0 of w.
+func NewWriter(w io.WriterAt) *Writer {
+	return &Writer{w: bio.NewWriter(w, bio.WithBufferSize(64<<10))}
+}
+
+// Write writes entry data, at most the Size of the last header.
+func (tw *Writer) Write(p []byte) (int, error) {
+	if tw.closed {
+		return 0, ErrWriteAfterEnd
+	}
+	var err error
+	if int64(len(p)) > tw.remaining {
+		p, err = p[:tw.remaining], ErrWriteTooLong
+	}
+	n := tw.w.WriteRaw(p)
+	tw.remaining -= int64(n)
+	if tw.w.Err() != nil {
+		return n, tw.w.Err()
+	}
+	return n, err
+}
+
+// finish pads the data of the current entry to a block boundary.
+func (tw *Writer) finish() error {
+	if tw.remaining > 0 {
+		return ErrMissingData
+	}
+	tw.w.WriteRaw(make([]byte, tw.pad))
+	tw.pad = 0
+	return tw.w.Err()
+}
+
+// WriteHeader finishes the previous entry and writes h. With FormatGNU long
+// names are written as GNU long name entries and large numbers in base-256;
+// otherwise fields that do not fit a ustar header are written as PAX records,
+// along with h.PAXRecords. FormatUSTAR reports ErrFieldTooLong instead. A
+// negative size reports ErrHeader.
+func (tw *Writer) WriteHeader(h *Header) error {
+	if tw.closed {
+		return ErrWriteAfterEnd
+	}
+	if err := tw.finish(); err != nil {
+		return err
+	}
+	if h.Size < 0 {
+		return ErrHeader
+	}
+
+	format := h.Format
+	if format == FormatGNU {
+		if len(h.Uname) > 32 || len(h.Gname) > 32 {
+			return ErrFieldTooLong
+		}
+		if len(h.Name) > 100 {
+			if err := tw.writeExt(TypeLongName, "././@LongLink", []byte(h.Name+"\x00"), format); err != nil {
+				return err
+			}
+		}
+		if len(h.Linkname) > 100 {
+			if err := tw.writeExt(TypeLongLink, "././@LongLink", []byte(h.Linkname+"\x00"), format); err != nil {
+				return err
+			}
+		}
+	} else {
+		records := paxRecords(h)
+		if len(records) > 0 {
+			if format == FormatUSTAR {
+				return ErrFieldTooLong
+			}
+			keys := make([]string, 0, len(records))
+			for k := range records {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			var data []byte
+			for _, k := range keys {
+				data = append(data, formatPAXRecord(k, records[k])...)
+			}
+			name := path.Join(path.Dir(h.Name), "PaxHeaders.0", path.Base(h.Name))
+			if err := tw.writeExt(TypeXHeader, name, data, FormatPAX); err != nil {
+				return err
+			}
+		}
+		format = FormatUSTAR
+	}
+
+	tw.w.WriteRaw(encode(h, format))
+	tw.remaining = h.Size
+	tw.pad = blockPad(h.Size)
+	return tw.w.Err()
+}
+
+// writeExt writes an extension entry with its data and padding.
+func (tw *Writer) writeExt(typeflag byte, name string, data []byte, format Format) error {
+	h := &Header{Name: name, Typeflag: typeflag, Size: int64(len(data)), Mode: 0644}
+	if format != FormatGNU {
+		format = FormatUSTAR
+	}
+	tw.w.WriteRaw(encode(h, format))
+	tw.w.WriteRaw(data)
+	tw.w.WriteRaw(make([]byte, blockPad(h.Size)))
+	return tw.w.Err()
+}
+
+// Close finishes the last entry, writes the two zero blocks ending the archive
+// and flushes the writer.
+func (tw *Writer) Close() error {
+	if tw.closed {
+		return nil
+	}
+	if err := tw.finish(); err != nil {
+		return err
+	}
+	tw.closed = true
+	tw.w.WriteRaw(make([]byte, 2*BlockSize))
+	return tw.w.Flush()
+}
+
+// splitUSTARPath splits a name into a ustar prefix and name at a slash.
+func splitUSTARPath(name string) (prefix, suffix string, ok bool) {
+	if len(name) <= 100 {
+		return "", name, true
+	}
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] == '/' && i <= 155 && len(name)-i-1 <= 100 && i < len(name)-1 {
+			return name[:i], name[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// paxRecords returns the records needed for fields that do not fit a ustar
+// header, merged over h.PAXRecords.
+func paxRecords(h *Header) map[string]string {
+	records := make(map[string]string)
+	for k, v := range h.PAXRecords {
+		records[k] = v
+	}
+	if _, _, ok := splitUSTARPath(h.Name); !ok || !ascii.ValidString(h.Name) {
+		records["path"] = h.Name
+	}
+	if len(h.Linkname) > 100 || !ascii.ValidString(h.Linkname) {
+		records["linkpath"] = h.Linkname
+	}
+	if len(h.Uname) > 32 || !ascii.ValidString(h.Uname) {
+		records["uname"] = h.Uname
+	}
+	if len(h.Gname) > 32 || !ascii.ValidString(h.Gname) {
+		records["gname"] = h.Gname
+	}
+	if !fitsOctal(int64(h.UID), 8) {
+		records["uid"] = strconv.Itoa(h.UID)
+	}
+	if !fitsOctal(int64(h.GID), 8) {
+		records["gid"] = strconv.Itoa(h.GID)
+	}
+	if !fitsOctal(h.Size, 12) {
+		records["size"] = strconv.FormatInt(h.Size, 10)
+	}
+	if !fitsOctal(unixTime(h), 12) {
+		records["mtime"] = formatPAXTime(h.ModTime)
+	}
+	return records
+}
+
+// unixTime returns the modification time in seconds, 0 for the zero time.
+func unixTime(h *Header) int64 {
+	if h.ModTime.IsZero() {
+		return 0
+	}
+	return h.ModTime.Unix()
+}
+
+// encode builds a header block in ustar or GNU layout, computing its checksum.
+// Numbers that do not fit are written in base-256 for GNU and as zero for
+// ustar, where PAX records carry them.
+func encode(h *Header, format Format) []byte {
+	b := make([]byte, BlockSize)
+	num := func(off, n int, v int64) {
+		switch {
+		case fitsOctal(v, n):
+			formatOctal(b[off:off+n], v)
+		case format == FormatGNU:
+			formatBase256(b[off:off+n], v)
+		default:
+			formatOctal(b[off:off+n], 0)
+		}
+	}
+
+	name := h.Name
+	if format == FormatUSTAR {
+		if prefix, suffix, ok := splitUSTARPath(name); ok {
+			copy(b[offPrefix:offPrefix+155], prefix)
+			name = suffix
+		}
+	}
+	copy(b[offName:offName+100], name)
+	num(offMode, 8, h.Mode)
+	num(offUID, 8, int64(h.UID))
+	num(offGID, 8, int64(h.GID))
+	num(offSize, 12, h.Size)
+	num(offMtime, 12, unixTime(h))
+	b[offTypeflag] = h.Typeflag
+	if h.Typeflag == TypeRegA {
+		b[offTypeflag] = TypeReg
+	}
+	copy(b[offLinkname:offLinkname+100], h.Linkname)
+	if format == FormatGNU {
+		copy(b[offMagic:], magicGNU)
+	} else {
+		copy(b[offMagic:], magicUSTAR)
+	}
+	copy(b[offUname:offUname+32], h.Uname)
+	copy(b[offGname:offGname+32], h.Gname)
+	num(offDevmajor, 8, h.Devmajor)
+	num(offDevminor, 8, h.Devminor)
+
+	sum, _ := Checksum(b)
+	formatOctal(b[offChksum:offChksum+7], sum)
+	b[offChksum+7] = ' '
+	return b
+}
@@ -0,0 +1,230 @@
+package tiff
+
+import (
+	"io"
+	"sort"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+type encoder struct {
+	bw    *bio.Writer
+	order bio.Endian
+	big   bool
+}
+
+// Encode writes f to w. Offsets are recomputed; image data referenced by strip,
+// tile and thumbnail offsets is copied from the source the IFD was decoded from.
+func (f *File) Encode(w io.WriterAt) error {
+	e := &encoder{bw: bio.NewWriter(w), order: f.Order, big: f.BigTIFF}
+	bw := e.bw
+	if f.Order == bio.LittleEndian {
+		bw.WriteS16("II", bio.BigEndian)
+	} else {
+		bw.WriteS16("MM", bio.BigEndian)
+	}
+	if e.big {
+		bw.WriteU16(43, e.order)
+		bw.WriteU16(8, e.order)
+		bw.WriteU16(0, e.order)
+	} else {
+		bw.WriteU16(42, e.order)
+	}
+	nextPos := bw.GetOffset()
+	e.writeOffset(0)
+
+	for _, ifd := range f.IFDs {
+		off, np, err := e.writeIFD(ifd)
+		if err != nil {
+			return err
+		}
+		e.patch(nextPos, uint64(off))
+		nextPos = np
+	}
+	return bw.Flush()
+}
+
+func (e *encoder) writeOffset(v uint64) {
+	if e.big {
+		e.bw.WriteU64(v, e.order)
+	} else {
+		e.bw.WriteU32(uint32(v), e.order)
+	}
+}
+
+func (e *encoder) patch(pos int64, v uint64) {
+	end := e.bw.GetOffset()
+	e.bw.SetOffset(pos)
+	e.writeOffset(v)
+	e.bw.SetOffset(end)
+}
+
+func (e *encoder) align() {
+	if e.bw.GetOffset()&1 == 1 {
+		e.bw.WriteU8(0)
+	}
+}
+
+func (e *encoder) inline() int {
+	if e.big {
+		return 8
+	}
+	return 4
+}
+
+func (e *encoder) checkOffset(v uint64) error {
+	if !e.big && v > 0xFFFFFFFF {
+		return ErrFormat
+	}
+	return nil
+}
+
+// copyData copies the blobs referenced by an offsets field and returns their new offsets.
+func (e *encoder) copyData(ifd *IFD, offTag, cntTag uint16) ([]uint64, error) {
+	offsets, err := ifd.Field(offTag).Uints()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := ifd.Field(cntTag).Uints()
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) != len(offsets) {
+		return nil, ErrFormat
+	}
+	br := bio.NewReader(ifd.src)
+	buf := make([]byte, 64<<10)
+	out := make([]uint64, len(offsets))
+	for i, off := range offsets {
+		if !available(ifd.src, off, counts[i]) {
+			return nil, ErrFormat
+		}
+		e.align()
+		out[i] = uint64(e.bw.GetOffset())
+		if err := e.checkOffset(out[i]); err != nil {
+			return nil, err
+		}
+		// copy in pieces so the counts in the file never size an allocation
+		br.SetOffset(int64(off))
+		for n := counts[i]; n > 0; {
+			k := uint64(len(buf))
+			if n < k {
+				k = n
+			}
+			br.ReadU8s(buf[:k])
+			if br.Err() != nil {
+				return nil, br.Err()
+			}
+			e.bw.WriteRaw(buf[:k])
+			n -= k
+		}
+	}
+	return out, e.bw.Err()
+}
+
+// writeIFD writes the sub-IFDs, image data and out-of-line values of ifd followed by
+// the IFD itself, and returns its offset and the position of its next-IFD pointer.
+func (e *encoder) writeIFD(ifd *IFD) (int64, int64, error) {
+	fields := make(map[uint16]*Field, len(ifd.Fields))
+	for _, f := range ifd.Fields {
+		fields[f.Tag] = f
+	}
+
+	subTags := make([]int, 0, len(ifd.Sub))
+	for tag := range ifd.Sub {
+		subTags = append(subTags, int(tag))
+	}
+	sort.Ints(subTags)
+	for _, t := range subTags {
+		tag := uint16(t)
+		var offs []uint64
+		for _, sub := range ifd.Sub[tag] {
+			off, _, err := e.writeIFD(sub)
+			if err != nil {
+				return 0, 0, err
+			}
+			offs = append(offs, uint64(off))
+		}
+		fields[tag] = offsetsField(tag, offs, e.big)
+	}
+
+	if ifd.src != nil {
+		for _, offTag := range []uint16{TagStripOffsets, TagTileOffsets, TagJPEGInterchangeFormat} {
+			cntTag := dataTags[offTag]
+			if fields[offTag] == nil || fields[cntTag] == nil {
+				continue
+			}
+			if offTag == TagJPEGInterchangeFormat && fields[offTag].Count != 1 {
+				return 0, 0, ErrFormat
+			}
+			offs, err := e.copyData(ifd, offTag, cntTag)
+			if err != nil {
+				return 0, 0, err
+			}
+			f := offsetsField(offTag, offs, e.big && fields[offTag].Type == Long8)
+			if offTag == TagJPEGInterchangeFormat {
+				f = NewLongs(offTag, uint32(offs[0]))
+			}
+			fields[offTag] = f
+		}
+	}
+
+	tags := make([]int, 0, len(fields))
+	for tag := range fields {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+
+	inline := e.inline()
+	valueOffsets := make(map[uint16]uint64)
+	for _, t := range tags {
+		f := fields[uint16(t)]
+		if !e.big && (f.Type == Long8 || f.Type == SLong8 || f.Type == IFD8) {
+			return 0, 0, ErrType
+		}
+		// the raw value field of an unknown type cannot be converted
+		if f.Type.Size() == 0 && (len(f.Data) != inline || f.Order != e.order) {
+			return 0, 0, ErrType
+		}
+		if len(f.Data) <= inline {
+			continue
+		}
+		e.align()
+		valueOffsets[f.Tag] = uint64(e.bw.GetOffset())
+		if err := e.checkOffset(valueOffsets[f.Tag]); err != nil {
+			return 0, 0, err
+		}
+		e.bw.WriteRaw(f.withOrder(e.order))
+	}
+
+	e.align()
+	bw := e.bw
+	off := bw.GetOffset()
+	if err := e.checkOffset(uint64(off)); err != nil {
+		return 0, 0, err
+	}
+	if e.big {
+		bw.WriteU64(uint64(len(tags)), e.order)
+	} else {
+		bw.WriteU16(uint16(len(tags)), e.order)
+	}
+	for _, t := range tags {
+		f := fields[uint16(t)]
+		bw.WriteU16(f.Tag, e.order)
+		bw.WriteU16(uint16(f.Type), e.order)
+		if e.big {
+			bw.WriteU64(f.Count, e.order)
+		} else {
+			bw.WriteU32(uint32(f.Count), e.order)
+		}
+		if v, ok := valueOffsets[f.Tag]; ok {
+			e.writeOffset(v)
+		} else {
+			bw.WriteRaw(f.withOrder(e.order))
+			bw.WriteRaw(make([]byte, inline-len(f.Data)))
+		}
+	}
+	nextPos := bw.GetOffset()
+	e.writeOffset(0)
+	return off, nextPos, bw.Err()
+}
@@ -0,0 +1,274 @@
+package tiff
+
+import (
+	"bytes"
+	"math"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Type is a TIFF field type.
+type Type uint16
+
+// Field types, including the BigTIFF 64-bit types.
+const (
+	Byte      Type = 1
+	ASCII     Type = 2
+	Short     Type = 3
+	Long      Type = 4
+	Rational  Type = 5
+	SByte     Type = 6
+	Undefined Type = 7
+	SShort    Type = 8
+	SLong     Type = 9
+	SRational Type = 10
+	Float     Type = 11
+	Double    Type = 12
+	IFDType   Type = 13
+	Long8     Type = 16
+	SLong8    Type = 17
+	IFD8      Type = 18
+)
+
+// unitSize returns the size of the byte-order unit of t; rationals are two units.
+func (t Type) unitSize() int {
+	switch t {
+	case Byte, ASCII, SByte, Undefined:
+		return 1
+	case Short, SShort:
+		return 2
+	case Long, SLong, Float, IFDType, Rational, SRational:
+		return 4
+	case Double, Long8, SLong8, IFD8:
+		return 8
+	}
+	return 0
+}
+
+// Size returns the size in bytes of one value of type t, or 0 for unknown types.
+func (t Type) Size() int {
+	if t == Rational || t == SRational {
+		return 8
+	}
+	return t.unitSize()
+}
+
+// Rat is a RATIONAL or SRATIONAL value.
+type Rat struct {
+	Num int64
+	Den int64
+}
+
+// Float64 returns r as a float64.
+func (r Rat) Float64() float64 {
+	return float64(r.Num) / float64(r.Den)
+}
+
+// Field is an IFD entry. Data holds the values in Order byte order; for types
+// unknown to this package it holds the raw value field, written back unchanged.
+type Field struct {
+	Tag   uint16
+	Type  Type
+	Count uint64
+	Data  []byte
+	Order bio.Endian
+}
+
+func (f *Field) reader() *bio.Reader {
+	return bio.NewReader(bytes.NewReader(f.Data))
+}
+
+// Uints returns the values of an unsigned integer field.
+func (f *Field) Uints() ([]uint64, error) {
+	out := make([]uint64, f.Count)
+	r := f.reader()
+	for i := range out {
+		switch f.Type {
+		case Byte, Undefined:
+			out[i] = uint64(r.ReadU8())
+		case Short:
+			out[i] = uint64(r.ReadU16(f.Order))
+		case Long, IFDType:
+			out[i] = uint64(r.ReadU32(f.Order))
+		case Long8, IFD8:
+			out[i] = r.ReadU64(f.Order)
+		default:
+			return nil, ErrType
+		}
+	}
+	return out, r.Err()
+}
+
+// Ints returns the values of a signed or unsigned integer field.
+func (f *Field) Ints() ([]int64, error) {
+	out := make([]int64, f.Count)
+	r := f.reader()
+	for i := range out {
+		switch f.Type {
+		case SByte:
+			out[i] = int64(r.ReadI8())
+		case SShort:
+			out[i] = int64(r.ReadI16(f.Order))
+		case SLong:
+			out[i] = int64(r.ReadI32(f.Order))
+		case SLong8:
+			out[i] = r.ReadI64(f.Order)
+		default:
+			u, err := f.Uints()
+			if err != nil {
+				return nil, err
+			}
+			for i, v := range u {
+				out[i] = int64(v)
+			}
+			return out, nil
+		}
+	}
+	return out, r.Err()
+}
+
+// Rats returns the values of a RATIONAL or SRATIONAL field.
+func (f *Field) Rats() ([]Rat, error) {
+	if f.Type != Rational && f.Type != SRational {
+		return nil, ErrType
+	}
+	out := make([]Rat, f.Count)
+	r := f.reader()
+	for i := range out {
+		if f.Type == Rational {
+			out[i] = Rat{int64(r.ReadU32(f.Order)), int64(r.ReadU32(f.Order))}
+		} else {
+			out[i] = Rat{int64(r.ReadI32(f.Order)), int64(r.ReadI32(f.Order))}
+		}
+	}
+	return out, r.Err()
+}
+
+// Floats returns the values of any numeric field as float64.
+func (f *Field) Floats() ([]float64, error) {
+	out := make([]float64, f.Count)
+	switch f.Type {
+	case Float:
+		r := f.reader()
+		for i := range out {
+			out[i] = float64(r.ReadF32(f.Order))
+		}
+		return out, r.Err()
+	case Double:
+		r := f.reader()
+		r.ReadF64s(out, f.Order)
+		return out, r.Err()
+	case Rational, SRational:
+		rats, err := f.Rats()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range rats {
+			out[i] = v.Float64()
+		}
+		return out, nil
+	}
+	ints, err := f.Ints()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range ints {
+		out[i] = float64(v)
+	}
+	return out, nil
+}
+
+// String returns the value of an ASCII field without trailing NULs.
+func (f *Field) String() string {
+	return string(bytes.TrimRight(f.Data, "\x00"))
+}
+
+// withOrder returns a copy of f's data in byte order e.
+func (f *Field) withOrder(e bio.Endian) []byte {
+	size := f.Type.unitSize()
+	if f.Order == e || size <= 1 {
+		return f.Data
+	}
+	data := append([]byte(nil), f.Data...)
+	for i := 0; i+size <= len(data); i += size {
+		for a, b := i, i+size-1; a < b; a, b = a+1, b-1 {
+			data[a], data[b] = data[b], data[a]
+		}
+	}
+	return data
+}
+
+func newField(tag uint16, typ Type, count int, fill func(w *bio.Writer)) *Field {
+	buf := make(bio.Buffer, 0, count*typ.Size())
+	w := bio.NewWriter(&buf)
+	fill(w)
+	return &Field{Tag: tag, Type: typ, Count: uint64(count), Data: buf, Order: bio.LittleEndian}
+}
+
+// NewASCII returns an ASCII field holding s and a terminating NUL.
+func NewASCII(tag uint16, s string) *Field {
+	data := append([]byte(s), 0)
+	return &Field{Tag: tag, Type: ASCII, Count: uint64(len(data)), Data: data, Order: bio.LittleEndian}
+}
+
+// NewUndefined returns an UNDEFINED field holding b.
+func NewUndefined(tag uint16, b []byte) *Field {
+	return &Field{Tag: tag, Type: Undefined, Count: uint64(len(b)), Data: b, Order: bio.LittleEndian}
+}
+
+// NewShorts returns a SHORT field.
+func NewShorts(tag uint16, v ...uint16) *Field {
+	return newField(tag, Short, len(v), func(w *bio.Writer) { w.WriteU16s(v, bio.LittleEndian) })
+}
+
+// NewLongs returns a LONG field.
+func NewLongs(tag uint16, v ...uint32) *Field {
+	return newField(tag, Long, len(v), func(w *bio.Writer) { w.WriteU32s(v, bio.LittleEndian) })
+}
+
+// NewLong8s returns a BigTIFF LONG8 field.
+func NewLong8s(tag uint16, v ...uint64) *Field {
+	return newField(tag, Long8, len(v), func(w *bio.Writer) { w.WriteU64s(v, bio.LittleEndian) })
+}
+
+// NewDoubles returns a DOUBLE field.
+func NewDoubles(tag uint16, v ...float64) *Field {
+	return newField(tag, Double, len(v), func(w *bio.Writer) { w.WriteF64s(v, bio.LittleEndian) })
+}
+
+// NewRationals returns a RATIONAL field.
+func NewRationals(tag uint16, v ...Rat) *Field {
+	return newField(tag, Rational, len(v), func(w *bio.Writer) {
+		for _, r := range v {
+			w.WriteU32(uint32(r.Num), bio.LittleEndian)
+			w.WriteU32(uint32(r.Den), bio.LittleEndian)
+		}
+	})
+}
+
+// NewSRationals returns an SRATIONAL field.
+func NewSRationals(tag uint16, v ...Rat) *Field {
+	return newField(tag, SRational, len(v), func(w *bio.Writer) {
+		for _, r := range v {
+			w.WriteI32(int32(r.Num), bio.LittleEndian)
+			w.WriteI32(int32(r.Den), bio.LittleEndian)
+		}
+	})
+}
+
+// offsetsField builds a LONG or LONG8 field for offsets written by Encode.
+func offsetsField(tag uint16, offsets []uint64, big bool) *Field {
+	for _, v := range offsets {
+		if v > math.MaxUint32 {
+			big = true
+		}
+	}
+	if big {
+		return NewLong8s(tag, offsets...)
+	}
+	v32 := make([]uint32, len(offsets))
+	for i, v := range offsets {
+		v32[i] = uint32(v)
+	}
+	return NewLongs(tag, v32...)
+}
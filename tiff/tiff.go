@@ -0,0 +1,283 @@
+// Package tiff decodes and encodes TIFF and BigTIFF image file directories,
+// including the EXIF, GPS and Interoperability sub-IFDs found in JPEG APP1 segments.
+package tiff
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the tiff package.
+var (
+	ErrFormat = errors.New("tiff: invalid format")
+	ErrType   = errors.New("tiff: unexpected field type")
+	ErrLoop   = errors.New("tiff: IFD loop")
+)
+
+// Common tags.
+const (
+	TagImageWidth                  = 0x0100
+	TagImageLength                 = 0x0101
+	TagMake                        = 0x010F
+	TagModel                       = 0x0110
+	TagStripOffsets                = 0x0111
+	TagOrientation                 = 0x0112
+	TagStripByteCounts             = 0x0117
+	TagSoftware                    = 0x0131
+	TagDateTime                    = 0x0132
+	TagTileOffsets                 = 0x0144
+	TagTileByteCounts              = 0x0145
+	TagSubIFDs                     = 0x014A
+	TagJPEGInterchangeFormat       = 0x0201
+	TagJPEGInterchangeFormatLength = 0x0202
+	TagExifIFD                     = 0x8769
+	TagGPSIFD                      = 0x8825
+	TagInteropIFD                  = 0xA005
+)
+
+// subIFDTags are the tags whose values point to sub-IFDs.
+var subIFDTags = map[uint16]bool{
+	TagSubIFDs:    true,
+	TagExifIFD:    true,
+	TagGPSIFD:     true,
+	TagInteropIFD: true,
+}
+
+// dataTags maps tags pointing at image data to the tags holding their lengths.
+var dataTags = map[uint16]uint16{
+	TagStripOffsets:          TagStripByteCounts,
+	TagTileOffsets:           TagTileByteCounts,
+	TagJPEGInterchangeFormat: TagJPEGInterchangeFormatLength,
+}
+
+// IFD is an image file directory. Sub holds the sub-IFDs referenced by pointer tags.
+type IFD struct {
+	Offset int64
+	Fields []*Field
+	Sub    map[uint16][]*IFD
+	src    io.ReaderAt
+}
+
+// Field returns the field with the given tag, or nil.
+func (ifd *IFD) Field(tag uint16) *Field {
+	for _, f := range ifd.Fields {
+		if f.Tag == tag {
+			return f
+		}
+	}
+	return nil
+}
+
+// Set adds f, replacing any field with the same tag.
+func (ifd *IFD) Set(f *Field) {
+	for i, g := range ifd.Fields {
+		if g.Tag == f.Tag {
+			ifd.Fields[i] = f
+			return
+		}
+	}
+	ifd.Fields = append(ifd.Fields, f)
+	sort.Slice(ifd.Fields, func(i, j int) bool { return ifd.Fields[i].Tag < ifd.Fields[j].Tag })
+}
+
+// Remove deletes the field with the given tag and any sub-IFDs it points to.
+func (ifd *IFD) Remove(tag uint16) {
+	for i, f := range ifd.Fields {
+		if f.Tag == tag {
+			ifd.Fields = append(ifd.Fields[:i], ifd.Fields[i+1:]...)
+			break
+		}
+	}
+	delete(ifd.Sub, tag)
+}
+
+// File is a decoded TIFF structure. IFDs is the main IFD chain.
+type File struct {
+	Order   bio.Endian
+	BigTIFF bool
+	IFDs    []*IFD
+}
+
+type decoder struct {
+	src     io.ReaderAt
+	br      *bio.Reader
+	order   bio.Endian
+	big     bool
+	visited map[int64]bool
+}
+
+// Decode parses the header and all IFDs of r, following the IFD chain and sub-IFDs.
+func Decode(r io.ReaderAt) (*File, error) {
+	br := bio.NewReader(r)
+	var order bio.Endian
+	switch br.ReadS16(bio.BigEndian) {
+	case "II":
+		order = bio.LittleEndian
+	case "MM":
+		order = bio.BigEndian
+	default:
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+
+	d := &decoder{src: r, br: br, order: order, visited: make(map[int64]bool)}
+	var next uint64
+	switch br.ReadU16(order) {
+	case 42:
+		next = uint64(br.ReadU32(order))
+	case 43:
+		d.big = true
+		if br.ReadU16(order) != 8 || br.ReadU16(order) != 0 {
+			return nil, ErrFormat
+		}
+		next = br.ReadU64(order)
+	default:
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	f := &File{Order: order, BigTIFF: d.big}
+	for next != 0 {
+		ifd, n, err := d.readIFD(int64(next))
+		if err != nil {
+			return nil, err
+		}
+		f.IFDs = append(f.IFDs, ifd)
+		next = n
+	}
+	return f, nil
+}
+
+func (d *decoder) readIFD(off int64) (*IFD, uint64, error) {
+	if d.visited[off] {
+		return nil, 0, ErrLoop
+	}
+	d.visited[off] = true
+
+	br := d.br
+	br.SetOffset(off)
+	var n uint64
+	if d.big {
+		n = br.ReadU64(d.order)
+	} else {
+		n = uint64(br.ReadU16(d.order))
+	}
+	if br.Err() != nil {
+		return nil, 0, br.Err()
+	}
+	if n > 1<<16 {
+		return nil, 0, ErrFormat
+	}
+
+	ifd := &IFD{Offset: off, src: d.src}
+	for i := uint64(0); i < n; i++ {
+		f, err := d.readField()
+		if err != nil {
+			return nil, 0, err
+		}
+		ifd.Fields = append(ifd.Fields, f)
+	}
+	var next uint64
+	if d.big {
+		next = br.ReadU64(d.order)
+	} else {
+		next = uint64(br.ReadU32(d.order))
+	}
+	if br.Err() != nil {
+		return nil, 0, br.Err()
+	}
+
+	for _, f := range ifd.Fields {
+		if !subIFDTags[f.Tag] {
+			continue
+		}
+		offsets, err := f.Uints()
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, o := range offsets {
+			sub, _, err := d.readIFD(int64(o))
+			if err != nil {
+				return nil, 0, err
+			}
+			if ifd.Sub == nil {
+				ifd.Sub = make(map[uint16][]*IFD)
+			}
+			ifd.Sub[f.Tag] = append(ifd.Sub[f.Tag], sub)
+		}
+	}
+	return ifd, next, nil
+}
+
+func (d *decoder) readField() (*Field, error) {
+	br := d.br
+	f := &Field{Order: d.order}
+	f.Tag = br.ReadU16(d.order)
+	f.Type = Type(br.ReadU16(d.order))
+	inline := uint64(4)
+	if d.big {
+		f.Count = br.ReadU64(d.order)
+		inline = 8
+	} else {
+		f.Count = uint64(br.ReadU32(d.order))
+	}
+	valuePos := br.GetOffset()
+	br.SetOffset(valuePos + int64(inline))
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	vr := bio.NewReader(d.src)
+	vr.SetOffset(valuePos)
+	size := uint64(f.Type.Size())
+	if size == 0 {
+		// unknown type, keep the raw count and value field
+		f.Data = vr.ReadRaw(inline)
+		return f, vr.Err()
+	}
+	if f.Count > 1<<30/size {
+		return nil, ErrFormat
+	}
+	total := f.Count * size
+	if total > inline {
+		var off uint64
+		if d.big {
+			off = vr.ReadU64(d.order)
+		} else {
+			off = uint64(vr.ReadU32(d.order))
+		}
+		if vr.Err() == nil && !available(d.src, off, total) {
+			return nil, ErrFormat
+		}
+		vr.SetOffset(int64(off))
+	}
+	f.Data = vr.ReadRaw(total)
+	if vr.Err() != nil {
+		return nil, vr.Err()
+	}
+	return f, nil
+}
+
+// available reports whether n bytes exist at off in src, so that sizes read
+// from the file can be checked before allocating for them.
+func available(src io.ReaderAt, off, n uint64) bool {
+	if off > math.MaxInt64-n {
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+	k, _ := src.ReadAt(make([]byte, 1), int64(off+n-1))
+	return k == 1
+}
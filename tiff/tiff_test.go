@@ -0,0 +1,190 @@
+package tiff
+
+import (
+	"bytes"
+	"testing"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+func encode(t *testing.T, f *File) []byte {
+	var buf bio.Buffer
+	if err := f.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func decode(t *testing.T, b []byte) *File {
+	f, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func exifFile(order bio.Endian) *File {
+	gps := &IFD{}
+	gps.Set(NewRationals(0x0002, Rat{35, 1}, Rat{41, 1}, Rat{2283, 100}))
+	gps.Set(NewASCII(0x0001, "N"))
+
+	interop := &IFD{}
+	interop.Set(NewASCII(0x0001, "R98"))
+
+	exif := &IFD{Sub: map[uint16][]*IFD{TagInteropIFD: {interop}}}
+	exif.Set(NewASCII(0x9003, "2020:09:07 12:00:00"))
+	exif.Set(NewSRationals(0x9204, Rat{-1, 3}))
+	exif.Set(NewUndefined(0x9000, []byte("0231")))
+
+	ifd0 := &IFD{Sub: map[uint16][]*IFD{TagExifIFD: {exif}, TagGPSIFD: {gps}}}
+	ifd0.Set(NewASCII(TagMake, "binaryio"))
+	ifd0.Set(NewShorts(TagOrientation, 6))
+	ifd0.Set(NewRationals(0x011A, Rat{72, 1}))
+	ifd0.Set(NewDoubles(0xC000, 1.5, -2.25))
+	ifd0.Set(newField(0xC001, Float, 1, func(w *bio.Writer) { w.WriteF32(0.5, bio.LittleEndian) }))
+	ifd0.Set(newField(0xC002, SShort, 2, func(w *bio.Writer) { w.WriteI16s([]int16{-1, 2}, bio.LittleEndian) }))
+	ifd0.Set(newField(0xC003, SByte, 1, func(w *bio.Writer) { w.WriteI8(-5) }))
+
+	ifd1 := &IFD{}
+	ifd1.Set(NewLongs(TagImageWidth, 160))
+
+	return &File{Order: order, IFDs: []*IFD{ifd0, ifd1}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, big := range []bool{false, true} {
+		for _, order := range []bio.Endian{bio.LittleEndian, bio.BigEndian} {
+			src := exifFile(order)
+			src.BigTIFF = big
+			f := decode(t, encode(t, src))
+			if f.Order != order || f.BigTIFF != big || len(f.IFDs) != 2 {
+				t.Fatalf("Invalid header %v %v %d", f.Order, f.BigTIFF, len(f.IFDs))
+			}
+			ifd0 := f.IFDs[0]
+			if s := ifd0.Field(TagMake).String(); s != "binaryio" {
+				t.Fatalf("Invalid ASCII %q", s)
+			}
+			if v, err := ifd0.Field(TagOrientation).Uints(); err != nil || v[0] != 6 {
+				t.Fatalf("Invalid SHORT %v %v", v, err)
+			}
+			if v, err := ifd0.Field(0x011A).Rats(); err != nil || v[0] != (Rat{72, 1}) {
+				t.Fatalf("Invalid RATIONAL %v %v", v, err)
+			}
+			if v, err := ifd0.Field(0xC000).Floats(); err != nil || v[0] != 1.5 || v[1] != -2.25 {
+				t.Fatalf("Invalid DOUBLE %v %v", v, err)
+			}
+			if v, err := ifd0.Field(0xC001).Floats(); err != nil || v[0] != 0.5 {
+				t.Fatalf("Invalid FLOAT %v %v", v, err)
+			}
+			if v, err := ifd0.Field(0xC002).Ints(); err != nil || v[0] != -1 || v[1] != 2 {
+				t.Fatalf("Invalid SSHORT %v %v", v, err)
+			}
+			if v, err := ifd0.Field(0xC003).Ints(); err != nil || v[0] != -5 {
+				t.Fatalf("Invalid SBYTE %v %v", v, err)
+			}
+			if _, err := ifd0.Field(TagMake).Uints(); err != ErrType {
+				t.Fatalf("Invalid type error %v", err)
+			}
+
+			exif := ifd0.Sub[TagExifIFD][0]
+			if v, err := exif.Field(0x9204).Floats(); err != nil || v[0] != -1.0/3 {
+				t.Fatalf("Invalid SRATIONAL %v %v", v, err)
+			}
+			if s := exif.Sub[TagInteropIFD][0].Field(0x0001).String(); s != "R98" {
+				t.Fatalf("Invalid Interop %q", s)
+			}
+			gps := ifd0.Sub[TagGPSIFD][0]
+			if v, err := gps.Field(0x0002).Floats(); err != nil || v[2] != 22.83 {
+				t.Fatalf("Invalid GPS %v %v", v, err)
+			}
+			if v, _ := f.IFDs[1].Field(TagImageWidth).Uints(); v[0] != 160 {
+				t.Fatalf("Invalid IFD1 %v", v)
+			}
+
+			// strip GPS and write back in the other byte order
+			ifd0.Remove(TagGPSIFD)
+			f.Order = 1 - f.Order
+			g := decode(t, encode(t, f))
+			if g.IFDs[0].Field(TagGPSIFD) != nil || g.IFDs[0].Sub[TagGPSIFD] != nil {
+				t.Fatalf("GPS not removed")
+			}
+			if s := g.IFDs[0].Sub[TagExifIFD][0].Field(0x9003).String(); s != "2020:09:07 12:00:00" {
+				t.Fatalf("Invalid EXIF after rewrite %q", s)
+			}
+		}
+	}
+}
+
+func TestStrips(t *testing.T) {
+	// classic little-endian file with one strip at offset 8
+	var buf bio.Buffer
+	w := bio.NewWriter(&buf)
+	w.WriteS16("II", bio.BigEndian)
+	w.WriteU16(42, bio.LittleEndian)
+	w.WriteU32(14, bio.LittleEndian)
+	w.WriteRaw([]byte("PIXELS"))
+	w.WriteU16(2, bio.LittleEndian)
+	w.WriteU16(TagStripOffsets, bio.LittleEndian)
+	w.WriteU16(uint16(Long), bio.LittleEndian)
+	w.WriteU32(1, bio.LittleEndian)
+	w.WriteU32(8, bio.LittleEndian)
+	w.WriteU16(TagStripByteCounts, bio.LittleEndian)
+	w.WriteU16(uint16(Short), bio.LittleEndian)
+	w.WriteU32(1, bio.LittleEndian)
+	w.WriteU32(6, bio.LittleEndian)
+	w.WriteU32(0, bio.LittleEndian)
+
+	f := decode(t, buf)
+	f.BigTIFF = true
+	f.Order = bio.BigEndian
+	out := encode(t, f)
+	g := decode(t, out)
+	offs, err := g.IFDs[0].Field(TagStripOffsets).Uints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[offs[0]:offs[0]+6], []byte("PIXELS")) {
+		t.Fatalf("Invalid strip data")
+	}
+
+	// next IFD pointing at itself
+	loop := append([]byte(nil), buf...)
+	loop[len(loop)-4] = 14
+	if _, err := Decode(bytes.NewReader(loop)); err != ErrLoop {
+		t.Fatalf("Invalid loop error %v", err)
+	}
+	if _, err := Decode(bytes.NewReader([]byte("XX*\x00"))); err != ErrFormat {
+		t.Fatalf("Invalid format error %v", err)
+	}
+
+	// strip byte count past the end of the source
+	short := append([]byte(nil), buf...)
+	short[36] = 0xFF
+	if err := decode(t, short).Encode(&bio.Buffer{}); err != ErrFormat {
+		t.Fatalf("Invalid strip count error %v", err)
+	}
+	// out-of-line offsets past the end of the source
+	short = append([]byte(nil), buf...)
+	short[20] = 0xFF
+	if _, err := Decode(bytes.NewReader(short)); err != ErrFormat {
+		t.Fatalf("Invalid field count error %v", err)
+	}
+	// unknown types keep their type, count and value field
+	f = decode(t, buf)
+	f.IFDs[0].Set(&Field{Tag: 0xC000, Type: 99, Count: 0xFFFFFFFF, Data: []byte{1, 2, 3, 4}, Order: bio.LittleEndian})
+	u := decode(t, encode(t, f)).IFDs[0].Field(0xC000)
+	if u == nil || u.Type != 99 || u.Count != 0xFFFFFFFF || !bytes.Equal(u.Data, []byte{1, 2, 3, 4}) {
+		t.Fatalf("Invalid unknown type field %+v", u)
+	}
+	f.BigTIFF = true
+	if err := f.Encode(&bio.Buffer{}); err != ErrType {
+		t.Fatalf("Invalid unknown type conversion error %v", err)
+	}
+	// JPEGInterchangeFormat without an offset
+	f = decode(t, buf)
+	f.IFDs[0].Set(NewLongs(TagJPEGInterchangeFormat))
+	f.IFDs[0].Set(NewLongs(TagJPEGInterchangeFormatLength))
+	if err := f.Encode(&bio.Buffer{}); err != ErrFormat {
+		t.Fatalf("Invalid JPEG offset error %v", err)
+	}
+}
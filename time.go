@@ -0,0 +1,136 @@
+package binaryio
+
+import (
+	"math"
+	"time"
+)
+
+// TimeKind selects the on-disk encoding used by ReadTime and WriteTime.
+type TimeKind int
+
+// Supported time encodings.
+const (
+	TimeUnix32   TimeKind = iota // signed 32-bit seconds since 1970
+	TimeUnix64                   // signed 64-bit seconds since 1970
+	TimeNTP64                    // unsigned 32.32 fixed-point seconds since 1900
+	TimeHFS                      // unsigned 32-bit seconds since 1904
+	TimeMP4V0                    // ISO-BMFF version 0 creation_time: unsigned 32-bit seconds since 1904
+	TimeMP4V1                    // ISO-BMFF version 1 creation_time: unsigned 64-bit seconds since 1904
+	TimeFILETIME                 // Windows FILETIME: unsigned 64-bit 100ns ticks since 1601
+	TimeDOS                      // MS-DOS time word followed by date word, 2s resolution, 1980-2107
+)
+
+// Seconds from each epoch to the Unix epoch.
+const (
+	ntpEpochOffset      = 2208988800
+	hfsEpochOffset      = 2082844800
+	filetimeEpochOffset = 11644473600
+)
+
+// ReadTime reads a timestamp of the given kind. Results are in UTC; DOS times
+// carry no zone and are returned as UTC wall clock values.
+func (br *Reader) ReadTime(kind TimeKind, e Endian) time.Time {
+	if br.err != nil {
+		return time.Time{}
+	}
+
+	var t time.Time
+	switch kind {
+	case TimeUnix32:
+		t = time.Unix(int64(br.ReadI32(e)), 0)
+	case TimeUnix64:
+		t = time.Unix(br.ReadI64(e), 0)
+	case TimeNTP64:
+		v := br.ReadU64(e)
+		ns := (v & 0xFFFFFFFF) * 1e9 >> 32
+		t = time.Unix(int64(v>>32)-ntpEpochOffset, int64(ns))
+	case TimeHFS, TimeMP4V0:
+		t = time.Unix(int64(br.ReadU32(e))-hfsEpochOffset, 0)
+	case TimeMP4V1:
+		v := br.ReadU64(e)
+		if v > math.MaxInt64 {
+			br.setErr(ErrOverflow)
+			return time.Time{}
+		}
+		t = time.Unix(int64(v)-hfsEpochOffset, 0)
+	case TimeFILETIME:
+		v := br.ReadU64(e)
+		t = time.Unix(int64(v/1e7)-filetimeEpochOffset, int64(v%1e7)*100)
+	case TimeDOS:
+		tm := br.ReadU16(e)
+		d := br.ReadU16(e)
+		sec, minute, hour := int(tm&0x1F)*2, int(tm>>5&0x3F), int(tm>>11)
+		day, month, year := int(d&0x1F), int(d>>5&0x0F), int(d>>9)+1980
+		t = time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.UTC)
+		// time.Date normalizes out-of-range fields such as Feb 30
+		if t.Day() != day || int(t.Month()) != month || hour > 23 || minute > 59 || sec > 59 {
+			if br.err == nil {
+				br.setErr(ErrInvalidTime)
+			}
+			return time.Time{}
+		}
+	default:
+		br.setErr(ErrInvalidTime)
+		return time.Time{}
+	}
+
+	if br.err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// WriteTime writes t using the given kind. Times that cannot be represented
+// are reported as ErrOverflow. DOS times use the wall clock of t's location.
+func (bw *Writer) WriteTime(t time.Time, kind TimeKind, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+
+	s := t.Unix()
+	ns := uint64(t.Nanosecond())
+	switch kind {
+	case TimeUnix32:
+		if s < math.MinInt32 || s > math.MaxInt32 {
+			break
+		}
+		return bw.WriteI32(int32(s), e)
+	case TimeUnix64:
+		return bw.WriteI64(s, e)
+	case TimeNTP64:
+		if s < -ntpEpochOffset || s >= 1<<32-ntpEpochOffset {
+			break
+		}
+		return bw.WriteU64(uint64(s+ntpEpochOffset)<<32|ns<<32/1e9, e)
+	case TimeHFS, TimeMP4V0:
+		if s < -hfsEpochOffset || s >= 1<<32-hfsEpochOffset {
+			break
+		}
+		return bw.WriteU32(uint32(s+hfsEpochOffset), e)
+	case TimeMP4V1:
+		if s < -hfsEpochOffset {
+			break
+		}
+		return bw.WriteU64(uint64(s+hfsEpochOffset), e)
+	case TimeFILETIME:
+		if s < -filetimeEpochOffset || s > math.MaxInt64/10000000-filetimeEpochOffset {
+			break
+		}
+		return bw.WriteU64(uint64(s+filetimeEpochOffset)*1e7+ns/100, e)
+	case TimeDOS:
+		year, month, day := t.Date()
+		hour, minute, sec := t.Clock()
+		if year < 1980 || year > 2107 {
+			break
+		}
+		tm := uint16(hour<<11 | minute<<5 | sec/2)
+		d := uint16((year-1980)<<9 | int(month)<<5 | day)
+		return bw.WriteU16(tm, e) + bw.WriteU16(d, e)
+	default:
+		bw.setErr(ErrInvalidTime)
+		return 0
+	}
+
+	bw.setErr(ErrOverflow)
+	return 0
+}
@@ -0,0 +1,104 @@
+package binaryio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	tm := time.Date(2020, 9, 7, 12, 34, 56, 500000000, time.UTC)
+
+	// -----------------------------
+	// Round trip
+	// -----------------------------
+	tests := []struct {
+		kind TimeKind
+		size int
+		want time.Time
+	}{
+		{TimeUnix32, 4, tm.Truncate(time.Second)},
+		{TimeUnix64, 8, tm.Truncate(time.Second)},
+		{TimeNTP64, 8, tm},
+		{TimeHFS, 4, tm.Truncate(time.Second)},
+		{TimeMP4V0, 4, tm.Truncate(time.Second)},
+		{TimeMP4V1, 8, tm.Truncate(time.Second)},
+		{TimeFILETIME, 8, tm},
+		{TimeDOS, 4, tm.Truncate(2 * time.Second)},
+	}
+	for _, tt := range tests {
+		for _, e := range []Endian{LittleEndian, BigEndian} {
+			testFileName := "test.bin"
+			fw := openWriteFile(testFileName, t)
+			w := NewWriter(fw)
+			if n := w.WriteTime(tm, tt.kind, e); n != tt.size || w.Err() != nil {
+				t.Fatalf("Invalid WriteTime kind %d: %d %v", tt.kind, n, w.Err())
+			}
+			fw.Close()
+
+			fr := openReadFile(testFileName, t)
+			r := NewReader(fr)
+			if v := r.ReadTime(tt.kind, e); !v.Equal(tt.want) || r.Err() != nil {
+				t.Fatalf("Invalid ReadTime kind %d: %v %v", tt.kind, v, r.Err())
+			}
+			fr.Close()
+			removeFile(testFileName, t)
+		}
+	}
+
+	// -----------------------------
+	// Known encodings
+	// -----------------------------
+	{
+		r := NewReader(bytes.NewReader([]byte{
+			0x00, 0x80, 0x3E, 0xD5, 0xDE, 0xB1, 0x9D, 0x01, // FILETIME 1970-01-01
+			0x83, 0xAA, 0x7E, 0x80, 0x00, 0x00, 0x00, 0x00, // NTP 1970-01-01
+			0x7C, 0x25, 0xB0, 0x80, // HFS 1970-01-01
+			0x00, 0x00, 0x21, 0x00, // DOS 1980-01-01 00:00:00
+			0x00, 0x00, 0x00, 0x00, // DOS with month 0
+		}))
+		epoch := time.Unix(0, 0)
+		if v := r.ReadTime(TimeFILETIME, LittleEndian); !v.Equal(epoch) {
+			t.Fatalf("Invalid FILETIME %v", v)
+		}
+		if v := r.ReadTime(TimeNTP64, BigEndian); !v.Equal(epoch) {
+			t.Fatalf("Invalid NTP64 %v", v)
+		}
+		if v := r.ReadTime(TimeHFS, BigEndian); !v.Equal(epoch) {
+			t.Fatalf("Invalid HFS %v", v)
+		}
+		if v := r.ReadTime(TimeDOS, LittleEndian); !v.Equal(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("Invalid DOS %v", v)
+		}
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		r.ReadTime(TimeDOS, LittleEndian)
+		if r.Err() != ErrInvalidTime {
+			t.Fatalf("Invalid DOS error %v", r.Err())
+		}
+		r = NewReader(bytes.NewReader([]byte{0x00, 0x00, 0x5E, 0x00})) // DOS 1980-02-30
+		r.ReadTime(TimeDOS, LittleEndian)
+		if r.Err() != ErrInvalidTime {
+			t.Fatalf("DOS Feb 30 must fail %v", r.Err())
+		}
+	}
+
+	// -----------------------------
+	// Range checks
+	// -----------------------------
+	{
+		w := NewWriter(failWriterAt{})
+		if w.WriteTime(time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC), TimeUnix32, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("Unix32 must overflow after 2038")
+		}
+		w = NewWriter(failWriterAt{})
+		if w.WriteTime(time.Date(1979, 1, 1, 0, 0, 0, 0, time.UTC), TimeDOS, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("DOS must overflow before 1980")
+		}
+		w = NewWriter(failWriterAt{})
+		if w.WriteTime(time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), TimeHFS, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("HFS must overflow before 1904")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package binaryio
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// UUID is a 128-bit identifier held in RFC 4122 (big-endian) byte order.
+type UUID [16]byte
+
+// ErrInvalidUUID is returned by ParseUUID for malformed input.
+var ErrInvalidUUID = errors.New("binaryio: invalid UUID")
+
+// ParseUUID parses the canonical xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form,
+// optionally enclosed in braces.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) == 38 && s[0] == '{' && s[37] == '}' {
+		s = s[1:37]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrInvalidUUID
+	}
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return u, ErrInvalidUUID
+	}
+	return u, nil
+}
+
+// String returns the canonical lower-case form of u.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:36], u[10:16])
+	return string(b[:])
+}
+
+// swapGUID converts between RFC 4122 and Microsoft GUID layouts, whose
+// first three fields are little-endian.
+func swapGUID(u UUID) UUID {
+	u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+	u[4], u[5] = u[5], u[4]
+	u[6], u[7] = u[7], u[6]
+	return u
+}
+
+// ReadUUID reads a UUID stored in RFC 4122 byte order.
+func (br *Reader) ReadUUID() UUID {
+	var u UUID
+	if br.err != nil {
+		return u
+	}
+	br.readInto(u[:])
+	if br.err != nil {
+		return UUID{}
+	}
+	return u
+}
+
+// ReadGUID reads a GUID stored in the Microsoft mixed-endian layout (GPT, COM)
+// and returns it in RFC 4122 byte order.
+func (br *Reader) ReadGUID() UUID {
+	if br.err != nil {
+		return UUID{}
+	}
+	return swapGUID(br.ReadUUID())
+}
+
+// WriteUUID writes u in RFC 4122 byte order.
+func (bw *Writer) WriteUUID(u UUID) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.writeBytes(u[:])
+}
+
+// WriteGUID writes u in the Microsoft mixed-endian layout.
+func (bw *Writer) WriteGUID(u UUID) int {
+	if bw.err != nil {
+		return 0
+	}
+	g := swapGUID(u)
+	return bw.writeBytes(g[:])
+}
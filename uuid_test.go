@@ -0,0 +1,74 @@
+package binaryio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	// EFI System Partition type GUID as stored on disk.
+	esp := []byte{
+		0x28, 0x73, 0x2A, 0xC1, 0x1F, 0xF8, 0xD2, 0x11,
+		0xBA, 0x4B, 0x00, 0xA0, 0xC9, 0x3E, 0xC9, 0x3B,
+	}
+	{
+		r := NewReader(bytes.NewReader(append(esp, esp...)))
+		g := r.ReadGUID()
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		if g.String() != "c12a7328-f81f-11d2-ba4b-00a0c93ec93b" {
+			t.Fatalf("Invalid ReadGUID %s", g)
+		}
+		u := r.ReadUUID()
+		if u.String() != "28732ac1-1ff8-d211-ba4b-00a0c93ec93b" {
+			t.Fatalf("Invalid ReadUUID %s", u)
+		}
+		r.ReadUUID()
+		if r.Err() == nil {
+			t.Fatalf("ReadUUID past end must fail")
+		}
+	}
+	{
+		u, err := ParseUUID("{C12A7328-F81F-11D2-BA4B-00A0C93EC93B}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseUUID("c12a7328f81f11d2ba4b00a0c93ec93b"); err != ErrInvalidUUID {
+			t.Fatalf("Invalid ParseUUID error %v", err)
+		}
+
+		testFileName := "test.bin"
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw)
+		n := w.WriteGUID(u)
+		n += w.WriteUUID(u)
+		n += w.WriteU128(U128{Hi: 1, Lo: 2}, LittleEndian)
+		n += w.WriteU128(U128{Hi: 1, Lo: 2}, BigEndian)
+		if w.Err() != nil {
+			t.Fatal(w.Err())
+		}
+		if n != 64 {
+			t.Fatalf("Invalid write size %d", n)
+		}
+		fw.Close()
+
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		if !bytes.Equal(r.ReadRaw(16), esp) {
+			t.Fatalf("Invalid WriteGUID")
+		}
+		if r.ReadUUID() != u {
+			t.Fatalf("Invalid WriteUUID")
+		}
+		if v := r.ReadU128(LittleEndian); v != (U128{Hi: 1, Lo: 2}) {
+			t.Fatalf("Invalid ReadU128 %v", v)
+		}
+		if v := r.ReadU128Big(BigEndian); v.String() != "18446744073709551618" {
+			t.Fatalf("Invalid ReadU128Big %s", v)
+		}
+		fr.Close()
+
+		removeFile(testFileName, t)
+	}
+}
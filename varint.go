@@ -0,0 +1,49 @@
+package binaryio
+
+import "encoding/binary"
+
+// ReadUvarint reads an unsigned LEB128 varint as used by protobuf and
+// encoding/binary. Encodings longer than 10 bytes or exceeding 64 bits are
+// reported as ErrOverflow.
+func (br *Reader) ReadUvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	var v uint64
+	for i := uint(0); i < binary.MaxVarintLen64; i++ {
+		b := br.ReadU8()
+		if br.err != nil {
+			return 0
+		}
+		if i == binary.MaxVarintLen64-1 && b > 1 {
+			break
+		}
+		v |= uint64(b&0x7F) << (7 * i)
+		if b < 0x80 {
+			return v
+		}
+	}
+	br.setErr(ErrOverflow)
+	return 0
+}
+
+// ReadVarint reads a zigzag-encoded signed varint.
+func (br *Reader) ReadVarint() int64 {
+	u := br.ReadUvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// WriteUvarint writes v as an unsigned LEB128 varint.
+func (bw *Writer) WriteUvarint(v uint64) int {
+	if bw.err != nil {
+		return 0
+	}
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return bw.writeBytes(buf[:n])
+}
+
+// WriteVarint writes v as a zigzag-encoded signed varint.
+func (bw *Writer) WriteVarint(v int64) int {
+	return bw.WriteUvarint(uint64(v<<1) ^ uint64(v>>63))
+}
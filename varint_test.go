@@ -0,0 +1,70 @@
+package binaryio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	testFileName := "test.bin"
+
+	uvalues := []uint64{0, 1, 127, 128, 300, 1<<32 - 1, 1 << 56, math.MaxUint64}
+	values := []int64{0, -1, 1, -64, 64, math.MinInt64, math.MaxInt64}
+
+	fw := openWriteFile(testFileName, t)
+	w := NewWriter(fw)
+	var want []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, v := range uvalues {
+		n := binary.PutUvarint(buf, v)
+		want = append(want, buf[:n]...)
+		if w.WriteUvarint(v) != n {
+			t.Fatalf("Invalid WriteUvarint length for %d", v)
+		}
+	}
+	for _, v := range values {
+		n := binary.PutVarint(buf, v)
+		want = append(want, buf[:n]...)
+		w.WriteVarint(v)
+	}
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	fw.Close()
+
+	fr := openReadFile(testFileName, t)
+	r := NewReader(fr)
+	got := r.ReadRaw(uint64(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Invalid varint encoding % x", got)
+	}
+	r.SetOffset(0)
+	for _, v := range uvalues {
+		if got := r.ReadUvarint(); got != v {
+			t.Fatalf("Invalid ReadUvarint %d != %d", got, v)
+		}
+	}
+	for _, v := range values {
+		if got := r.ReadVarint(); got != v {
+			t.Fatalf("Invalid ReadVarint %d != %d", got, v)
+		}
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+	fr.Close()
+	removeFile(testFileName, t)
+
+	// 11-byte encoding and a 10th byte carrying more than one bit overflow.
+	for _, b := range [][]byte{
+		bytes.Repeat([]byte{0x80}, 11),
+		append(bytes.Repeat([]byte{0xFF}, 9), 0x02),
+	} {
+		r := NewReader(bytes.NewReader(b))
+		if r.ReadUvarint(); r.Err() != ErrOverflow {
+			t.Fatalf("Invalid overflow error %v", r.Err())
+		}
+	}
+}
@@ -2,7 +2,10 @@ package binaryio
 
 import (
 	"fmt"
+	"hash"
 	"io"
+	"math"
+	"unsafe"
 )
 
 // Writer ...
@@ -15,10 +18,29 @@ type Writer struct {
 	b24    []byte
 	b32    []byte
 	b64    []byte
+	hash   hash.Hash
+	buf    []byte
+	bufOff int64
+	dst    []byte
+}
+
+// bytesDest is implemented by destinations whose contents can be written in
+// place, such as an MmapFile opened with OpenMmapRW.
+type bytesDest interface {
+	writableBytes() []byte
 }
 
 // NewWriter ...
-func NewWriter(w io.WriterAt) (br *Writer) {
+//
+// With WithBufferSize, contiguous writes are coalesced in memory and written out
+// when a non-contiguous write needs the buffer or on Flush. Writes that fall inside
+// the pending range (back-patching) update the buffer in place. Flush must be called
+// before the destination is closed; deferred write errors are reported by Flush and Err.
+//
+// Writes to a writable MmapFile are copied straight into the mapping and are
+// not buffered.
+func NewWriter(w io.WriterAt, opts ...Option) (br *Writer) {
+	o := applyOptions(opts)
 	br = &Writer{
 		w,               // io.WriterAt
 		0,               // offset
@@ -28,16 +50,118 @@ func NewWriter(w io.WriterAt) (br *Writer) {
 		make([]byte, 3), // b24
 		make([]byte, 4), // b32
 		make([]byte, 8), // b64
+		nil,             // hash
+		nil,             // buf
+		0,               // bufOff
+		nil,             // dst
+	}
+	if bd, ok := w.(bytesDest); ok && bd.writableBytes() != nil {
+		br.dst = bd.writableBytes()
+	} else if o.bufSize > 0 {
+		br.buf = make([]byte, 0, o.bufSize)
 	}
 	return br
 }
 
+func (bw *Writer) writeAt(p []byte) (int, error) {
+	if bw.dst != nil {
+		if bw.offset < 0 {
+			return 0, errNegativeOffset
+		}
+		if bw.offset >= int64(len(bw.dst)) {
+			return 0, io.ErrShortWrite
+		}
+		if n := copy(bw.dst[bw.offset:], p); n < len(p) {
+			return n, io.ErrShortWrite
+		}
+		return len(p), nil
+	}
+	if bw.buf == nil {
+		return bw.WriteAt(p, bw.offset)
+	}
+	if len(bw.buf) == 0 {
+		bw.bufOff = bw.offset
+	}
+	end := bw.bufOff + int64(len(bw.buf))
+	if bw.offset >= bw.bufOff && bw.offset+int64(len(p)) <= end {
+		copy(bw.buf[bw.offset-bw.bufOff:], p)
+		return len(p), nil
+	}
+	if bw.offset == end && len(bw.buf)+len(p) <= cap(bw.buf) {
+		bw.buf = append(bw.buf, p...)
+		return len(p), nil
+	}
+	if err := bw.flush(); err != nil {
+		return 0, err
+	}
+	if len(p) > cap(bw.buf) {
+		return bw.WriteAt(p, bw.offset)
+	}
+	bw.bufOff = bw.offset
+	bw.buf = append(bw.buf, p...)
+	return len(p), nil
+}
+
+func (bw *Writer) flush() error {
+	if len(bw.buf) == 0 {
+		return nil
+	}
+	n, err := bw.WriteAt(bw.buf, bw.bufOff)
+	if err == nil && n < len(bw.buf) {
+		err = io.ErrShortWrite
+	}
+	bw.buf = bw.buf[:0]
+	return err
+}
+
 func (bw *Writer) writeBytes(p []byte) (n int) {
-	n, bw.err = bw.WriteAt(p, bw.offset)
+	n, bw.err = bw.writeAt(p)
 	bw.offset += int64(n)
+	if bw.hash != nil {
+		bw.hash.Write(p[:n])
+	}
 	return n
 }
 
+func (bw *Writer) writeSlice(p unsafe.Pointer, n, size int, e Endian) int {
+	var tmp []byte
+	var written int
+	for n > 0 && bw.err == nil {
+		c := n
+		if c > maxView {
+			c = maxView
+		}
+		b := byteView(p, c)
+		if size > 1 && e != hostEndian {
+			if tmp == nil {
+				tmp = make([]byte, 64<<10)
+			}
+			for len(b) > 0 && bw.err == nil {
+				k := copy(tmp, b)
+				swapBytes(tmp[:k], size)
+				written += bw.writeBytes(tmp[:k])
+				b = b[k:]
+			}
+		} else {
+			written += bw.writeBytes(b)
+		}
+		n -= c
+		if n > 0 {
+			// only step while inside the slice; a past-the-end pointer is invalid
+			p = unsafe.Pointer(uintptr(p) + uintptr(c))
+		}
+	}
+	return written
+}
+
+// Flush writes any buffered data to the underlying io.WriterAt.
+func (bw *Writer) Flush() error {
+	if err := bw.flush(); err != nil && bw.err == nil {
+		bw.err = err
+	}
+	return bw.err
+}
+
 func (bw *Writer) setErr(err error) {
 	bw.err = err
 }
@@ -57,6 +181,31 @@ func (bw *Writer) SetOffset(offset int64) {
 	bw.offset = offset
 }
 
+// StartHash feeds every byte written from now on into h until SumHash is called.
+func (bw *Writer) StartHash(h hash.Hash) {
+	bw.hash = h
+}
+
+// SumHash stops hashing and returns the checksum of the bytes written since StartHash.
+func (bw *Writer) SumHash() []byte {
+	if bw.hash == nil {
+		return nil
+	}
+	h := bw.hash
+	bw.hash = nil
+	return h.Sum(nil)
+}
+
+// SumHash32 is like SumHash for hashes implementing hash.Hash32.
+func (bw *Writer) SumHash32() uint32 {
+	h, ok := bw.hash.(hash.Hash32)
+	bw.hash = nil
+	if !ok {
+		return 0
+	}
+	return h.Sum32()
+}
+
 // WriteRaw ...
 func (bw *Writer) WriteRaw(p []byte) int {
 	if bw.err != nil {
@@ -201,6 +350,97 @@ func (bw *Writer) WriteU64(v uint64, e Endian) int {
 	return bw.writeBytes(bw.b64)
 }
 
+// WriteUN writes v as an unsigned integer of nbytes (1..8) bytes.
+// Values that do not fit are reported as ErrOverflow.
+func (bw *Writer) WriteUN(v uint64, nbytes int, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	if nbytes < 1 || nbytes > 8 {
+		bw.setErr(ErrInvalidWidth)
+		return 0
+	}
+	if nbytes < 8 && v>>uint(8*nbytes) != 0 {
+		bw.setErr(ErrOverflow)
+		return 0
+	}
+
+	data := bw.b64[:nbytes]
+	for i := 0; i < nbytes; i++ {
+		if e == LittleEndian {
+			data[i] = byte(v >> uint(8*i))
+		} else {
+			data[nbytes-1-i] = byte(v >> uint(8*i))
+		}
+	}
+
+	return bw.writeBytes(data)
+}
+
+// WriteIN writes v as a two's complement integer of nbytes (1..8) bytes.
+// Values that do not fit are reported as ErrOverflow.
+func (bw *Writer) WriteIN(v int64, nbytes int, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	if nbytes < 1 || nbytes > 8 {
+		bw.setErr(ErrInvalidWidth)
+		return 0
+	}
+	shift := uint(64 - 8*nbytes)
+	if v<<shift>>shift != v {
+		bw.setErr(ErrOverflow)
+		return 0
+	}
+	return bw.WriteUN(uint64(v)<<shift>>shift, nbytes, e)
+}
+
+// WriteI40 ...
+func (bw *Writer) WriteI40(v int64, e Endian) int {
+	return bw.WriteIN(v, 5, e)
+}
+
+// WriteI48 ...
+func (bw *Writer) WriteI48(v int64, e Endian) int {
+	return bw.WriteIN(v, 6, e)
+}
+
+// WriteI56 ...
+func (bw *Writer) WriteI56(v int64, e Endian) int {
+	return bw.WriteIN(v, 7, e)
+}
+
+// WriteU40 ...
+func (bw *Writer) WriteU40(v uint64, e Endian) int {
+	return bw.WriteUN(v, 5, e)
+}
+
+// WriteU48 ...
+func (bw *Writer) WriteU48(v uint64, e Endian) int {
+	return bw.WriteUN(v, 6, e)
+}
+
+// WriteU56 ...
+func (bw *Writer) WriteU56(v uint64, e Endian) int {
+	return bw.WriteUN(v, 7, e)
+}
+
+// WriteF32 ...
+func (bw *Writer) WriteF32(v float32, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.WriteU32(math.Float32bits(v), e)
+}
+
+// WriteF64 ...
+func (bw *Writer) WriteF64(v float64, e Endian) int {
+	if bw.err != nil {
+		return 0
+	}
+	return bw.WriteU64(math.Float64bits(v), e)
+}
+
 // WriteS8 ...
 func (bw *Writer) WriteS8(s string) int {
 	if bw.err != nil {
@@ -265,6 +505,86 @@ func (bw *Writer) WriteS64(s string, e Endian) int {
 	return bw.WriteU64(v, e)
 }
 
+// WriteI8s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteI8s(v []int8) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*1, 1, LittleEndian)
+}
+
+// WriteI16s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteI16s(v []int16, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*2, 2, e)
+}
+
+// WriteI32s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteI32s(v []int32, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*4, 4, e)
+}
+
+// WriteI64s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteI64s(v []int64, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*8, 8, e)
+}
+
+// WriteU8s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteU8s(v []uint8) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*1, 1, LittleEndian)
+}
+
+// WriteU16s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteU16s(v []uint16, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*2, 2, e)
+}
+
+// WriteU32s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteU32s(v []uint32, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*4, 4, e)
+}
+
+// WriteU64s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteU64s(v []uint64, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*8, 8, e)
+}
+
+// WriteF32s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteF32s(v []float32, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*4, 4, e)
+}
+
+// WriteF64s writes all values of v using as few writes as possible.
+func (bw *Writer) WriteF64s(v []float64, e Endian) int {
+	if bw.err != nil || len(v) == 0 {
+		return 0
+	}
+	return bw.writeSlice(unsafe.Pointer(&v[0]), len(v)*8, 8, e)
+}
+
 // WriteX ...
 func (bw *Writer) WriteX(e Endian, chainData ...interface{}) int {
 
@@ -272,37 +592,25 @@ func (bw *Writer) WriteX(e Endian, chainData ...interface{}) int {
 	for _, d := range chainData {
 		switch v := d.(type) {
 		case []int8:
-			for _, x := range v {
-				n += bw.WriteI8(x)
-			}
+			n += bw.WriteI8s(v)
 		case []int16:
-			for _, x := range v {
-				n += bw.WriteI16(x, e)
-			}
+			n += bw.WriteI16s(v, e)
 		case []int32:
-			for _, x := range v {
-				n += bw.WriteI32(x, e)
-			}
+			n += bw.WriteI32s(v, e)
 		case []int64:
-			for _, x := range v {
-				n += bw.WriteI64(x, e)
-			}
+			n += bw.WriteI64s(v, e)
 		case []uint8:
-			for _, x := range v {
-				n += bw.WriteU8(x)
-			}
+			n += bw.WriteU8s(v)
 		case []uint16:
-			for _, x := range v {
-				n += bw.WriteU16(x, e)
-			}
+			n += bw.WriteU16s(v, e)
 		case []uint32:
-			for _, x := range v {
-				n += bw.WriteU32(x, e)
-			}
+			n += bw.WriteU32s(v, e)
 		case []uint64:
-			for _, x := range v {
-				n += bw.WriteU64(x, e)
-			}
+			n += bw.WriteU64s(v, e)
+		case []float32:
+			n += bw.WriteF32s(v, e)
+		case []float64:
+			n += bw.WriteF64s(v, e)
 		case int8:
 			n += bw.WriteI8(v)
 		case int16:
@@ -319,6 +627,10 @@ func (bw *Writer) WriteX(e Endian, chainData ...interface{}) int {
 			n += bw.WriteU32(v, e)
 		case uint64:
 			n += bw.WriteU64(v, e)
+		case float32:
+			n += bw.WriteF32(v, e)
+		case float64:
+			n += bw.WriteF64(v, e)
 		case *int8:
 			n += bw.WriteI8(*v)
 		case *int16:
@@ -335,6 +647,10 @@ func (bw *Writer) WriteX(e Endian, chainData ...interface{}) int {
 			n += bw.WriteU32(*v, e)
 		case *uint64:
 			n += bw.WriteU64(*v, e)
+		case *float32:
+			n += bw.WriteF32(*v, e)
+		case *float64:
+			n += bw.WriteF64(*v, e)
 		default:
 			panic(fmt.Errorf("not supported type %T", v))
 		}
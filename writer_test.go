@@ -1,6 +1,7 @@
 package binaryio
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -636,3 +637,286 @@ func TestWriter(t *testing.T) {
 		removeFile(testFileName, t)
 	}
 }
+
+type failWriterAt struct{}
+
+func (failWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriterBuffered(t *testing.T) {
+	testFileName := "test.bin"
+
+	{
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw, WithBufferSize(16))
+		w.WriteU32(0, BigEndian) // size, patched below
+		for i := 0; i < 10; i++ {
+			w.WriteU16(uint16(i), LittleEndian)
+		}
+		end := w.GetOffset()
+		w.SetOffset(0)
+		w.WriteU32(uint32(end), BigEndian)
+		w.SetOffset(end)
+		w.WriteRaw(make([]byte, 40))
+		w.SetOffset(100)
+		w.WriteU8(0xAA)
+		w.SetOffset(2)
+		w.WriteU16(0xBEEF, BigEndian)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		fw.Close()
+
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		if v := r.ReadU16(BigEndian); v != 0 {
+			t.Fatalf("Invalid patched size hi %x", v)
+		}
+		if v := r.ReadU16(BigEndian); v != 0xBEEF {
+			t.Fatalf("Invalid patched value %x", v)
+		}
+		for i := 0; i < 10; i++ {
+			if r.ReadU16(LittleEndian) != uint16(i) {
+				t.Fatalf("Invalid buffered WriteU16")
+			}
+		}
+		r.SetOffset(100)
+		if r.ReadU8() != 0xAA || r.Err() != nil {
+			t.Fatalf("Invalid buffered WriteU8")
+		}
+		fr.Close()
+
+		removeFile(testFileName, t)
+	}
+	{
+		w := NewWriter(failWriterAt{}, WithPageBuffer())
+		if w.WriteU32(1, BigEndian) != 4 || w.Err() != nil {
+			t.Fatalf("Buffered write must be deferred")
+		}
+		w.SetOffset(1 << 20)
+		w.WriteU32(1, BigEndian)
+		if w.Err() == nil {
+			t.Fatalf("Deferred error must surface on jump")
+		}
+		if w.Flush() == nil {
+			t.Fatalf("Flush must report the deferred error")
+		}
+	}
+}
+
+func benchmarkWriteU16(b *testing.B, opts ...Option) {
+	testFileName := "bench.bin"
+
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(testFileName)
+	defer fw.Close()
+
+	b.SetBytes(2)
+	b.ResetTimer()
+	w := NewWriter(fw, opts...)
+	for i := 0; i < b.N; i++ {
+		if w.GetOffset() == 1<<20 {
+			w.SetOffset(0)
+		}
+		w.WriteU16(uint16(i), LittleEndian)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkWriteU16(b *testing.B) {
+	benchmarkWriteU16(b)
+}
+
+func BenchmarkWriteU16Buffered(b *testing.B) {
+	benchmarkWriteU16(b, WithPageBuffer())
+}
+
+func TestWriterSlices(t *testing.T) {
+	testFileName := "test.bin"
+
+	u16 := []uint16{0x0102, 0x0304, 0xFFFE}
+	i32 := []int32{-1, math.MinInt32, math.MaxInt32}
+	u64 := []uint64{0x0102030405060708, math.MaxUint64}
+	f32 := []float32{1.5, float32(math.Inf(-1))}
+	f64 := []float64{math.Pi, -0.25}
+	i8 := []int8{-128, 127}
+
+	for _, e := range []Endian{LittleEndian, BigEndian} {
+		fw := openWriteFile(testFileName, t)
+		w := NewWriter(fw)
+		n := w.WriteU16s(u16, e)
+		n += w.WriteI32s(i32, e)
+		n += w.WriteU64s(u64, e)
+		n += w.WriteF32s(f32, e)
+		n += w.WriteF64s(f64, e)
+		n += w.WriteI8s(i8)
+		n += w.WriteF32(2.5, e)
+		n += w.WriteF64(-2.5, e)
+		if w.Err() != nil {
+			t.Fatal(w.Err())
+		}
+		if n != 6+12+16+8+16+2+4+8 {
+			t.Fatalf("Invalid slice write size %d", n)
+		}
+		if u16[0] != 0x0102 {
+			t.Fatalf("WriteU16s must not modify its input")
+		}
+		fw.Close()
+
+		fr := openReadFile(testFileName, t)
+		r := NewReader(fr)
+		for _, v := range u16 {
+			if r.ReadU16(e) != v {
+				t.Fatalf("Invalid WriteU16s")
+			}
+		}
+		ri32 := make([]int32, len(i32))
+		r.ReadI32s(ri32, e)
+		ru64 := make([]uint64, len(u64))
+		r.ReadU64s(ru64, e)
+		rf32 := make([]float32, len(f32))
+		r.ReadF32s(rf32, e)
+		rf64 := make([]float64, len(f64))
+		r.ReadF64s(rf64, e)
+		ri8 := make([]int8, len(i8))
+		r.ReadI8s(ri8)
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		for i := range i32 {
+			if ri32[i] != i32[i] {
+				t.Fatalf("Invalid ReadI32s")
+			}
+		}
+		for i := range u64 {
+			if ru64[i] != u64[i] {
+				t.Fatalf("Invalid ReadU64s")
+			}
+		}
+		for i := range f32 {
+			if rf32[i] != f32[i] {
+				t.Fatalf("Invalid ReadF32s")
+			}
+		}
+		for i := range f64 {
+			if rf64[i] != f64[i] {
+				t.Fatalf("Invalid ReadF64s")
+			}
+		}
+		for i := range i8 {
+			if ri8[i] != i8[i] {
+				t.Fatalf("Invalid ReadI8s")
+			}
+		}
+		if r.ReadF32(e) != 2.5 || r.ReadF64(e) != -2.5 {
+			t.Fatalf("Invalid ReadF32/ReadF64")
+		}
+		fr.Close()
+	}
+
+	removeFile(testFileName, t)
+}
+
+func benchmarkWriteSamples(b *testing.B, bulk bool) {
+	testFileName := "bench.bin"
+	samples := make([]uint16, 4096)
+
+	fw, err := os.Create(testFileName)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(testFileName)
+	defer fw.Close()
+
+	b.SetBytes(int64(len(samples) * 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(fw)
+		if bulk {
+			w.WriteU16s(samples, BigEndian)
+		} else {
+			for _, v := range samples {
+				w.WriteU16(v, BigEndian)
+			}
+		}
+		if w.Err() != nil {
+			b.Fatal(w.Err())
+		}
+	}
+}
+
+func BenchmarkWriteU16Loop(b *testing.B) {
+	benchmarkWriteSamples(b, false)
+}
+
+func BenchmarkWriteU16s(b *testing.B) {
+	benchmarkWriteSamples(b, true)
+}
+
+func TestWriterUN(t *testing.T) {
+	testFileName := "test.bin"
+
+	fw := openWriteFile(testFileName, t)
+	w := NewWriter(fw)
+	n := w.WriteU48(0x0011223344FF, BigEndian)
+	n += w.WriteU40(0x123456789A, LittleEndian)
+	n += w.WriteI56(-2, BigEndian)
+	n += w.WriteI40(-549755813888, LittleEndian)
+	n += w.WriteIN(-1, 3, BigEndian)
+	n += w.WriteUN(math.MaxUint64, 8, LittleEndian)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	if n != 6+5+7+5+3+8 {
+		t.Fatalf("Invalid WriteUN size %d", n)
+	}
+	fw.Close()
+
+	fr := openReadFile(testFileName, t)
+	r := NewReader(fr)
+	if v := r.ReadU48(BigEndian); v != 0x0011223344FF {
+		t.Fatalf("Invalid ReadU48 %x", v)
+	}
+	if v := r.ReadU40(LittleEndian); v != 0x123456789A {
+		t.Fatalf("Invalid ReadU40 %x", v)
+	}
+	if v := r.ReadI56(BigEndian); v != -2 {
+		t.Fatalf("Invalid ReadI56 %d", v)
+	}
+	if v := r.ReadI40(LittleEndian); v != -549755813888 {
+		t.Fatalf("Invalid ReadI40 %d", v)
+	}
+	if v := r.ReadIN(3, BigEndian); v != -1 {
+		t.Fatalf("Invalid ReadIN %d", v)
+	}
+	if v := r.ReadUN(8, LittleEndian); v != math.MaxUint64 {
+		t.Fatalf("Invalid ReadUN %x", v)
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+	r.ReadUN(9, LittleEndian)
+	if r.Err() != ErrInvalidWidth {
+		t.Fatalf("Invalid ReadUN width error %v", r.Err())
+	}
+	fr.Close()
+
+	{
+		w := NewWriter(failWriterAt{})
+		if w.WriteU40(1<<40, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("WriteU40 must overflow")
+		}
+		w = NewWriter(failWriterAt{})
+		if w.WriteI48(1<<47, BigEndian) != 0 || w.Err() != ErrOverflow {
+			t.Fatalf("WriteI48 must overflow")
+		}
+	}
+
+	removeFile(testFileName, t)
+}
@@ -0,0 +1,260 @@
+package zipfmt
+
+import (
+	"io"
+	"unicode/utf8"
+
+	bio "github.com/takurooo/binaryio"
+	"github.com/takurooo/binaryio/internal/ascii"
+)
+
+// Versions written by Writer.
+const (
+	version20 = 20
+	version45 = 45 // ZIP64
+	flagUTF8  = 0x800
+)
+
+// zip64ExtraLen is the largest ZIP64 extra field written, with both sizes and
+// the local header offset.
+const zip64ExtraLen = 4 + 3*8
+
+// Writer builds an archive of stored entries. The CRC and sizes of each entry
+// are back-patched into its local header once its data is complete, so no
+// data descriptors are needed.
+type Writer struct {
+	w       *bio.Writer
+	entries []*Entry
+	cur     *Entry
+	cw      *entryWriter
+	zip64   bool // cur has a ZIP64 extra field in its local header
+	start   int64
+	Comment string
+}
+
+// NewWriter returns a Writer starting at offset 0 of w.
+func NewWriter(w io.WriterAt) *Writer {
+	return &Writer{w: bio.NewWriter(w, bio.WithBufferSize(64<<10))}
+}
+
+type entryWriter struct {
+	zw *Writer
+}
+
+func (ew *entryWriter) Write(p []byte) (int, error) {
+	if ew.zw.cw != ew {
+		return 0, ErrFormat
+	}
+	w := ew.zw.w
+	n := w.WriteRaw(p)
+	return n, w.Err()
+}
+
+// Create finishes the previous entry and starts a stored entry. Name, Modified,
+// Extra, Comment and ExternalAttr are taken from e; Modified must be zero or
+// within the DOS range of 1980 to 2107. A UncompressedSize of at
+// least 4 GiB reserves ZIP64 fields in the local header; other entries are
+// limited to 4 GiB. Name and Comment are limited to 65535 bytes and Extra to
+// 65507, leaving room for a ZIP64 extra field. The Writer keeps a copy of e
+// and does not modify it. The returned writer is valid until the next Create
+// or Close.
+func (zw *Writer) Create(e *Entry) (io.Writer, error) {
+	if len(e.Name) > max16 || len(e.Extra) > max16-zip64ExtraLen || len(e.Comment) > max16 {
+		return nil, ErrTooLong
+	}
+	if err := zw.finish(); err != nil {
+		return nil, err
+	}
+	ec := *e
+	e = &ec
+	w := zw.w
+	e.Method = Store
+	e.VersionNeeded = version20
+	e.VersionMadeBy = version45
+	if !ascii.ValidString(e.Name) && utf8.ValidString(e.Name) {
+		e.Flags |= flagUTF8
+	}
+	e.LocalHeaderOffset = uint64(w.GetOffset())
+	zw.zip64 = e.UncompressedSize >= max32
+	if zw.zip64 {
+		e.VersionNeeded = version45
+	}
+
+	w.WriteU32(sigLocal, le)
+	w.WriteU16(e.VersionNeeded, le)
+	w.WriteU16(e.Flags, le)
+	w.WriteU16(e.Method, le)
+	zw.writeTime(e)
+	w.WriteU32(0, le) // CRC-32, patched
+	if zw.zip64 {
+		w.WriteU32(0xFFFFFFFF, le)
+		w.WriteU32(0xFFFFFFFF, le)
+	} else {
+		w.WriteU32(0, le) // sizes, patched
+		w.WriteU32(0, le)
+	}
+	extra := e.Extra
+	if zw.zip64 {
+		extra = append(make([]byte, 20), extra...)
+	}
+	w.WriteU16(uint16(len(e.Name)), le)
+	w.WriteU16(uint16(len(extra)), le)
+	w.WriteRaw([]byte(e.Name))
+	if zw.zip64 {
+		w.WriteU16(ExtraZip64, le)
+		w.WriteU16(16, le)
+		w.WriteU64(0, le) // sizes, patched
+		w.WriteU64(0, le)
+		extra = extra[20:]
+	}
+	w.WriteRaw(extra)
+	if w.Err() != nil {
+		return nil, w.Err()
+	}
+
+	zw.cur = e
+	zw.start = w.GetOffset()
+	zw.cw = &entryWriter{zw}
+	w.StartHash(bio.NewCRC32IEEE())
+	return zw.cw, nil
+}
+
+// writeTime writes the modification time as DOS time and date words. The zero
+// time is written as zero words.
+func (zw *Writer) writeTime(e *Entry) {
+	if e.Modified.IsZero() {
+		zw.w.WriteU32(0, le)
+		return
+	}
+	zw.w.WriteTime(e.Modified, bio.TimeDOS, le)
+}
+
+// finish back-patches the CRC and sizes of the current entry.
+func (zw *Writer) finish() error {
+	e := zw.cur
+	if e == nil {
+		return nil
+	}
+	zw.cur, zw.cw = nil, nil
+	w := zw.w
+	e.CRC32 = w.SumHash32()
+	end := w.GetOffset()
+	size := uint64(end - zw.start)
+	if size >= max32 && !zw.zip64 {
+		return ErrTooLarge
+	}
+	e.CompressedSize, e.UncompressedSize = size, size
+
+	local := int64(e.LocalHeaderOffset)
+	w.SetOffset(local + 14)
+	w.WriteU32(e.CRC32, le)
+	if zw.zip64 {
+		w.SetOffset(local + localLen + int64(len(e.Name)) + 4)
+		w.WriteU64(size, le)
+		w.WriteU64(size, le)
+	} else {
+		w.WriteU32(uint32(size), le)
+		w.WriteU32(uint32(size), le)
+	}
+	w.SetOffset(end)
+	zw.entries = append(zw.entries, e)
+	return w.Err()
+}
+
+// Close finishes the last entry and writes the central directory and end of
+// central directory records, using ZIP64 records when needed. Comment is
+// limited to 65535 bytes.
+func (zw *Writer) Close() error {
+	if len(zw.Comment) > max16 {
+		return ErrTooLong
+	}
+	if err := zw.finish(); err != nil {
+		return err
+	}
+	w := zw.w
+	cdOffset := uint64(w.GetOffset())
+	for _, e := range zw.entries {
+		e.Offset = w.GetOffset()
+		zw.writeCentral(e)
+	}
+	cdSize := uint64(w.GetOffset()) - cdOffset
+	n := uint64(len(zw.entries))
+
+	if n >= max16 || cdSize >= max32 || cdOffset >= max32 {
+		z64 := uint64(w.GetOffset())
+		w.WriteU32(sigZip64EOCD, le)
+		w.WriteU64(zip64EOCDLen-12, le)
+		w.WriteU16(version45, le)
+		w.WriteU16(version45, le)
+		w.WriteU32(0, le)
+		w.WriteU32(0, le)
+		w.WriteU64(n, le)
+		w.WriteU64(n, le)
+		w.WriteU64(cdSize, le)
+		w.WriteU64(cdOffset, le)
+
+		w.WriteU32(sigZip64Locate, le)
+		w.WriteU32(0, le)
+		w.WriteU64(z64, le)
+		w.WriteU32(1, le)
+	}
+
+	w.WriteU32(sigEOCD, le)
+	w.WriteU16(0, le)
+	w.WriteU16(0, le)
+	w.WriteU16(uint16(saturate(n, max16)), le)
+	w.WriteU16(uint16(saturate(n, max16)), le)
+	w.WriteU32(uint32(saturate(cdSize, max32)), le)
+	w.WriteU32(uint32(saturate(cdOffset, max32)), le)
+	w.WriteU16(uint16(len(zw.Comment)), le)
+	w.WriteRaw([]byte(zw.Comment))
+	return w.Flush()
+}
+
+// saturate returns v, or the all-ones marker limit when v does not fit below it.
+func saturate(v, limit uint64) uint64 {
+	if v >= limit {
+		return limit
+	}
+	return v
+}
+
+func (zw *Writer) writeCentral(e *Entry) {
+	w := zw.w
+	var z64 []uint64
+	for _, v := range []uint64{e.UncompressedSize, e.CompressedSize, e.LocalHeaderOffset} {
+		if v >= max32 {
+			z64 = append(z64, v)
+		}
+	}
+	extraLen := len(e.Extra)
+	if len(z64) > 0 {
+		extraLen += 4 + 8*len(z64)
+		e.VersionNeeded = version45
+	}
+
+	w.WriteU32(sigCentral, le)
+	w.WriteU16(e.VersionMadeBy, le)
+	w.WriteU16(e.VersionNeeded, le)
+	w.WriteU16(e.Flags, le)
+	w.WriteU16(e.Method, le)
+	zw.writeTime(e)
+	w.WriteU32(e.CRC32, le)
+	w.WriteU32(uint32(saturate(e.CompressedSize, max32)), le)
+	w.WriteU32(uint32(saturate(e.UncompressedSize, max32)), le)
+	w.WriteU16(uint16(len(e.Name)), le)
+	w.WriteU16(uint16(extraLen), le)
+	w.WriteU16(uint16(len(e.Comment)), le)
+	w.WriteU16(0, le)
+	w.WriteU16(e.InternalAttr, le)
+	w.WriteU32(e.ExternalAttr, le)
+	w.WriteU32(uint32(saturate(e.LocalHeaderOffset, max32)), le)
+	w.WriteRaw([]byte(e.Name))
+	if len(z64) > 0 {
+		w.WriteU16(ExtraZip64, le)
+		w.WriteU16(uint16(8*len(z64)), le)
+		w.WriteU64s(z64, le)
+	}
+	w.WriteRaw(e.Extra)
+	w.WriteRaw([]byte(e.Comment))
+}
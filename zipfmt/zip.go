@@ -0,0 +1,368 @@
+// Package zipfmt reads and writes the ZIP container structures: the end of
+// central directory record, ZIP64 extensions, central directory entries and
+// local headers. Entry data is exposed as raw byte ranges; compression is left
+// to the caller.
+package zipfmt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+// Errors returned by the zipfmt package.
+var (
+	ErrFormat   = errors.New("zipfmt: invalid format")
+	ErrNotFound = errors.New("zipfmt: end of central directory not found")
+	ErrTooLarge = errors.New("zipfmt: entry too large without ZIP64 size hint")
+	ErrTooLong  = errors.New("zipfmt: name, extra field or comment too long")
+)
+
+// Record signatures.
+const (
+	sigLocal       = 0x04034B50
+	sigCentral     = 0x02014B50
+	sigEOCD        = 0x06054B50
+	sigZip64EOCD   = 0x06064B50
+	sigZip64Locate = 0x07064B50
+)
+
+// Record sizes without variable-length fields.
+const (
+	localLen       = 30
+	centralLen     = 46
+	eocdLen        = 22
+	zip64EOCDLen   = 56
+	zip64LocateLen = 20
+)
+
+// Compression methods.
+const (
+	Store   = 0
+	Deflate = 8
+)
+
+// ExtraZip64 is the header ID of the ZIP64 extended information extra field.
+const ExtraZip64 = 0x0001
+
+// Limits of the 16- and 32-bit fields, beyond which ZIP64 records are used.
+const (
+	max16 = 0xFFFF
+	max32 = 0xFFFFFFFF
+)
+
+var le = bio.LittleEndian
+
+// ExtraField is one entry of an extra field block.
+type ExtraField struct {
+	ID   uint16
+	Data []byte
+}
+
+// ParseExtra splits an extra field block into its entries.
+func ParseExtra(extra []byte) ([]ExtraField, error) {
+	var fields []ExtraField
+	for len(extra) > 0 {
+		if len(extra) < 4 {
+			return nil, ErrFormat
+		}
+		id := uint16(extra[0]) | uint16(extra[1])<<8
+		n := int(extra[2]) | int(extra[3])<<8
+		if len(extra) < 4+n {
+			return nil, ErrFormat
+		}
+		fields = append(fields, ExtraField{ID: id, Data: extra[4 : 4+n]})
+		extra = extra[4+n:]
+	}
+	return fields, nil
+}
+
+// readTime reads the DOS time and date words. Zero words, as written by
+// Writer for the zero time, and other invalid values give the zero time
+// without setting an error on br.
+func readTime(br *bio.Reader) time.Time {
+	tr := br.At(br.GetOffset())
+	t := tr.ReadTime(bio.TimeDOS, le)
+	br.ReadU32(le)
+	if tr.Err() != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// readZip64 replaces the saturated values among sizes and disk with those of
+// the ZIP64 extra fields in extra, which hold them in a fixed order but only
+// when saturated. disk may be nil.
+func readZip64(extra []byte, sizes []*uint64, disk *uint32) error {
+	fields, err := ParseExtra(extra)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.ID != ExtraZip64 {
+			continue
+		}
+		br := bio.NewReader(bytes.NewReader(f.Data))
+		for _, p := range sizes {
+			if *p == max32 {
+				*p = br.ReadU64(le)
+			}
+		}
+		if disk != nil && *disk == max16 {
+			*disk = br.ReadU32(le)
+		}
+		if br.Err() != nil {
+			return ErrFormat
+		}
+	}
+	return nil
+}
+
+// EOCD is the end of central directory record, with values from the ZIP64
+// record when present. Offset is the position of the classic record.
+type EOCD struct {
+	Disk          uint32
+	CDDisk        uint32
+	EntriesOnDisk uint64
+	Entries       uint64
+	CDSize        uint64
+	CDOffset      uint64
+	Comment       string
+	Offset        int64
+	Zip64         bool
+}
+
+// FindEOCD scans backward from the end of r for the end of central directory
+// record, which may be followed by a comment of up to 64 KiB.
+func FindEOCD(r io.ReaderAt, size int64) (*EOCD, error) {
+	start := size - eocdLen - 0xFFFF
+	if start < 0 {
+		start = 0
+	}
+	br := bio.NewReader(r)
+	br.SetOffset(start)
+	tail := br.ReadRaw(uint64(size - start))
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	for i := len(tail) - eocdLen; i >= 0; i-- {
+		if tail[i] != 'P' || tail[i+1] != 'K' || tail[i+2] != 5 || tail[i+3] != 6 {
+			continue
+		}
+		// The comment must end exactly at the end of the file.
+		n := int(tail[i+20]) | int(tail[i+21])<<8
+		if i+eocdLen+n != len(tail) {
+			continue
+		}
+		return readEOCD(r, start+int64(i))
+	}
+	return nil, ErrNotFound
+}
+
+func readEOCD(r io.ReaderAt, off int64) (*EOCD, error) {
+	br := bio.NewReader(r)
+	br.SetOffset(off + 4)
+	e := &EOCD{Offset: off}
+	e.Disk = uint32(br.ReadU16(le))
+	e.CDDisk = uint32(br.ReadU16(le))
+	e.EntriesOnDisk = uint64(br.ReadU16(le))
+	e.Entries = uint64(br.ReadU16(le))
+	e.CDSize = uint64(br.ReadU32(le))
+	e.CDOffset = uint64(br.ReadU32(le))
+	if n := br.ReadU16(le); n > 0 {
+		e.Comment = string(br.ReadRaw(uint64(n)))
+	}
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+
+	// A ZIP64 locator immediately precedes the classic record.
+	if off < zip64LocateLen {
+		return e, nil
+	}
+	br.SetOffset(off - zip64LocateLen)
+	if br.ReadU32(le) != sigZip64Locate {
+		return e, nil
+	}
+	br.ReadU32(le) // disk with the ZIP64 record
+	z64 := int64(br.ReadU64(le))
+	br.SetOffset(z64)
+	if br.ReadU32(le) != sigZip64EOCD {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	br.ReadU64(le) // record size
+	br.ReadU16(le) // version made by
+	br.ReadU16(le) // version needed
+	e.Disk = br.ReadU32(le)
+	e.CDDisk = br.ReadU32(le)
+	e.EntriesOnDisk = br.ReadU64(le)
+	e.Entries = br.ReadU64(le)
+	e.CDSize = br.ReadU64(le)
+	e.CDOffset = br.ReadU64(le)
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	e.Zip64 = true
+	return e, nil
+}
+
+// Entry is a central directory entry. Sizes, the local header offset and the
+// disk number are taken from the ZIP64 extra field when present. Offset is the
+// position of the central directory record.
+type Entry struct {
+	VersionMadeBy     uint16
+	VersionNeeded     uint16
+	Flags             uint16
+	Method            uint16
+	Modified          time.Time
+	CRC32             uint32
+	CompressedSize    uint64
+	UncompressedSize  uint64
+	DiskStart         uint32
+	InternalAttr      uint16
+	ExternalAttr      uint32
+	LocalHeaderOffset uint64
+	Name              string
+	Extra             []byte
+	Comment           string
+	Offset            int64
+	src               io.ReaderAt
+}
+
+// ExtraFields parses the central directory extra field block.
+func (e *Entry) ExtraFields() ([]ExtraField, error) {
+	return ParseExtra(e.Extra)
+}
+
+// LocalHeader is a local file header. Sizes and CRC are zero when flag bit 3
+// defers them to a data descriptor. Sizes are taken from the ZIP64 extra field
+// when present.
+type LocalHeader struct {
+	VersionNeeded    uint16
+	Flags            uint16
+	Method           uint16
+	Modified         time.Time
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+	Name             string
+	Extra            []byte
+	DataOffset       int64
+}
+
+// LocalHeader reads the local header of e.
+func (e *Entry) LocalHeader() (*LocalHeader, error) {
+	br := bio.NewReader(e.src)
+	off := int64(e.LocalHeaderOffset)
+	br.SetOffset(off)
+	if br.ReadU32(le) != sigLocal {
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		return nil, ErrFormat
+	}
+	h := &LocalHeader{}
+	h.VersionNeeded = br.ReadU16(le)
+	h.Flags = br.ReadU16(le)
+	h.Method = br.ReadU16(le)
+	h.Modified = readTime(br)
+	h.CRC32 = br.ReadU32(le)
+	h.CompressedSize = uint64(br.ReadU32(le))
+	h.UncompressedSize = uint64(br.ReadU32(le))
+	nameLen := br.ReadU16(le)
+	extraLen := br.ReadU16(le)
+	h.Name = string(br.ReadRaw(uint64(nameLen)))
+	h.Extra = br.ReadRaw(uint64(extraLen))
+	if br.Err() != nil {
+		return nil, br.Err()
+	}
+	if err := readZip64(h.Extra, []*uint64{&h.UncompressedSize, &h.CompressedSize}, nil); err != nil {
+		return nil, err
+	}
+	h.DataOffset = br.GetOffset()
+	return h, nil
+}
+
+// DataOffset returns the position of the entry data, after its local header.
+func (e *Entry) DataOffset() (int64, error) {
+	h, err := e.LocalHeader()
+	if err != nil {
+		return 0, err
+	}
+	return h.DataOffset, nil
+}
+
+// Data returns a reader over the raw, possibly compressed, entry data.
+func (e *Entry) Data() (*io.SectionReader, error) {
+	off, err := e.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(e.src, off, int64(e.CompressedSize)), nil
+}
+
+// Archive is a parsed central directory.
+type Archive struct {
+	EOCD    *EOCD
+	Entries []*Entry
+}
+
+// Decode locates the end of central directory record of r and parses the
+// central directory. Invalid DOS modification times are read as the zero
+// time.
+func Decode(r io.ReaderAt, size int64) (*Archive, error) {
+	eocd, err := FindEOCD(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if eocd.CDOffset > uint64(size) || eocd.CDSize > uint64(size)-eocd.CDOffset || eocd.Entries > eocd.CDSize/centralLen {
+		return nil, ErrFormat
+	}
+
+	a := &Archive{EOCD: eocd}
+	br := bio.NewReader(r, bio.WithBufferSize(64<<10))
+	br.SetOffset(int64(eocd.CDOffset))
+	for i := uint64(0); i < eocd.Entries; i++ {
+		e := &Entry{Offset: br.GetOffset(), src: r}
+		if br.ReadU32(le) != sigCentral {
+			if br.Err() != nil {
+				return nil, br.Err()
+			}
+			return nil, ErrFormat
+		}
+		e.VersionMadeBy = br.ReadU16(le)
+		e.VersionNeeded = br.ReadU16(le)
+		e.Flags = br.ReadU16(le)
+		e.Method = br.ReadU16(le)
+		e.Modified = readTime(br)
+		e.CRC32 = br.ReadU32(le)
+		e.CompressedSize = uint64(br.ReadU32(le))
+		e.UncompressedSize = uint64(br.ReadU32(le))
+		nameLen := br.ReadU16(le)
+		extraLen := br.ReadU16(le)
+		commentLen := br.ReadU16(le)
+		e.DiskStart = uint32(br.ReadU16(le))
+		e.InternalAttr = br.ReadU16(le)
+		e.ExternalAttr = br.ReadU32(le)
+		e.LocalHeaderOffset = uint64(br.ReadU32(le))
+		e.Name = string(br.ReadRaw(uint64(nameLen)))
+		e.Extra = br.ReadRaw(uint64(extraLen))
+		e.Comment = string(br.ReadRaw(uint64(commentLen)))
+		if br.Err() != nil {
+			return nil, br.Err()
+		}
+		sizes := []*uint64{&e.UncompressedSize, &e.CompressedSize, &e.LocalHeaderOffset}
+		if err := readZip64(e.Extra, sizes, &e.DiskStart); err != nil {
+			return nil, err
+		}
+		a.Entries = append(a.Entries, e)
+	}
+	return a, nil
+}
@@ -0,0 +1,354 @@
+package zipfmt
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	bio "github.com/takurooo/binaryio"
+)
+
+type testEntry struct {
+	entry *Entry
+	data  string
+}
+
+func testEntries() []testEntry {
+	mod := time.Date(2020, 9, 7, 12, 34, 56, 0, time.UTC)
+	return []testEntry{
+		{&Entry{Name: "hello.txt", Modified: mod, Extra: []byte{0xFE, 0xCA, 2, 0, 'h', 'i'}, Comment: "greeting", ExternalAttr: 0644 << 16}, "Hello, World!"},
+		{&Entry{Name: "日本/語.txt", Modified: mod.Add(time.Hour)}, "こんにちは"},
+		{&Entry{Name: "empty"}, ""},
+	}
+}
+
+func writeArchive(t *testing.T, name string, entries []testEntry, comment string) []byte {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	zw := NewWriter(fw)
+	zw.Comment = comment
+	for _, te := range entries {
+		w, err := zw.Create(te.entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(te.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func checkArchive(t *testing.T, b []byte, entries []testEntry, comment string) *Archive {
+	// archive/zip must accept the archive.
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Comment != comment || len(zr.File) != len(entries) {
+		t.Fatalf("Invalid archive %q %d", zr.Comment, len(zr.File))
+	}
+	for i, f := range zr.File {
+		want := entries[i]
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name != want.entry.Name || string(data) != want.data || f.Comment != want.entry.Comment {
+			t.Fatalf("Invalid entry %q %q", f.Name, data)
+		}
+		if !want.entry.Modified.IsZero() && !f.Modified.Equal(want.entry.Modified) {
+			t.Fatalf("Invalid time %v", f.Modified)
+		}
+	}
+
+	a, err := Decode(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.EOCD.Comment != comment || len(a.Entries) != len(entries) {
+		t.Fatalf("Invalid EOCD %+v", a.EOCD)
+	}
+	for i, e := range a.Entries {
+		want := entries[i]
+		if e.Name != want.entry.Name || !e.Modified.Equal(want.entry.Modified) || e.CRC32 != zr.File[i].CRC32 {
+			t.Fatalf("Invalid entry %+v", e)
+		}
+		r, err := e.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(r)
+		if string(data) != want.data {
+			t.Fatalf("Invalid data %q", data)
+		}
+		h, err := e.LocalHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name != e.Name || h.CRC32 != e.CRC32 {
+			t.Fatalf("Invalid local header %+v", h)
+		}
+	}
+	return a
+}
+
+func TestArchive(t *testing.T) {
+	testFileName := "test.zip"
+	defer os.Remove(testFileName)
+
+	entries := testEntries()
+	b := writeArchive(t, testFileName, entries, "archive comment PK\x05\x06")
+	a := checkArchive(t, b, entries, "archive comment PK\x05\x06")
+	if a.EOCD.Zip64 {
+		t.Fatalf("Unexpected ZIP64 records")
+	}
+
+	e := a.Entries[0]
+	fields, err := e.ExtraFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields[0].ID != 0xCAFE || string(fields[0].Data) != "hi" {
+		t.Fatalf("Invalid extra fields %+v", fields)
+	}
+	off, err := e.DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off != localLen+int64(len(e.Name))+6 || string(b[off:off+5]) != "Hello" {
+		t.Fatalf("Invalid data offset %d", off)
+	}
+	if e.Comment != "greeting" || e.ExternalAttr != 0644<<16 || a.Entries[1].Flags&flagUTF8 == 0 {
+		t.Fatalf("Invalid entry attributes %+v", e)
+	}
+
+	if _, err := Decode(bytes.NewReader(b[:len(b)-1]), int64(len(b)-1)); err != ErrNotFound {
+		t.Fatalf("Invalid truncation error %v", err)
+	}
+	// central directory past the end of the file
+	bad := append([]byte(nil), b...)
+	bad[a.EOCD.Offset+16+3] = 0x7F
+	if _, err := Decode(bytes.NewReader(bad), int64(len(bad))); err != ErrFormat {
+		t.Fatalf("Invalid directory offset error %v", err)
+	}
+
+	// an invalid DOS date reads as the zero time
+	bad = append([]byte(nil), b...)
+	bad[a.EOCD.CDOffset+14] = 0xFF
+	bad[a.EOCD.CDOffset+15] = 0xFF
+	if got, err := Decode(bytes.NewReader(bad), int64(len(bad))); err != nil || !got.Entries[0].Modified.IsZero() || got.Entries[1].Modified.IsZero() {
+		t.Fatalf("Invalid bad time handling %v", err)
+	}
+
+	// Create copies the entry
+	var out bio.Buffer
+	e = &Entry{Name: "c"}
+	zw := NewWriter(&out)
+	if _, err := zw.Create(e); err != nil || e.VersionNeeded != 0 {
+		t.Fatalf("Invalid entry after Create %+v %v", e, err)
+	}
+	e.Name = "changed"
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decode(bytes.NewReader(out), int64(len(out))); err != nil || got.Entries[0].Name != "c" {
+		t.Fatalf("Invalid copied entry %v", err)
+	}
+	zw = NewWriter(&bio.Buffer{})
+	long := strings.Repeat("a", 0x10000)
+	for _, e := range []*Entry{{Name: long}, {Name: "a", Comment: long}, {Name: "a", Extra: []byte(long[zip64ExtraLen:])}} {
+		if _, err := zw.Create(e); err != ErrTooLong {
+			t.Fatalf("Invalid length error %v", err)
+		}
+	}
+	zw.Comment = long
+	if err := zw.Close(); err != ErrTooLong {
+		t.Fatalf("Invalid comment length error %v", err)
+	}
+}
+
+// sparseFile is an in-memory io.WriterAt and io.ReaderAt that only stores
+// pages holding nonzero bytes, so archives with gigabytes of zeros fit in memory.
+type sparseFile struct {
+	pages map[int64][]byte
+	size  int64
+}
+
+const pageSize = 64 << 10
+
+var zeroPage = make([]byte, pageSize)
+
+func (f *sparseFile) WriteAt(p []byte, off int64) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		page, in := off/pageSize, off%pageSize
+		k := len(p)
+		if k > int(pageSize-in) {
+			k = int(pageSize - in)
+		}
+		if f.pages[page] == nil && !bytes.Equal(p[:k], zeroPage[:k]) {
+			f.pages[page] = make([]byte, pageSize)
+		}
+		if b := f.pages[page]; b != nil {
+			copy(b[in:], p[:k])
+		}
+		p, off = p[k:], off+int64(k)
+	}
+	if off > f.size {
+		f.size = off
+	}
+	return n, nil
+}
+
+func (f *sparseFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off < f.size {
+		page, in := off/pageSize, off%pageSize
+		b := f.pages[page]
+		if b == nil {
+			b = zeroPage
+		}
+		k := copy(p[n:], b[in:])
+		if rest := f.size - off; int64(k) > rest {
+			k = int(rest)
+		}
+		n, off = n+k, off+int64(k)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestZip64(t *testing.T) {
+	// 65535 entries need a ZIP64 end of central directory record.
+	var buf bio.Buffer
+	zw := NewWriter(&buf)
+	for i := 0; i < max16; i++ {
+		if _, err := zw.Create(&Entry{Name: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil || len(zr.File) != max16 {
+		t.Fatalf("Invalid archive/zip entries %v", err)
+	}
+	a, err := Decode(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.EOCD.Zip64 || a.EOCD.Entries != max16 || a.Entries[max16-1].Name != "65534" {
+		t.Fatalf("Invalid ZIP64 directory %+v", a.EOCD)
+	}
+
+	// An entry of 4 GiB and more with the size hint, and one after it whose
+	// local header offset needs ZIP64.
+	const size = 1<<32 + 3
+	f := &sparseFile{pages: make(map[int64][]byte)}
+	zw = NewWriter(f)
+	w, err := zw.Create(&Entry{Name: "big", UncompressedSize: max32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 1<<20)
+	for i := 0; i < 4<<10; i++ {
+		w.Write(chunk)
+	}
+	w.Write([]byte("end"))
+	w, _ = zw.Create(&Entry{Name: "small"})
+	w.Write([]byte("tail"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err = zip.NewReader(f, f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.File[0].UncompressedSize64 != size || zr.File[1].Name != "small" {
+		t.Fatalf("Invalid archive/zip entries %+v", zr.File[0].FileHeader)
+	}
+	rc, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	if err != nil || string(data) != "tail" {
+		t.Fatalf("Invalid data %q %v", data, err)
+	}
+	a, err = Decode(f, f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.EOCD.Zip64 || a.Entries[0].CompressedSize != size || a.Entries[1].LocalHeaderOffset < max32 {
+		t.Fatalf("Invalid ZIP64 archive %+v", a.EOCD)
+	}
+	h, err := a.Entries[0].LocalHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.CompressedSize != size || h.UncompressedSize != size || h.Extra[0] != ExtraZip64 {
+		t.Fatalf("Invalid ZIP64 local header %+v", h)
+	}
+
+	// Without the size hint the entry is too large.
+	zw = NewWriter(&sparseFile{pages: make(map[int64][]byte)})
+	w, _ = zw.Create(&Entry{Name: "big"})
+	for i := 0; i < 4<<10; i++ {
+		w.Write(chunk)
+	}
+	if err := zw.Close(); err != ErrTooLarge {
+		t.Fatalf("Invalid size error %v", err)
+	}
+}
+
+func TestArchiveZip(t *testing.T) {
+	// Archives from archive/zip use data descriptors and deflate.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("a.txt")
+	w.Write(bytes.Repeat([]byte("abc"), 100))
+	zw.Close()
+	b := buf.Bytes()
+
+	a, err := Decode(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := a.Entries[0]
+	if e.Method != Deflate || e.UncompressedSize != 300 || e.Flags&0x8 == 0 {
+		t.Fatalf("Invalid entry %+v", e)
+	}
+	h, err := e.LocalHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.CRC32 != 0 || h.Method != Deflate {
+		t.Fatalf("Invalid local header %+v", h)
+	}
+}